@@ -17,7 +17,9 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -26,6 +28,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/spf13/pflag"
 
+	netdconfig "github.com/GoogleCloudPlatform/netd/pkg/config"
 	"github.com/GoogleCloudPlatform/netd/pkg/controllers/netconf"
 	"github.com/GoogleCloudPlatform/netd/pkg/metrics"
 	"github.com/GoogleCloudPlatform/netd/pkg/options"
@@ -42,7 +45,69 @@ func main() {
 		glog.Infof("FLAG: --%s=%q", f.Name, f.Value)
 	})
 
-	nc := netconf.NewNetworkConfigController(config.EnablePolicyRouting, config.EnableSourceValidMark, config.ExcludeDNS, config.ReconcileInterval)
+	netdconfig.DiffLogLevel = glog.Level(config.DiffLogVerbosity)
+
+	if config.SelfTest {
+		if err := netdconfig.SelfTest(context.Background()); err != nil {
+			glog.Exitf("self-test failed: %v", err)
+		}
+		glog.Flush()
+		return
+	}
+
+	nc := netconf.NewNetworkConfigController(config.EnablePolicyRouting, config.EnableSourceValidMark, config.ExcludeDNS, config.ReconcileInterval,
+		config.ReconcileJitter)
+	if len(config.CanaryPercent) > 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			glog.Exitf("failed to get the node name for --canary-percent: %v", err)
+		}
+		nc.SetCanary(hostname, config.CanaryPercent)
+	}
+	if config.Check {
+		match, err := nc.CheckDivergence()
+		if err != nil {
+			glog.Exitf("failed to query the live state: %v", err)
+		}
+		if !match {
+			glog.Exitf("live state diverges from the configured features")
+		}
+		glog.Flush()
+		return
+	}
+	if config.StateFile != "" {
+		nc.RestoreState(netconf.NewStateStore(config.StateFile))
+	}
+	if config.AuditLogSize > 0 {
+		auditLog, err := netconf.NewAuditLog(config.AuditLogSize, config.AuditLogFile)
+		if err != nil {
+			glog.Errorf("failed to create audit log: %v", err)
+		} else {
+			defer auditLog.Close()
+			nc.SetAuditLog(auditLog)
+			http.Handle("/audit", auditLog)
+		}
+	}
+	if config.FullReconcileEvery > 1 {
+		nc.SetChangeTracking(config.FullReconcileEvery)
+	}
+	if config.NetlinkBatch {
+		nc.SetNetlinkBatch(true)
+	}
+	if config.FailureExitThreshold > 0 {
+		nc.SetFailureThreshold(config.FailureExitThreshold, config.CriticalFeatures, nil)
+	}
+	if ok, err := netconf.HasNetAdmin(netconf.ProcSelfStatus); err != nil {
+		glog.Errorf("failed to check for CAP_NET_ADMIN: %v", err)
+	} else if !ok {
+		glog.Errorf("netd lacks CAP_NET_ADMIN, running read-only")
+		nc.SetReadOnly(true)
+	}
+	if config.SweepOrphanedChains && !nc.ReadOnly() {
+		if err := nc.SweepOrphanedChains(); err != nil {
+			glog.Errorf("failed to sweep orphaned iptables chains: %v", err)
+		}
+	}
 
 	stopCh := make(chan struct{})
 
@@ -52,14 +117,26 @@ func main() {
 	glog.Infof("Starting netd")
 	go nc.Run(stopCh, &wg)
 
+	// The health endpoint is served alongside /metrics.
+	http.Handle("/healthz", nc)
+
 	err := metrics.StartCollector()
 	if err != nil {
-		glog.Errorf("Could not start metrics collector: %v", err)
+		glog.Errorf("Could not start metrics collector, /metrics, /healthz and /audit are not served: %v", err)
 	}
 
+	// SIGUSR1 pauses reconciliation and SIGUSR2 resumes it.
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-	<-ch
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range ch {
+		if sig == syscall.SIGUSR1 {
+			nc.Pause()
+		} else if sig == syscall.SIGUSR2 {
+			nc.Resume()
+		} else {
+			break
+		}
+	}
 
 	glog.Infof("Shutting down netd ...")
 	close(stopCh)
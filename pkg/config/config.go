@@ -17,44 +17,166 @@ limitations under the License.
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"slices"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/golang/glog"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/metrics"
+)
+
+const (
+	tableFilter   = "filter"
+	tableNAT      = "nat"
+	tableMangle   = "mangle"
+	tableRaw      = "raw"
+	tableSecurity = "security"
 )
 
+// iptablesTables lists the tables IPTablesChainSpec can operate on.
+var iptablesTables = map[string]bool{
+	tableFilter:   true,
+	tableNAT:      true,
+	tableMangle:   true,
+	tableRaw:      true,
+	tableSecurity: true,
+}
+
 // Config interface
 type Config interface {
 	Ensure(enabled bool) error
 }
 
+// ChangeReporter is implemented by configs that can tell whether ensuring
+// them modified the system.
+type ChangeReporter interface {
+	EnsureChanged(enabled bool) (changed bool, err error)
+}
+
+// EnsureChanged ensures c and reports whether the system was modified.
+// Configs that do not implement ChangeReporter report a change on success.
+func EnsureChanged(c Config, enabled bool) (bool, error) {
+	if cr, ok := c.(ChangeReporter); ok {
+		return cr.EnsureChanged(enabled)
+	}
+	err := c.Ensure(enabled)
+	return err == nil, err
+}
+
 // Set defines the set of Config
 type Set struct {
 	Enabled     bool
 	FeatureName string
 	Configs     []Config
+	// Gate optionally narrows Enabled on instance metadata.
+	Gate *MetadataGate
+	// OnReconcileComplete, when set, is called with the outcome of every
+	// Ensure of the Set.
+	OnReconcileComplete func(ReconcileSummary)
+	// OnChange, when set, is called for every config whose Ensure modified
+	// the system.
+	OnChange func(ChangeEvent)
+	// Tracker, when set, skips the configs applied successfully by the
+	// previous Ensure with the same desired state, unless they are dynamic.
+	Tracker *ChangeTracker
+	// Window, when set, holds back applying the disruptive configs with
+	// ErrScheduled while it is closed. Removal proceeds at any time.
+	Window *MaintenanceWindow
+	// Canary, when set, narrows Enabled to the nodes it selects.
+	Canary *CanaryGate
+	// Recorder, when set, records the outcome of every Ensure for Status.
+	Recorder *StatusRecorder
+}
+
+// ChangeEvent describes one config of a Set that Ensure modified.
+type ChangeEvent struct {
+	FeatureName string
+	Config      Config
+	// Enabled is true when the config was applied and false when removed.
+	Enabled bool
+}
+
+// ReconcileSummary counts the outcome of one Set.Ensure.
+type ReconcileSummary struct {
+	FeatureName string
+	Enabled     bool
+	Total       int
+	Changed     int
+	Unchanged   int
+	Failed      int
+	// Deferred counts the configs that returned ErrDeferred.
+	Deferred int
 }
 
 type sysctler func(name string, params ...string) (string, error)
 
+type kernelVersioner func() (KernelVersion, error)
+
 // SysctlConfig defines sysctl config
 type SysctlConfig struct {
 	Key, Value, DefaultValue string
 	SysctlFunc               sysctler
+	// MinKernel is an optional kernel version, e.g. "5.4", below which the
+	// sysctl is skipped as unsupported instead of failing.
+	MinKernel string
+	// KernelVersionFunc defaults to CurrentKernelVersion.
+	KernelVersionFunc kernelVersioner
 }
 
 type routeAdder func(route *netlink.Route) error
 type routeDeler func(route *netlink.Route) error
+type routeReplacer func(route *netlink.Route) error
+type routeLister func(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error)
+type linkByNamer func(name string) (netlink.Link, error)
 
 // IPRouteConfig defines route config
 type IPRouteConfig struct {
 	Route    netlink.Route
 	RouteAdd routeAdder
 	RouteDel routeDeler
+	// RouteList is optional. When set, Ensure looks for the route in its table,
+	// comparing destination, gateway, scope, metric and protocol, instead of
+	// relying on EEXIST/ESRCH from the kernel, which ignores scope and adds a
+	// route differing only in metric next to the existing one.
+	RouteList routeLister
+	// RouteReplace is optional. When set together with RouteList, a route to
+	// the same destination that differs from Route is replaced in place.
+	RouteReplace routeReplacer
+	// VerifyAfterApply re-lists the table after adding the route and fails if
+	// the route is not there. It requires RouteList.
+	VerifyAfterApply bool
+	// KeepForeignRoutes leaves a different route to the same destination, such
+	// as a default route managed by another controller, in place instead of
+	// adding or replacing, and fails with ErrRouteConflict. It requires
+	// RouteList.
+	KeepForeignRoutes bool
+	// LinkName, when set, is resolved to the link's current index on every
+	// Ensure, so a route follows an interface that was recreated with a new
+	// index. A route left on the stale index is deleted. Set it with
+	// WithLinkName, so the config remembers the index it last resolved and
+	// deletes the stale route once rather than on every Ensure.
+	LinkName   string
+	LinkByName linkByNamer
+	linkIndex  *atomic.Int64
+	// GatewayResolver, when set, provides Route.Gw on every Ensure(true), e.g.
+	// from a Service, and a route via an outdated gateway is replaced. It
+	// requires RouteList and RouteReplace. Ensure(false) removes netd's route
+	// to Route.Dst whatever its gateway.
+	GatewayResolver func() (net.IP, error)
+	// SrcAddrList, when set, defers Ensure(true) with ErrDeferred until
+	// Route.Src is assigned to a local interface, as the kernel rejects a
+	// route whose preferred source is not, e.g. pass netlink.AddrList.
+	SrcAddrList addrLister
 }
 
 type ruleAdder func(rule *netlink.Rule) error
@@ -67,17 +189,65 @@ type IPRuleConfig struct {
 	RuleAdd  ruleAdder
 	RuleDel  ruleDeler
 	RuleList ruleLister
+	// StalePriorities lists priorities the rule was previously installed at.
+	// Copies of the rule at these priorities are removed, leaving Rule.Priority.
+	StalePriorities []int
+	// VerifyAfterApply re-lists the rules after adding the rule and fails if
+	// it is not there.
+	VerifyAfterApply bool
+	// TableRouteList, when set, makes the rule conditional on its table: the
+	// rule is removed while the table holds no route of the rule's family, so
+	// traffic is not sent to an empty table, and added back once it does.
+	TableRouteList routeLister
+	// ConflictPolicy decides what Ensure(true) does about foreign rules at
+	// Rule.Priority. The default, ConflictCoexist, adds the rule next to them.
+	ConflictPolicy ConflictPolicy
 }
 
 // IPTablesRuleSpec defines the config for ip table rule
 type IPTablesRuleSpec []string
 
+// ownershipCommentPrefix starts the comment WithComment adds, marking a rule in
+// a default chain as owned by netd.
+const ownershipCommentPrefix = "netd: "
+
+// legacyOwnershipComments are the comments netd marked its own rules in
+// default chains with before ownershipCommentPrefix existed.
+var legacyOwnershipComments = map[string]bool{
+	policyRoutingPreRoutingComment:  true,
+	policyRoutingPostRoutingComment: true,
+}
+
+// WithComment returns the spec followed by
+// "-m comment --comment netd: <comment>", which marks a rule in a default
+// chain as owned by netd.
+func (s IPTablesRuleSpec) WithComment(comment string) IPTablesRuleSpec {
+	spec := append(IPTablesRuleSpec{}, s...)
+	return append(spec, "-m", "comment", "--comment", ownershipCommentPrefix+comment)
+}
+
+// hasOwnershipMarker reports whether the spec carries a comment added by
+// WithComment, or one of the legacy comments of netd's own rules.
+func hasOwnershipMarker(s IPTablesRuleSpec) bool {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == "--comment" && (strings.HasPrefix(s[i+1], ownershipCommentPrefix) || legacyOwnershipComments[s[i+1]]) {
+			return true
+		}
+	}
+	return false
+}
+
 type iptabler interface {
 	NewChain(table, chain string) error
 	ClearChain(table, chain string) error
 	DeleteChain(table, chain string) error
+	ChainExists(table, chain string) (bool, error)
+	Exists(table, chain string, rulespec ...string) (bool, error)
+	Insert(table, chain string, pos int, rulespec ...string) error
+	Append(table, chain string, rulespec ...string) error
 	AppendUnique(table, chain string, rulespec ...string) error
 	Delete(table, chain string, rulespec ...string) error
+	List(table, chain string) ([]string, error)
 }
 
 // IPTablesChainSpec defines iptable chain
@@ -92,6 +262,12 @@ type IPTablesRuleConfig struct {
 	Spec      IPTablesChainSpec
 	RuleSpecs []IPTablesRuleSpec
 	IPT       iptabler
+	// Ordered keeps the netd-owned rules in RuleSpecs order, so first-match
+	// semantics are deterministic. Each Ensure(true) lists the chain and only
+	// rewrites the rules when their order differs. RuleSpecs must then be
+	// written as iptables -S prints them, up to the order of the options, the
+	// prefix length of addresses and the match module implied by -p.
+	Ordered bool
 }
 
 var ipt *iptables.IPTables
@@ -105,51 +281,219 @@ func init() {
 
 // Ensure SysctlConfig
 func (s SysctlConfig) Ensure(enabled bool) error {
+	_, err := s.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged SysctlConfig. The sysctl is only written when its current
+// value differs.
+func (s SysctlConfig) EnsureChanged(enabled bool) (bool, error) {
+	if s.MinKernel != "" {
+		supported, err := s.kernelSupported()
+		if err != nil || !supported {
+			return false, err
+		}
+	}
 	var value string
 	if enabled {
 		value = s.Value
 	} else {
 		value = s.DefaultValue
 	}
-	_, err := s.SysctlFunc(s.Key, value)
-	return err
+	// A failed read falls through to the write, which reports the error.
+	current, err := s.SysctlFunc(s.Key)
+	current = strings.TrimSpace(current)
+	if err == nil && current == value {
+		return false, nil
+	}
+	if _, err = s.SysctlFunc(s.Key, value); err != nil {
+		return false, err
+	}
+	diffLogf("sysctl %s %s->%s", s.Key, current, value)
+	return true, nil
+}
+
+func (s SysctlConfig) kernelSupported() (bool, error) {
+	min, err := ParseKernelVersion(s.MinKernel)
+	if err != nil {
+		return false, err
+	}
+	versionFunc := s.KernelVersionFunc
+	if versionFunc == nil {
+		versionFunc = CurrentKernelVersion
+	}
+	current, err := versionFunc()
+	if err != nil {
+		return false, err
+	}
+	if !current.AtLeast(min) {
+		glog.V(2).Infof("skipping sysctl %s: unsupported on kernel %v, requires %v", s.Key, current, min)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Dynamic IPRouteConfig
+func (r IPRouteConfig) Dynamic() bool {
+	return r.GatewayResolver != nil || r.LinkName != "" || r.SrcAddrList != nil
 }
 
 // Ensure IPRouteConfig
 func (r IPRouteConfig) Ensure(enabled bool) error {
+	_, err := r.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged IPRouteConfig
+func (r IPRouteConfig) EnsureChanged(enabled bool) (bool, error) {
+	if r.VerifyAfterApply && r.RouteList == nil {
+		return false, fmt.Errorf("VerifyAfterApply requires RouteList for route %v", r.Route)
+	}
+	if r.KeepForeignRoutes && r.RouteList == nil {
+		return false, fmt.Errorf("KeepForeignRoutes requires RouteList for route %v", r.Route)
+	}
+	if r.GatewayResolver != nil {
+		if r.RouteList == nil || r.RouteReplace == nil {
+			return false, fmt.Errorf("GatewayResolver requires RouteList and RouteReplace for route %v", r.Route)
+		}
+		r.Route.Gw = nil
+		if enabled {
+			gw, err := r.GatewayResolver()
+			if err != nil {
+				return false, fmt.Errorf("failed to resolve the gateway of route %v: %w", r.Route.Dst, err)
+			}
+			r.Route.Gw = gw
+		}
+	}
+	if enabled && r.SrcAddrList != nil && r.Route.Src != nil {
+		local, err := isLocalAddr(r.Route.Src, r.SrcAddrList)
+		if err != nil {
+			return false, err
+		}
+		if !local {
+			return false, fmt.Errorf("%w: source address %s of route %v is not assigned locally", ErrDeferred, r.Route.Src, r.Route.Dst)
+		}
+	}
+	var relinked bool
+	if r.LinkName != "" {
+		resolved, stale, err := r.resolveLink()
+		if err != nil {
+			var notFound netlink.LinkNotFoundError
+			if !enabled && errors.As(err, &notFound) {
+				// The kernel removed the route together with the link.
+				return false, nil
+			}
+			return false, err
+		}
+		r = resolved
+		if enabled && stale != 0 {
+			if relinked, err = r.deleteStale(stale); err != nil {
+				return false, err
+			}
+		}
+		if r.linkIndex != nil {
+			r.linkIndex.Store(int64(r.Route.LinkIndex))
+		}
+	}
+	if r.RouteList != nil {
+		changed, err := r.ensureListed(enabled)
+		if err == nil && changed && enabled && r.VerifyAfterApply {
+			err = r.verify()
+		}
+		return changed || relinked, err
+	}
 	var err error
 	if enabled {
 		err = r.RouteAdd(&r.Route)
 		if os.IsExist(err) {
-			err = nil
+			return relinked, nil
+		}
+		metrics.RecordNetlinkError("route_add", err)
+		if err == nil {
+			diffLogf("added ip route %s", describeRoute(r.Route))
+		}
+	} else if err = r.RouteDel(&r.Route); err != nil && errors.Is(err, syscall.ESRCH) {
+		return false, nil
+	} else {
+		metrics.RecordNetlinkError("route_del", err)
+		if err == nil {
+			diffLogf("deleted ip route %s", describeRoute(r.Route))
 		}
-	} else if err = r.RouteDel(&r.Route); err != nil && err.(syscall.Errno) == syscall.ESRCH {
-		err = nil
 	}
 
-	return err
+	return err == nil || relinked, err
+}
+
+// Dynamic IPRuleConfig
+func (r IPRuleConfig) Dynamic() bool {
+	return r.TableRouteList != nil
 }
 
 // Ensure IPRuleConfig
 func (r IPRuleConfig) Ensure(enabled bool) error {
-	if enabled {
-		return r.ensureHelper(1)
+	_, err := r.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged IPRuleConfig
+func (r IPRuleConfig) EnsureChanged(enabled bool) (bool, error) {
+	if err := checkRuleTable(r.Rule); err != nil {
+		return false, err
+	}
+	if enabled && r.TableRouteList != nil {
+		empty, err := r.tableEmpty()
+		if err != nil {
+			return false, err
+		}
+		if empty {
+			glog.V(2).Infof("table %d is empty, removing ip rule %v", r.Rule.Table, r.Rule)
+			enabled = false
+		}
+	}
+	if !enabled {
+		return r.ensureHelper(0)
+	}
+	resolved, err := r.resolveConflicts()
+	if err != nil {
+		return resolved, err
 	}
-	return r.ensureHelper(0)
+	changed, err := r.ensureHelper(1)
+	changed = changed || resolved
+	if err == nil && changed && r.VerifyAfterApply {
+		err = r.verify()
+	}
+	return changed, err
 }
 
-func (r IPRuleConfig) ensureHelper(ensureCount int) error {
+// verify confirms the rule is listed by the kernel after it was added.
+func (r IPRuleConfig) verify() error {
+	count, err := r.count()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("ip rule %v is missing after it was added", r.Rule)
+	}
+	return nil
+}
+
+func (r IPRuleConfig) ensureHelper(ensureCount int) (bool, error) {
 	var err error
+	changed := false
 	ruleCount, err := r.count()
 	if err != nil {
 		glog.Errorf("failed to get IP rule count for rule: %v, error: %v", r.Rule, err)
-		return err
+		return false, err
 	}
 
 	for ruleCount != ensureCount {
 		if ruleCount > ensureCount {
 			if err = r.RuleDel(&r.Rule); err != nil {
+				metrics.RecordNetlinkError("rule_del", err)
 				glog.Errorf("failed to delete duplicated ip rule: %v, error: %v", r.Rule, err)
+			} else {
+				diffLogf("deleted ip rule %s", describeRule(r.Rule))
+				changed = true
 			}
 			ruleCount--
 		} else {
@@ -158,79 +502,421 @@ func (r IPRuleConfig) ensureHelper(ensureCount int) error {
 				if os.IsExist(err) {
 					err = nil
 				} else {
+					metrics.RecordNetlinkError("rule_add", err)
 					glog.Errorf("failed to add ip rule: %v, error: %v", r.Rule, err)
 				}
+			} else {
+				diffLogf("added ip rule %s", describeRule(r.Rule))
+				changed = true
 			}
 			ruleCount++
 		}
 	}
+	staleChanged, serr := r.deleteStale()
+	if serr != nil {
+		return changed, serr
+	}
+	return changed || staleChanged, err
+}
+
+// deleteStale removes copies of the rule installed at one of StalePriorities.
+// Each listed entry is deleted as-is so the kernel cannot pick the copy at
+// the desired priority instead.
+func (r IPRuleConfig) deleteStale() (bool, error) {
+	if len(r.StalePriorities) == 0 {
+		return false, nil
+	}
+	rules, err := r.listRules()
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for _, rule := range rules {
+		if !r.isStale(rule) {
+			continue
+		}
+		if err = r.RuleDel(&rule); err != nil {
+			metrics.RecordNetlinkError("rule_del", err)
+			glog.Errorf("failed to delete ip rule %v at stale priority %d: %v", r.Rule, rule.Priority, err)
+			return changed, err
+		}
+		diffLogf("deleted ip rule %s at stale priority", describeRule(rule))
+		changed = true
+	}
+	return changed, nil
+}
+
+func (r IPRuleConfig) isStale(rule netlink.Rule) bool {
+	for _, p := range r.StalePriorities {
+		if p == r.Rule.Priority || rule.Priority != p {
+			continue
+		}
+		rule.Priority = r.Rule.Priority
+		return ruleEqual(rule, r.Rule)
+	}
+	return false
+}
+
+// MigratePriority moves the rule from priority from to priority to. The rule is
+// added at the new priority and confirmed present before the copies at the old
+// priority are removed, so a matching rule exists throughout the migration.
+func (r IPRuleConfig) MigratePriority(from, to int) error {
+	target := r
+	target.Rule.Priority = to
+	target.StalePriorities = nil
+	if _, err := target.ensureHelper(1); err != nil {
+		return err
+	}
+	count, err := target.count()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("ip rule %v is not present at priority %d after adding it", r.Rule, to)
+	}
+
+	old := r
+	old.Rule.Priority = from
+	old.StalePriorities = nil
+	_, err = old.ensureHelper(0)
 	return err
 }
 
+// ruleListAttempts bounds how often a rule dump interrupted by concurrent
+// changes is retried before the error is returned.
+const ruleListAttempts = 3
+
+// listRules lists the rules of the rule's family, retrying when RuleList
+// reports that the dump was interrupted (EINTR) because the rules changed
+// while it ran. The vendored netlink ignores the kernel's NLM_F_DUMP_INTR
+// flag and returns the possibly incomplete dump without an error, so the
+// retry only fires once netlink is upgraded to a version reporting it as an
+// error matching EINTR. Until then, a rule missing from an interrupted dump
+// makes that reconcile's add fail with EEXIST, and the next dump finds it.
+func (r IPRuleConfig) listRules() ([]netlink.Rule, error) {
+	var rules []netlink.Rule
+	var err error
+	for i := 0; i < ruleListAttempts; i++ {
+		if rules, err = r.RuleList(ruleFamily(r.Rule)); !errors.Is(err, unix.EINTR) {
+			metrics.RecordNetlinkError("rule_list", err)
+			return rules, err
+		}
+		glog.Warningf("ip rule dump was interrupted, retrying (attempt %d/%d)", i+1, ruleListAttempts)
+	}
+	metrics.RecordNetlinkError("rule_list", err)
+	return nil, err
+}
+
+// ruleFamily returns the rule's Family, or the family of its Src or Dst when
+// unset. Rules matching neither default to IPv4.
+func ruleFamily(rule netlink.Rule) int {
+	switch {
+	case rule.Family != 0:
+		return rule.Family
+	case rule.Src != nil && rule.Src.IP.To4() == nil:
+		return unix.AF_INET6
+	case rule.Dst != nil && rule.Dst.IP.To4() == nil:
+		return unix.AF_INET6
+	}
+	return unix.AF_INET
+}
+
 func (r IPRuleConfig) count() (int, error) {
-	rules, err := r.RuleList(unix.AF_INET)
+	rules, err := r.listRules()
 	if err != nil {
 		return 0, err
 	}
 	count := 0
 	for _, rule := range rules {
-		if rule == r.Rule {
+		if ruleMatches(r.Rule, rule) {
 			count++
 		}
 	}
 	return count, nil
 }
 
-func (c IPTablesChainSpec) ensure(enabled bool) error {
+// ruleMatches reports whether got, as listed from the kernel, is the rule
+// want. A negative want.Priority matches any priority.
+func ruleMatches(want, got netlink.Rule) bool {
+	if want.Priority < 0 {
+		got.Priority = want.Priority
+	}
+	return ruleEqual(want, got)
+}
+
+// ruleEqual compares two rules by value, including the contents of their
+// pointer fields. Family is ignored since rules are listed per family and
+// netlink does not report it.
+func ruleEqual(a, b netlink.Rule) bool {
+	if !ipNetEqual(a.Src, b.Src) || !ipNetEqual(a.Dst, b.Dst) {
+		return false
+	}
+	if !portRangeEqual(a.Dport, b.Dport) || !portRangeEqual(a.Sport, b.Sport) {
+		return false
+	}
+	a.Src, a.Dst, a.Dport, a.Sport, a.Family = nil, nil, nil, nil, 0
+	b.Src, b.Dst, b.Dport, b.Sport, b.Family = nil, nil, nil, nil, 0
+	return a == b
+}
+
+func portRangeEqual(a, b *netlink.RulePortRange) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (c IPTablesChainSpec) ensure(enabled bool) (bool, error) {
 	var err error
+	if !iptablesTables[c.TableName] {
+		return false, fmt.Errorf("unsupported iptables table %q for chain %s", c.TableName, c.ChainName)
+	}
+	exists, err := c.IPT.ChainExists(c.TableName, c.ChainName)
+	if err != nil {
+		return false, err
+	}
 	if enabled {
+		if exists {
+			return false, nil
+		}
 		if err = c.IPT.NewChain(c.TableName, c.ChainName); err != nil {
 			if eerr, eok := err.(*iptables.Error); !eok || eerr.ExitStatus() != 1 {
-				return err
+				return false, err
 			}
 		}
-	} else {
-		if !c.IsDefaultChain {
-			err = c.IPT.ClearChain(c.TableName, c.ChainName)
-			if err != nil {
-				glog.Errorf("failed to clean chain %s in table %s: %v", c.TableName, c.ChainName, err)
-				return err
+		diffLogf("created iptables chain %s in table %s", c.ChainName, c.TableName)
+		return true, nil
+	}
+	if c.IsDefaultChain || !exists {
+		return false, nil
+	}
+	err = c.IPT.ClearChain(c.TableName, c.ChainName)
+	if err != nil {
+		glog.Errorf("failed to clean chain %s in table %s: %v", c.TableName, c.ChainName, err)
+		return false, err
+	}
+	if err = c.IPT.DeleteChain(c.TableName, c.ChainName); err != nil {
+		if eerr, eok := err.(*iptables.Error); !eok || eerr.ExitStatus() != 1 {
+			glog.Errorf("failed to delete chain %s in table %s: %v", c.TableName, c.ChainName, err)
+			return false, err
+		}
+	}
+	diffLogf("deleted iptables chain %s in table %s", c.ChainName, c.TableName)
+	return true, nil
+}
+
+// ensureOrdered lists the chain and, unless the netd-owned rules already
+// appear in RuleSpecs order, rewrites them and reports a change. A chain netd
+// created is owned entirely, so it is cleared and refilled. In a default chain
+// only the rules matching RuleSpecs are netd's: fresh copies are appended after
+// every other rule before the old ones are deleted, so the chain never misses
+// one of them.
+func (r IPTablesRuleConfig) ensureOrdered() (bool, error) {
+	table, chain := r.Spec.TableName, r.Spec.ChainName
+	if r.Spec.IsDefaultChain {
+		for _, rs := range r.RuleSpecs {
+			if !hasOwnershipMarker(rs) {
+				return false, fmt.Errorf("ordered rule %v in default chain %s carries no comment marking it as netd's", rs, chain)
 			}
-			if err = c.IPT.DeleteChain(c.TableName, c.ChainName); err != nil {
-				if eerr, eok := err.(*iptables.Error); !eok || eerr.ExitStatus() != 1 {
-					glog.Errorf("failed to delete chain %s in table %s: %v", c.TableName, c.ChainName, err)
-					return err
+		}
+	}
+	want := make([]string, len(r.RuleSpecs))
+	wanted := make(map[string]bool)
+	for i, rs := range r.RuleSpecs {
+		want[i] = canonicalRuleSpec(rs)
+		wanted[want[i]] = true
+	}
+	rules, err := r.IPT.List(table, chain)
+	if err != nil {
+		return false, err
+	}
+	var got []string
+	old := make(map[string]int)
+	for _, rule := range rules {
+		rs := splitRuleSpec(rule)
+		if len(rs) < 2 || rs[0] != "-A" || rs[1] != chain {
+			continue
+		}
+		c := canonicalRuleSpec(rs[2:])
+		if r.Spec.IsDefaultChain && !wanted[c] {
+			continue
+		}
+		got = append(got, c)
+		old[c]++
+	}
+	if slices.Equal(got, want) {
+		return false, nil
+	}
+	// A rule iptables finds but the listing did not match would be missed
+	// below, and rewritten on every reconcile.
+	for i, rs := range r.RuleSpecs {
+		if old[want[i]] > 0 {
+			continue
+		}
+		if exists, err := r.IPT.Exists(table, chain, rs...); err != nil {
+			return false, err
+		} else if exists {
+			return false, fmt.Errorf("ordered rule %v in table %s chain %s is not listed as written, write it as iptables -S prints it", rs, table, chain)
+		}
+	}
+
+	if !r.Spec.IsDefaultChain {
+		if err := r.IPT.ClearChain(table, chain); err != nil {
+			return false, err
+		}
+	}
+	for _, rs := range r.RuleSpecs {
+		if err := r.IPT.Append(table, chain, rs...); err != nil {
+			glog.Errorf("failed to append rule %v in table %s chain %s: %v", rs, table, chain, err)
+			return true, err
+		}
+	}
+	if r.Spec.IsDefaultChain {
+		// iptables deletes the first matching rule, which is the old copy.
+		for i, rs := range r.RuleSpecs {
+			for ; old[want[i]] > 0; old[want[i]]-- {
+				if err := r.IPT.Delete(table, chain, rs...); err != nil {
+					return true, err
 				}
 			}
 		}
 	}
-	return nil
+	diffLogf("rewrote %d ordered iptables rules in table %s chain %s", len(r.RuleSpecs), table, chain)
+	return true, nil
+}
+
+// ruleSpecLongFlags maps the long forms of the options iptables -S prints in
+// their short form.
+var ruleSpecLongFlags = map[string]string{
+	"--source":        "-s",
+	"--destination":   "-d",
+	"--protocol":      "-p",
+	"--in-interface":  "-i",
+	"--out-interface": "-o",
+	"--match":         "-m",
+	"--jump":          "-j",
+	"--goto":          "-g",
+}
+
+// canonicalRuleSpec returns a form of rs that is the same for a rule spec and
+// the rule as listed by iptables -S: options are compared regardless of their
+// order, addresses as networks, and the match module -p implies is dropped.
+func canonicalRuleSpec(rs []string) string {
+	var options [][]string
+	proto := ""
+	for i := 0; i < len(rs); {
+		j := i
+		if rs[j] == "!" && j+1 < len(rs) {
+			j++
+		}
+		option := append([]string{}, rs[i:j]...)
+		flag := rs[j]
+		if short, ok := ruleSpecLongFlags[flag]; ok {
+			flag = short
+		}
+		option = append(option, flag)
+		for j++; j < len(rs) && rs[j] != "!" && !strings.HasPrefix(rs[j], "-"); j++ {
+			arg := rs[j]
+			switch flag {
+			case "-s", "-d":
+				arg = canonicalAddress(arg)
+			case "-p":
+				proto = arg
+			}
+			option = append(option, arg)
+		}
+		options = append(options, option)
+		i = j
+	}
+	var parts []string
+	for _, option := range options {
+		part := strings.Join(option, " ")
+		if proto != "" && part == "-m "+proto {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+// canonicalAddress returns addr, an address or network, as the network
+// iptables prints for it.
+func canonicalAddress(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil {
+		if ip.To4() != nil {
+			return ip.String() + "/32"
+		}
+		return ip.String() + "/128"
+	}
+	if _, ipNet, err := net.ParseCIDR(addr); err == nil {
+		return ipNet.String()
+	}
+	return addr
+}
+
+// Chains IPTablesRuleConfig
+func (r IPTablesRuleConfig) Chains() []IPTablesChainSpec {
+	return []IPTablesChainSpec{r.Spec}
 }
 
 // Ensure IPTablesRuleConfig
 func (r IPTablesRuleConfig) Ensure(enabled bool) error {
-	var err error
-	if err = r.Spec.ensure(enabled); err != nil {
-		return err
+	_, err := r.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged IPTablesRuleConfig
+func (r IPTablesRuleConfig) EnsureChanged(enabled bool) (bool, error) {
+	changed, err := r.Spec.ensure(enabled)
+	if err != nil {
+		return changed, err
+	}
+	if enabled && r.Ordered {
+		orderedChanged, err := r.ensureOrdered()
+		return changed || orderedChanged, err
 	}
 	if enabled {
 		for _, rs := range r.RuleSpecs {
+			exists, err := r.IPT.Exists(r.Spec.TableName, r.Spec.ChainName, rs...)
+			if err != nil {
+				return changed, err
+			}
+			if exists {
+				continue
+			}
 			err = r.IPT.AppendUnique(r.Spec.TableName, r.Spec.ChainName, rs...)
 			if err != nil {
 				glog.Errorf("failed to append rule %v in table %s chain %s: %v", rs, r.Spec.TableName, r.Spec.ChainName, err)
-				return err
+				return changed, err
 			}
+			diffLogf("appended iptables rule -t %s -A %s %s", r.Spec.TableName, r.Spec.ChainName, strings.Join(rs, " "))
+			changed = true
 		}
 	} else if r.Spec.IsDefaultChain {
 		for _, rs := range r.RuleSpecs {
+			// A rule without netd's comment may be an identical rule that
+			// belongs to the system, so it is never deleted from a default chain.
+			if !hasOwnershipMarker(rs) {
+				glog.Warningf("not deleting rule %v from default chain %s in table %s: it carries no comment marking it as netd's", rs, r.Spec.ChainName, r.Spec.TableName)
+				continue
+			}
+			if exists, err := r.IPT.Exists(r.Spec.TableName, r.Spec.ChainName, rs...); err == nil && !exists {
+				continue
+			}
 			if err := r.IPT.Delete(r.Spec.TableName, r.Spec.ChainName, rs...); err != nil {
-				if eerr, eok := err.(*iptables.Error); !eok || eerr.ExitStatus() != 2 {
-					if !strings.Contains(eerr.Error(), "No chain/target/match") {
-						return err
-					}
+				eerr, eok := err.(*iptables.Error)
+				if !eok {
+					return changed, err
+				}
+				if eerr.ExitStatus() != 2 && !strings.Contains(eerr.Error(), "No chain/target/match") {
+					return changed, err
 				}
+				continue
 			}
+			diffLogf("deleted iptables rule -t %s -A %s %s", r.Spec.TableName, r.Spec.ChainName, strings.Join(rs, " "))
+			changed = true
 		}
 	}
-	return nil
+	return changed, nil
 }
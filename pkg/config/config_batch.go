@@ -0,0 +1,140 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// EnsureBatched is like Ensure, but issues every route and rule call of the
+// Set on one netlink socket opened for the call, instead of opening and
+// closing a socket per call as the package-level netlink functions do, which
+// adds up on route-heavy Sets. rtnetlink has no transactions, so the changes
+// are still applied one by one and are not atomic. If the socket cannot be
+// opened, the Set is ensured per operation.
+func (s Set) EnsureBatched() error {
+	h, err := netlink.NewHandle(unix.NETLINK_ROUTE)
+	if err != nil {
+		glog.Warningf("failed to open a netlink socket for %s, ensuring per operation: %v", s.FeatureName, err)
+		return s.Ensure()
+	}
+	defer h.Close()
+	return s.WithNetlinkHandle(h).Ensure()
+}
+
+// WithNetlinkHandle returns a copy of s whose IPRouteConfigs and IPRuleConfigs,
+// including those inside composite configs, make their netlink calls on h.
+// Calls a config leaves unset, such as an optional RouteList, stay unset.
+func (s Set) WithNetlinkHandle(h *netlink.Handle) Set {
+	configs := make([]Config, len(s.Configs))
+	for i, c := range s.Configs {
+		configs[i] = bindHandle(c, h)
+	}
+	s.Configs = configs
+	return s
+}
+
+func bindHandle(c Config, h *netlink.Handle) Config {
+	switch c := c.(type) {
+	case IPRouteConfig:
+		return bindRouteHandle(c, h)
+	case IPRuleConfig:
+		return bindRuleHandle(c, h)
+	case DualStackRuleConfig:
+		c.V4, c.V6 = bindRuleHandle(c.V4, h), bindRuleHandle(c.V6, h)
+		return c
+	case FwmarkPolicyConfig:
+		c.Rule = bindRuleHandle(c.Rule, h)
+		return c
+	case MultipathRouteConfig:
+		return bindMultipathHandle(c, h)
+	case OifPolicyConfig:
+		c.Rule = bindRuleHandle(c.Rule, h)
+		routes := make([]IPRouteConfig, len(c.Routes))
+		for i, r := range c.Routes {
+			routes[i] = bindRouteHandle(r, h)
+		}
+		c.Routes = routes
+		return c
+	case NamedConfig:
+		c.Config = bindHandle(c.Config, h)
+		return c
+	case MetadataGatedConfig:
+		c.Config = bindHandle(c.Config, h)
+		return c
+	case KubeProxyModeGatedConfig:
+		c.Config = bindHandle(c.Config, h)
+		return c
+	case NodeReadyGatedConfig:
+		c.Config = bindHandle(c.Config, h)
+		return c
+	}
+	return c
+}
+
+func bindRouteHandle(r IPRouteConfig, h *netlink.Handle) IPRouteConfig {
+	if r.RouteAdd != nil {
+		r.RouteAdd = h.RouteAdd
+	}
+	if r.RouteDel != nil {
+		r.RouteDel = h.RouteDel
+	}
+	if r.RouteList != nil {
+		r.RouteList = h.RouteListFiltered
+	}
+	if r.RouteReplace != nil {
+		r.RouteReplace = h.RouteReplace
+	}
+	if r.LinkByName != nil {
+		r.LinkByName = h.LinkByName
+	}
+	return r
+}
+
+func bindMultipathHandle(m MultipathRouteConfig, h *netlink.Handle) MultipathRouteConfig {
+	if m.RouteList != nil {
+		m.RouteList = h.RouteListFiltered
+	}
+	if m.RouteReplace != nil {
+		m.RouteReplace = h.RouteReplace
+	}
+	if m.RouteDel != nil {
+		m.RouteDel = h.RouteDel
+	}
+	if m.LinkByIndex != nil {
+		m.LinkByIndex = h.LinkByIndex
+	}
+	return m
+}
+
+func bindRuleHandle(r IPRuleConfig, h *netlink.Handle) IPRuleConfig {
+	if r.RuleAdd != nil {
+		r.RuleAdd = h.RuleAdd
+	}
+	if r.RuleDel != nil {
+		r.RuleDel = h.RuleDel
+	}
+	if r.RuleList != nil {
+		r.RuleList = h.RuleList
+	}
+	if r.TableRouteList != nil {
+		r.TableRouteList = h.RouteListFiltered
+	}
+	return r
+}
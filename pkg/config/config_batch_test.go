@@ -0,0 +1,118 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+func TestWithNetlinkHandle(t *testing.T) {
+	h, err := netlink.NewHandle(unix.NETLINK_ROUTE)
+	if err != nil {
+		t.Skipf("cannot open a netlink socket: %v", err)
+	}
+	defer h.Close()
+
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	route := IPRouteConfig{Route: netlink.Route{Dst: dst}, RouteAdd: netlink.RouteAdd, RouteDel: netlink.RouteDel}
+	oif, err := NewOifPolicyConfig("eth1", 300, 31000, []netlink.Route{{Dst: dst}})
+	if err != nil {
+		t.Fatalf("NewOifPolicyConfig returned error: %v", err)
+	}
+	s := Set{FeatureName: "test", Configs: []Config{route, NamedConfig{Config: newRuleConfig(100), Name: "rule"}, oif}}
+
+	bound := s.WithNetlinkHandle(h)
+	r := bound.Configs[0].(IPRouteConfig)
+	if r.RouteAdd == nil || r.RouteDel == nil || r.RouteList != nil || r.RouteReplace != nil {
+		t.Errorf("only the calls the route sets should be bound, got %+v", r)
+	}
+	if rule := bound.Configs[1].(NamedConfig).Config.(IPRuleConfig); rule.RuleAdd == nil || rule.RuleList == nil {
+		t.Errorf("the rule inside NamedConfig should stay bound, got %+v", rule)
+	}
+	if o := bound.Configs[2].(OifPolicyConfig); o.Routes[0].RouteReplace == nil || &o.Routes[0] == &oif.Routes[0] {
+		t.Errorf("the oif routes should be bound on a copy, got %+v", o.Routes[0])
+	}
+	if s.Configs[0].(IPRouteConfig).RouteList != nil {
+		t.Error("WithNetlinkHandle should not modify the original Set")
+	}
+}
+
+// enterScratchNetns moves the calling goroutine's thread into a new network
+// namespace, which requires CAP_SYS_ADMIN and CAP_NET_ADMIN, and skips tb
+// otherwise. The returned func moves it back.
+func enterScratchNetns(tb testing.TB) func() {
+	tb.Helper()
+	runtime.LockOSThread()
+	origin, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		tb.Skipf("cannot get the current network namespace: %v", err)
+	}
+	scratch, err := netns.New()
+	if err != nil {
+		origin.Close()
+		runtime.UnlockOSThread()
+		tb.Skipf("cannot create a network namespace: %v", err)
+	}
+	return func() {
+		netns.Set(origin)
+		scratch.Close()
+		origin.Close()
+		runtime.UnlockOSThread()
+	}
+}
+
+// benchmarkRoutes ensures and removes n blackhole routes in a scratch network
+// namespace.
+func benchmarkRoutes(b *testing.B, n int, ensure func(Set) error) {
+	defer enterScratchNetns(b)()
+
+	s := Set{Enabled: true, FeatureName: "bench"}
+	for i := 0; i < n; i++ {
+		dst := &net.IPNet{IP: net.IPv4(10, byte(i>>8), byte(i), 0).To4(), Mask: net.CIDRMask(24, 32)}
+		s.Configs = append(s.Configs, IPRouteConfig{
+			Route:    netlink.Route{Dst: dst, Table: 100, Type: unix.RTN_BLACKHOLE},
+			RouteAdd: netlink.RouteAdd,
+			RouteDel: netlink.RouteDel,
+		})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Enabled = true
+		if err := ensure(s); err != nil {
+			b.Fatalf("failed to add routes: %v", err)
+		}
+		s.Enabled = false
+		if err := ensure(s); err != nil {
+			b.Fatalf("failed to delete routes: %v", err)
+		}
+	}
+}
+
+func BenchmarkEnsurePerOp(b *testing.B) {
+	benchmarkRoutes(b, 1000, Set.Ensure)
+}
+
+func BenchmarkEnsureBatched(b *testing.B) {
+	benchmarkRoutes(b, 1000, Set.EnsureBatched)
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"hash/fnv"
+)
+
+// canaryBuckets is the resolution of CanaryGate.Percent, 0.01%.
+const canaryBuckets = 10000
+
+// CanaryGate enables a Set on only Percent percent of the nodes, e.g. 5 to
+// roll a risky change out gradually. A node is picked by hashing its name
+// with the feature's, so the same nodes stay selected across restarts and as
+// Percent grows, while different features pick different nodes.
+type CanaryGate struct {
+	NodeName string
+	Percent  float64
+}
+
+// Selected reports whether the node is among the canaries of featureName.
+func (g CanaryGate) Selected(featureName string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(featureName))
+	h.Write([]byte{0})
+	h.Write([]byte(g.NodeName))
+	return float64(h.Sum64()%canaryBuckets) < g.Percent*canaryBuckets/100
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCanaryGateFraction(t *testing.T) {
+	const nodes = 10000
+	selected := func(percent float64) map[string]bool {
+		picked := make(map[string]bool)
+		for i := 0; i < nodes; i++ {
+			name := fmt.Sprintf("gke-cluster-pool-%d", i)
+			if (CanaryGate{NodeName: name, Percent: percent}).Selected("PolicyRouting") {
+				picked[name] = true
+			}
+		}
+		return picked
+	}
+
+	five := selected(5)
+	if n := len(five); n < 400 || n > 600 {
+		t.Errorf("5%% canary picked %d of %d nodes", n, nodes)
+	}
+	for name := range selected(5) {
+		if !five[name] {
+			t.Errorf("%s was not picked the first time", name)
+		}
+	}
+	// Growing the rollout keeps the earlier canaries.
+	twenty := selected(20)
+	for name := range five {
+		if !twenty[name] {
+			t.Errorf("%s left the canary when it grew to 20%%", name)
+		}
+	}
+	if n := len(selected(0)); n != 0 {
+		t.Errorf("0%% canary picked %d nodes", n)
+	}
+	if n := len(selected(100)); n != nodes {
+		t.Errorf("100%% canary picked %d of %d nodes", n, nodes)
+	}
+}
+
+func TestSetResolveCanary(t *testing.T) {
+	var log []string
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{recordingConfig{"a", &log, nil}}}
+	for _, tc := range []struct {
+		percent float64
+		enabled bool
+	}{
+		{100, true},
+		{0, false},
+	} {
+		s.Canary = &CanaryGate{NodeName: "node-1", Percent: tc.percent}
+		if resolved, ok := s.Resolve(); !ok || resolved.Enabled != tc.enabled {
+			t.Errorf("Resolve() at %v%% = %v, %v; want enabled %v", tc.percent, resolved.Enabled, ok, tc.enabled)
+		}
+	}
+	s.Ensure()
+	if len(log) != 1 || log[0] != "a:false" {
+		t.Errorf("a node outside the canary should remove the configs, got %v", log)
+	}
+}
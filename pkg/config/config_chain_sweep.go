@@ -0,0 +1,172 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// NetdChainPrefix marks the chains netd owns. Only chains starting with it,
+// and the policy routing chains predating it, are ever swept.
+const NetdChainPrefix = "NETD-"
+
+// legacyNetdChains are netd's chains named before NetdChainPrefix, by table.
+var legacyNetdChains = map[string]map[string]bool{
+	tableMangle: {gcpPreRoutingChain: true, gcpPostRoutingChain: true},
+}
+
+func netdOwnedChain(table, chain string) bool {
+	return strings.HasPrefix(chain, NetdChainPrefix) || legacyNetdChains[table][chain]
+}
+
+type chainSweeper interface {
+	iptabler
+	ListChains(table string) ([]string, error)
+	List(table, chain string) ([]string, error)
+}
+
+// SweepOrphanedChains deletes the chains netd owns that none of sets, enabled
+// or not, manages any more, e.g. left behind by a crash or a removed feature,
+// together with the jumps to them. Run it once at startup, before the sets
+// are ensured.
+func SweepOrphanedChains(sets []Set) error {
+	if ipt == nil {
+		return errors.New("iptables is not available")
+	}
+	return sweepOrphanedChains(ipt, []string{tableFilter, tableNAT, tableMangle, tableRaw}, sets)
+}
+
+func sweepOrphanedChains(ipt chainSweeper, tables []string, sets []Set) error {
+	managed := make(map[string]bool)
+	for _, s := range sets {
+		for _, c := range s.Configs {
+			for _, spec := range configChains(c) {
+				managed[spec.TableName+"/"+spec.ChainName] = true
+			}
+		}
+	}
+	var errs []error
+	for _, table := range tables {
+		chains, err := ipt.ListChains(table)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, chain := range chains {
+			if !netdOwnedChain(table, chain) || managed[table+"/"+chain] {
+				continue
+			}
+			glog.Infof("deleting orphaned iptables chain %s in table %s", chain, table)
+			if err := deleteChainAndJumps(ipt, table, chain, chains); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete orphaned chain %s in table %s: %w", chain, table, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteChainAndJumps removes the rules of the chains of table jumping to
+// chain, which the kernel requires before deleting it, then chain itself.
+func deleteChainAndJumps(ipt chainSweeper, table, chain string, chains []string) error {
+	for _, parent := range chains {
+		if parent == chain {
+			continue
+		}
+		rules, err := ipt.List(table, parent)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			rs := splitRuleSpec(rule)
+			if len(rs) < 3 || rs[0] != "-A" || !jumpsTo(rs[2:], chain) {
+				continue
+			}
+			if err := ipt.Delete(table, parent, rs[2:]...); err != nil {
+				return err
+			}
+			diffLogf("deleted iptables rule -t %s -A %s %s", table, parent, strings.Join(rs[2:], " "))
+		}
+	}
+	if err := ipt.ClearChain(table, chain); err != nil {
+		return err
+	}
+	if err := ipt.DeleteChain(table, chain); err != nil {
+		return err
+	}
+	diffLogf("deleted iptables chain %s in table %s", chain, table)
+	return nil
+}
+
+func jumpsTo(rs []string, chain string) bool {
+	for i := 0; i+1 < len(rs); i++ {
+		if (rs[i] == "-j" || rs[i] == "-g") && rs[i+1] == chain {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRuleSpec splits a rule as printed by iptables -S into its tokens,
+// unquoting double-quoted ones such as comments.
+func splitRuleSpec(rule string) []string {
+	var tokens []string
+	var token strings.Builder
+	inToken, quoted, escaped := false, false, false
+	for _, r := range rule {
+		switch {
+		case escaped:
+			token.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inToken = true, true
+		case r == '"':
+			quoted, inToken = !quoted, true
+		case r == ' ' && !quoted:
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// ChainOwner is implemented by configs that create iptables chains, and by
+// wrappers returning the chains of the config they wrap. SweepOrphanedChains
+// keeps every chain a config of its sets returns.
+type ChainOwner interface {
+	Chains() []IPTablesChainSpec
+}
+
+// configChains returns the chains of c, or nil if c creates none.
+func configChains(c Config) []IPTablesChainSpec {
+	if o, ok := c.(ChainOwner); ok {
+		return o.Chains()
+	}
+	return nil
+}
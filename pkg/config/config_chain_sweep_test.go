@@ -0,0 +1,124 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// fakeChainSweeper lists the chains of a FakeIPTable, which holds a single
+// table.
+type fakeChainSweeper struct {
+	FakeIPTable
+}
+
+func (f fakeChainSweeper) ListChains(string) ([]string, error) {
+	var chains []string
+	for chain := range f.iptCache {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+	return chains, nil
+}
+
+func TestSweepOrphanedChains(t *testing.T) {
+	ipt := fakeChainSweeper{FakeIPTable{iptCache: map[string][]string{
+		"PREROUTING": {
+			"-j NETD-ORPHAN -m comment --comment netd-orphan",
+			"-j NETD-KEEP -m comment --comment netd-keep",
+			"-j KUBE-SERVICES",
+		},
+		"NETD-ORPHAN":    {"-j ACCEPT"},
+		"NETD-KEEP":      {"-j ACCEPT"},
+		"KUBE-SERVICES":  {"-j RETURN"},
+		"GCP-PREROUTING": {"-j RETURN"},
+	}}}
+	sets := []Set{{
+		Enabled:     false,
+		FeatureName: "test",
+		Configs: []Config{NamedConfig{Config: IPTablesRuleConfig{
+			Spec: IPTablesChainSpec{TableName: tableMangle, ChainName: "NETD-KEEP"},
+		}}},
+	}}
+
+	if err := sweepOrphanedChains(ipt, []string{tableMangle}, sets); err != nil {
+		t.Fatalf("sweepOrphanedChains returned error: %v", err)
+	}
+	chains, _ := ipt.ListChains(tableMangle)
+	// GCP-PREROUTING is netd's but not managed by any of the sets.
+	if want := []string{"KUBE-SERVICES", "NETD-KEEP", "PREROUTING"}; !reflect.DeepEqual(chains, want) {
+		t.Errorf("chains after the sweep = %v, want %v", chains, want)
+	}
+	want := []string{"-j NETD-KEEP -m comment --comment netd-keep", "-j KUBE-SERVICES"}
+	if !reflect.DeepEqual(ipt.iptCache["PREROUTING"], want) {
+		t.Errorf("PREROUTING after the sweep = %v, want %v", ipt.iptCache["PREROUTING"], want)
+	}
+
+	// Sweeping again changes nothing.
+	if err := sweepOrphanedChains(ipt, []string{tableMangle}, sets); err != nil {
+		t.Fatalf("sweepOrphanedChains returned error: %v", err)
+	}
+	if chains2, _ := ipt.ListChains(tableMangle); !reflect.DeepEqual(chains, chains2) {
+		t.Errorf("a second sweep changed the chains to %v", chains2)
+	}
+}
+
+func TestSweepKeepsWrappedChains(t *testing.T) {
+	ipt := fakeChainSweeper{FakeIPTable{iptCache: map[string][]string{
+		"PREROUTING":  {"-j NETD-eth0", "-j NETD-GATED"},
+		"NETD-eth0":   {"-j ACCEPT"},
+		"NETD-GATED":  {"-j ACCEPT"},
+		"NETD-ORPHAN": {"-j ACCEPT"},
+	}}}
+	routes := &fakeRouteTable{routes: []netlink.Route{{Gw: net.IPv4(10, 128, 0, 1), LinkIndex: 2}}}
+	sets := []Set{{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs: []Config{
+			NewPrimaryInterfaceConfig(newFakePrimaryDetector(routes), func(p PrimaryInterface) Config {
+				return IPTablesRuleConfig{Spec: IPTablesChainSpec{TableName: tableMangle, ChainName: NetdChainPrefix + p.Name}}
+			}),
+			NamedConfig{Config: MetadataGatedConfig{Config: IPTablesRuleConfig{
+				Spec: IPTablesChainSpec{TableName: tableMangle, ChainName: "NETD-GATED"},
+			}}},
+		},
+	}}
+
+	if err := sweepOrphanedChains(ipt, []string{tableMangle}, sets); err != nil {
+		t.Fatalf("sweepOrphanedChains returned error: %v", err)
+	}
+	chains, _ := ipt.ListChains(tableMangle)
+	if want := []string{"NETD-GATED", "NETD-eth0", "PREROUTING"}; !reflect.DeepEqual(chains, want) {
+		t.Errorf("chains after the sweep = %v, want %v", chains, want)
+	}
+	if want := []string{"-j NETD-eth0", "-j NETD-GATED"}; !reflect.DeepEqual(ipt.iptCache["PREROUTING"], want) {
+		t.Errorf("PREROUTING after the sweep = %v, want %v", ipt.iptCache["PREROUTING"], want)
+	}
+}
+
+func TestSplitRuleSpec(t *testing.T) {
+	got := splitRuleSpec(`-A PREROUTING -j NETD-X -m comment --comment "netd \"quoted\" jump"`)
+	want := []string{"-A", "PREROUTING", "-j", "NETD-X", "-m", "comment", "--comment", `netd "quoted" jump`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitRuleSpec = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sync"
+)
+
+// ChangeTracker remembers the configs each Set applied successfully, so that
+// a Set using it only ensures the configs whose desired state changed since.
+// Drift made outside netd is not noticed until Reset, so callers should Reset
+// periodically to force a full reconcile.
+type ChangeTracker struct {
+	mu      sync.Mutex
+	applied map[string]map[uint64]bool
+}
+
+// NewChangeTracker creates an empty ChangeTracker.
+func NewChangeTracker() *ChangeTracker {
+	return &ChangeTracker{applied: make(map[string]map[uint64]bool)}
+}
+
+// Reset forgets every applied config, so the next Ensure of each Set
+// re-applies all of its configs.
+func (t *ChangeTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.applied = make(map[string]map[uint64]bool)
+}
+
+func (t *ChangeTracker) unchanged(featureName string, h uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.applied[featureName][h]
+}
+
+// record replaces the configs applied for featureName, dropping the ones no
+// longer part of the Set.
+func (t *ChangeTracker) record(featureName string, applied map[uint64]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.applied[featureName] = applied
+}
+
+// DynamicConfig is implemented by configs whose desired state is computed on
+// every Ensure, e.g. by a resolver func or from the links or routes present,
+// so it can change while configHash stays the same. A Set with a Tracker
+// ensures a config whose Dynamic returns true on every reconcile.
+type DynamicConfig interface {
+	Config
+	Dynamic() bool
+}
+
+// isDynamic reports whether c, or the config a NamedConfig wraps, is dynamic.
+func isDynamic(c Config) bool {
+	if n, ok := c.(NamedConfig); ok {
+		return isDynamic(n.Config)
+	}
+	d, ok := c.(DynamicConfig)
+	return ok && d.Dynamic()
+}
+
+// configHash hashes the desired state of c: its type, every field except
+// functions, and enabled. Functions are the injected netlink and iptables
+// calls, but also resolvers and gates whose result it cannot see, so configs
+// using those implement DynamicConfig. Pointers are followed, hashing the
+// state they point at when the Set runs.
+func configHash(c Config, enabled bool) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%T %v ", c, enabled)
+	hashValue(h, reflect.ValueOf(c), make(map[uintptr]bool))
+	return h.Sum64()
+}
+
+func hashValue(h hash.Hash64, v reflect.Value, visited map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Invalid, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		h.Write([]byte{0})
+	case reflect.Pointer:
+		if v.IsNil() {
+			h.Write([]byte{0})
+			return
+		}
+		if visited[v.Pointer()] {
+			return
+		}
+		visited[v.Pointer()] = true
+		hashValue(h, v.Elem(), visited)
+	case reflect.Interface:
+		if v.IsNil() {
+			h.Write([]byte{0})
+			return
+		}
+		fmt.Fprintf(h, "%v ", v.Elem().Type())
+		hashValue(h, v.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			hashValue(h, v.Field(i), visited)
+		}
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(h, "[%d ", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i), visited)
+		}
+	case reflect.Map:
+		// Map order is random, so combine the entries order-independently.
+		var sum uint64
+		iter := v.MapRange()
+		for iter.Next() {
+			e := fnv.New64a()
+			hashValue(e, iter.Key(), visited)
+			hashValue(e, iter.Value(), visited)
+			sum += e.Sum64()
+		}
+		fmt.Fprintf(h, "{%d %d ", v.Len(), sum)
+	case reflect.Bool:
+		fmt.Fprintf(h, "%v ", v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(h, "%d ", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(h, "%d ", v.Uint())
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprintf(h, "%v ", v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprintf(h, "%v ", v.Complex())
+	case reflect.String:
+		fmt.Fprintf(h, "%q ", v.String())
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// funcConfig hands Ensure to a function, which unlike the state a
+// recordingConfig points to is left out of its hash.
+type funcConfig struct {
+	name   string
+	ensure func(name string, enabled bool) error
+}
+
+func (c funcConfig) Ensure(enabled bool) error {
+	return c.ensure(c.name, enabled)
+}
+
+func TestSetEnsureChangeTracker(t *testing.T) {
+	var log []string
+	record := func(name string, enabled bool) error {
+		return recordingConfig{name, &log, nil}.Ensure(enabled)
+	}
+	fail := func(name string, enabled bool) error {
+		record(name, enabled)
+		return errors.New("failed")
+	}
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs:     []Config{funcConfig{"a", record}, funcConfig{"failing", fail}},
+		Tracker:     NewChangeTracker(),
+	}
+	var summary ReconcileSummary
+	s.OnReconcileComplete = func(rs ReconcileSummary) { summary = rs }
+
+	s.Ensure()
+	s.Ensure()
+	// Failed configs are retried, the applied one is skipped.
+	want := []string{"a:true", "failing:true", "failing:true"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v, want %v", log, want)
+	}
+	if summary.Unchanged != 1 || summary.Failed != 1 {
+		t.Errorf("summary = %+v, want 1 unchanged and 1 failed", summary)
+	}
+
+	// A changed config is ensured.
+	log = nil
+	s.Configs = []Config{funcConfig{"b", record}}
+	s.Ensure()
+	if want := []string{"b:true"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v, want %v", log, want)
+	}
+
+	// A full reconcile re-applies every config.
+	log = nil
+	s.Tracker.Reset()
+	s.Ensure()
+	s.Ensure()
+	if want := []string{"b:true"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v after Reset, want %v", log, want)
+	}
+
+	// So does disabling the Set.
+	log = nil
+	s.Enabled = false
+	s.Ensure()
+	if want := []string{"b:false"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v after disabling, want %v", log, want)
+	}
+}
+
+func TestSetEnsureChangeTrackerDynamic(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("0.0.0.0/0")
+	fake := &fakeRouteTable{}
+	route := fake.config(netlink.Route{Dst: dst, LinkIndex: 2, Table: 400, Protocol: RouteProtocolNetd})
+	route.RouteReplace = fake.replace
+	gw := net.IPv4(10, 0, 0, 10)
+	route.GatewayResolver = func() (net.IP, error) { return gw, nil }
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs:     []Config{NamedConfig{Config: route, Name: "gateway"}},
+		Tracker:     NewChangeTracker(),
+	}
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+
+	// The resolver's answer is not part of the hash, so the route must be
+	// ensured again rather than skipped as unchanged.
+	gw = net.IPv4(10, 0, 0, 20)
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Gw.Equal(gw) {
+		t.Errorf("the route should follow the resolved gateway %v, got %v", gw, fake.routes)
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.0.0.0/24")
+	_, other, _ := net.ParseCIDR("10.0.1.0/24")
+	routes := &fakeRouteTable{}
+	route := func(dst *net.IPNet) Config {
+		return routes.config(netlink.Route{Dst: dst, Table: 1})
+	}
+
+	// Fresh closures and pointers to equal values hash the same.
+	if configHash(route(dst), true) != configHash(routes.config(netlink.Route{Dst: &net.IPNet{IP: dst.IP, Mask: dst.Mask}, Table: 1}), true) {
+		t.Error("equal routes should hash the same")
+	}
+	if configHash(route(dst), true) == configHash(route(other), true) {
+		t.Error("routes to different destinations should hash differently")
+	}
+	if configHash(route(dst), true) == configHash(route(dst), false) {
+		t.Error("the enabled state should change the hash")
+	}
+	a := SysctlConfig{Key: "net.ipv4.ip_forward", Value: "1", DefaultValue: "0"}
+	b := a
+	b.Value = "0"
+	if configHash(a, true) == configHash(b, true) {
+		t.Error("sysctls with different values should hash differently")
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	sysctlConntrackMax     = "net.netfilter.nf_conntrack_max"
+	sysctlConntrackBuckets = "net.netfilter.nf_conntrack_buckets"
+)
+
+// ConntrackSysctlConfig sets nf_conntrack_buckets and nf_conntrack_max as a
+// pair, since a max lower than the hash size makes the kernel warn.
+type ConntrackSysctlConfig struct {
+	Max, Buckets               int
+	DefaultMax, DefaultBuckets int
+	SysctlFunc                 sysctler
+}
+
+// Ensure ConntrackSysctlConfig
+func (c ConntrackSysctlConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged ConntrackSysctlConfig
+func (c ConntrackSysctlConfig) EnsureChanged(enabled bool) (bool, error) {
+	max, buckets := c.Max, c.Buckets
+	if !enabled {
+		max, buckets = c.DefaultMax, c.DefaultBuckets
+	}
+	if err := validateConntrack(max, buckets); err != nil {
+		return false, err
+	}
+	// The hash size is written first so max never refers to a smaller table.
+	bucketsChanged, err := SysctlConfig{Key: sysctlConntrackBuckets, Value: strconv.Itoa(buckets), SysctlFunc: c.SysctlFunc}.EnsureChanged(true)
+	if err != nil {
+		return bucketsChanged, err
+	}
+	maxChanged, err := SysctlConfig{Key: sysctlConntrackMax, Value: strconv.Itoa(max), SysctlFunc: c.SysctlFunc}.EnsureChanged(true)
+	return bucketsChanged || maxChanged, err
+}
+
+func validateConntrack(max, buckets int) error {
+	if max <= 0 || buckets <= 0 {
+		return fmt.Errorf("%s (%d) and %s (%d) must be positive", sysctlConntrackMax, max, sysctlConntrackBuckets, buckets)
+	}
+	if max < buckets {
+		return fmt.Errorf("%s (%d) must not be lower than %s (%d)", sysctlConntrackMax, max, sysctlConntrackBuckets, buckets)
+	}
+	return nil
+}
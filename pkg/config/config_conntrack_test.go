@@ -0,0 +1,78 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+)
+
+func TestConntrackSysctlConfig(t *testing.T) {
+	var writes []string
+	mSysctl := make(map[string]string)
+	sysctlFunc := func(name string, params ...string) (string, error) {
+		if len(params) == 0 {
+			return mSysctl[name], nil
+		}
+		writes = append(writes, name)
+		mSysctl[name] = params[0]
+		return "", nil
+	}
+
+	c := ConntrackSysctlConfig{
+		Max:            262144,
+		Buckets:        65536,
+		DefaultMax:     131072,
+		DefaultBuckets: 32768,
+		SysctlFunc:     sysctlFunc,
+	}
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if mSysctl[sysctlConntrackMax] != "262144" || mSysctl[sysctlConntrackBuckets] != "65536" {
+		t.Errorf("unexpected conntrack sysctls: %v", mSysctl)
+	}
+	if len(writes) != 2 || writes[0] != sysctlConntrackBuckets || writes[1] != sysctlConntrackMax {
+		t.Errorf("buckets should be written before max, got %v", writes)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if mSysctl[sysctlConntrackMax] != "131072" || mSysctl[sysctlConntrackBuckets] != "32768" {
+		t.Errorf("Ensure(false) should restore defaults, got %v", mSysctl)
+	}
+}
+
+func TestConntrackSysctlConfigInvalid(t *testing.T) {
+	for _, c := range []ConntrackSysctlConfig{
+		{Max: 1024, Buckets: 65536},
+		{Max: 0, Buckets: 65536},
+		{Max: 65536, Buckets: -1},
+	} {
+		called := false
+		c.SysctlFunc = func(name string, params ...string) (string, error) {
+			called = true
+			return "", nil
+		}
+		if err := c.Ensure(true); err == nil {
+			t.Errorf("Ensure(true) should reject max=%d buckets=%d", c.Max, c.Buckets)
+		}
+		if called {
+			t.Errorf("no sysctl should be written for max=%d buckets=%d", c.Max, c.Buckets)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+)
+
+// DiffLogLevel is the glog verbosity at which every change netd makes to the
+// system is logged, e.g. "added ip rule pref 1000 lookup 100". This gives an
+// audit trail without enabling all debug logging.
+var DiffLogLevel glog.Level = 2
+
+// diffLogf logs one change. It is replaced in tests.
+var diffLogf = func(format string, args ...interface{}) {
+	glog.V(DiffLogLevel).Infof(format, args...)
+}
+
+// describeRule formats a rule the way ip rule shows it. Fields netlink.NewRule
+// leaves at -1 are unset and omitted.
+func describeRule(rule netlink.Rule) string {
+	var parts []string
+	if rule.Priority >= 0 {
+		parts = append(parts, fmt.Sprintf("pref %d", rule.Priority))
+	}
+	if rule.Invert {
+		parts = append(parts, "not")
+	}
+	if rule.Src != nil {
+		parts = append(parts, "from "+rule.Src.String())
+	}
+	if rule.Dst != nil {
+		parts = append(parts, "to "+rule.Dst.String())
+	}
+	if rule.Mark > 0 {
+		mark := fmt.Sprintf("fwmark %#x", rule.Mark)
+		if rule.Mask > 0 {
+			mark += fmt.Sprintf("/%#x", rule.Mask)
+		}
+		parts = append(parts, mark)
+	}
+	if rule.Tos != 0 {
+		parts = append(parts, fmt.Sprintf("tos %#x", rule.Tos))
+	}
+	if rule.IPProto > 0 {
+		parts = append(parts, fmt.Sprintf("ipproto %d", rule.IPProto))
+	}
+	if rule.IifName != "" {
+		parts = append(parts, "iif "+rule.IifName)
+	}
+	if rule.OifName != "" {
+		parts = append(parts, "oif "+rule.OifName)
+	}
+	if rule.Table > 0 {
+		parts = append(parts, fmt.Sprintf("lookup %d", rule.Table))
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeRoute formats a route the way ip route shows it, with the link as
+// an index.
+func describeRoute(route netlink.Route) string {
+	dst := "default"
+	if !isDefaultDst(route.Dst) {
+		dst = route.Dst.String()
+	}
+	parts := []string{dst}
+	if route.Gw != nil {
+		parts = append(parts, "via "+route.Gw.String())
+	}
+	if route.LinkIndex != 0 {
+		parts = append(parts, fmt.Sprintf("dev %d", route.LinkIndex))
+	}
+	if route.Src != nil {
+		parts = append(parts, "src "+route.Src.String())
+	}
+	parts = append(parts, fmt.Sprintf("table %d", routeTable(route)))
+	return strings.Join(parts, " ")
+}
+
+// DescribeTarget returns what c changes in the system, e.g. a sysctl key, an
+// ip rule or route, or an iptables chain.
+func DescribeTarget(c Config) string {
+	switch c := c.(type) {
+	case NamedConfig:
+		return DescribeTarget(c.Config)
+	case MetadataGatedConfig:
+		return DescribeTarget(c.Config)
+	case KubeProxyModeGatedConfig:
+		return DescribeTarget(c.Config)
+	case NodeReadyGatedConfig:
+		return DescribeTarget(c.Config)
+	case SysctlConfig:
+		return "sysctl " + c.Key
+	case IPRuleConfig:
+		return "ip rule " + describeRule(c.Rule)
+	case IPRouteConfig:
+		return "ip route " + describeRoute(c.Route)
+	case MultipathRouteConfig:
+		return "ip route " + describeRoute(c.Route)
+	case IPTablesRuleConfig:
+		return fmt.Sprintf("iptables -t %s %s", c.Spec.TableName, c.Spec.ChainName)
+	}
+	return fmt.Sprintf("%v", c)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// captureDiffs records the diff log lines written until the test ends.
+func captureDiffs(t *testing.T) *[]string {
+	var lines []string
+	saved := diffLogf
+	diffLogf = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	t.Cleanup(func() { diffLogf = saved })
+	return &lines
+}
+
+func TestDiffLogging(t *testing.T) {
+	lines := captureDiffs(t)
+
+	mSysctl := map[string]string{"net.ipv4.conf.eth0.rp_filter": "1"}
+	s := SysctlConfig{
+		Key:          "net.ipv4.conf.eth0.rp_filter",
+		Value:        "2",
+		DefaultValue: "1",
+		SysctlFunc: func(name string, params ...string) (string, error) {
+			if len(params) == 0 {
+				return mSysctl[name], nil
+			}
+			mSysctl[name] = params[0]
+			return "", nil
+		},
+	}
+	rules := &fakeRuleList{}
+	r := rules.config(newRuleConfig(100))
+	r.Rule.Priority = 1000
+	routes := &fakeRouteTable{}
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	route := routes.config(NewLinkScopeRouteConfig(*dst, 2, customRouteTable).Route)
+
+	for i := 0; i < 2; i++ {
+		for _, c := range []Config{s, r, route} {
+			if err := c.Ensure(true); err != nil {
+				t.Fatalf("Ensure(true) returned error: %v", err)
+			}
+		}
+	}
+	if err := r.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+
+	want := []string{
+		"sysctl net.ipv4.conf.eth0.rp_filter 1->2",
+		"added ip rule pref 1000 lookup 100",
+		"added ip route 10.1.0.0/24 dev 2 table 1",
+		"deleted ip rule pref 1000 lookup 100",
+	}
+	if !reflect.DeepEqual(*lines, want) {
+		t.Errorf("diff log = %q, want %q", *lines, want)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containernetworking/plugins/pkg/utils/sysctl"
+	"github.com/golang/glog"
+)
+
+// FamilyPlaceholder is replaced by "ipv4" and "ipv6" in the key template of a
+// DualStackSysctlConfig.
+const FamilyPlaceholder = "{family}"
+
+// DualStackSysctlConfig keeps the IPv4 and IPv6 variants of a sysctl, e.g.
+// net.{family}.conf.all.forwarding, at the same value. The IPv6 sysctl is
+// skipped when it does not exist, as on nodes with IPv6 disabled.
+type DualStackSysctlConfig struct {
+	Template, Value, DefaultValue string
+	SysctlFunc                    sysctler
+}
+
+// NewDualStackSysctlConfig creates a DualStackSysctlConfig for template,
+// which must contain FamilyPlaceholder.
+func NewDualStackSysctlConfig(template, value, defaultValue string) (DualStackSysctlConfig, error) {
+	if !strings.Contains(template, FamilyPlaceholder) {
+		return DualStackSysctlConfig{}, fmt.Errorf("sysctl template %q has no %s placeholder", template, FamilyPlaceholder)
+	}
+	return DualStackSysctlConfig{
+		Template:     template,
+		Value:        value,
+		DefaultValue: defaultValue,
+		SysctlFunc:   sysctl.Sysctl,
+	}, nil
+}
+
+// Ensure DualStackSysctlConfig
+func (d DualStackSysctlConfig) Ensure(enabled bool) error {
+	_, err := d.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged DualStackSysctlConfig. Each family is read back after it is
+// written, and both are always attempted.
+func (d DualStackSysctlConfig) EnsureChanged(enabled bool) (bool, error) {
+	want := d.DefaultValue
+	if enabled {
+		want = d.Value
+	}
+	var changed bool
+	var errs []error
+	for _, family := range []string{"ipv4", "ipv6"} {
+		s := SysctlConfig{
+			Key:          strings.ReplaceAll(d.Template, FamilyPlaceholder, family),
+			Value:        d.Value,
+			DefaultValue: d.DefaultValue,
+			SysctlFunc:   d.SysctlFunc,
+		}
+		familyChanged, err := s.EnsureChanged(enabled)
+		if family == "ipv6" && errors.Is(err, os.ErrNotExist) {
+			glog.V(2).Infof("skipping %s: IPv6 is disabled", s.Key)
+			continue
+		}
+		if err == nil {
+			err = s.verify(want)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		changed = changed || familyChanged
+	}
+	return changed, errors.Join(errs...)
+}
+
+// verify reads the sysctl back and fails unless it holds want.
+func (s SysctlConfig) verify(want string) error {
+	got, err := s.SysctlFunc(s.Key)
+	if err != nil {
+		return err
+	}
+	if got = strings.TrimSpace(got); got != want {
+		return fmt.Errorf("sysctl %s is %q after setting it to %q", s.Key, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+// fakeSysctls is a sysctl tree in which only the listed keys exist.
+type fakeSysctls map[string]string
+
+func (f fakeSysctls) sysctl(name string, params ...string) (string, error) {
+	if _, ok := f[name]; !ok {
+		return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if len(params) == 0 {
+		return f[name] + "\n", nil
+	}
+	f[name] = params[0]
+	return "", nil
+}
+
+func TestDualStackSysctlConfig(t *testing.T) {
+	const v4, v6 = "net.ipv4.conf.all.forwarding", "net.ipv6.conf.all.forwarding"
+	c, err := NewDualStackSysctlConfig("net.{family}.conf.all.forwarding", "1", "0")
+	if err != nil {
+		t.Fatalf("NewDualStackSysctlConfig returned error: %v", err)
+	}
+
+	sysctls := fakeSysctls{v4: "0", v6: "0"}
+	c.SysctlFunc = sysctls.sysctl
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) = %v, %v; want true, nil", changed, err)
+	}
+	if sysctls[v4] != "1" || sysctls[v6] != "1" {
+		t.Errorf("both families should be enabled, got %v", sysctls)
+	}
+	if changed, err := c.EnsureChanged(true); err != nil || changed {
+		t.Errorf("a second EnsureChanged(true) = %v, %v; want false, nil", changed, err)
+	}
+	if err := c.Ensure(false); err != nil || sysctls[v4] != "0" || sysctls[v6] != "0" {
+		t.Errorf("Ensure(false) = %v with %v; want both families reset", err, sysctls)
+	}
+
+	// IPv6 disabled: the IPv6 key does not exist.
+	sysctls = fakeSysctls{v4: "0"}
+	c.SysctlFunc = sysctls.sysctl
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) without IPv6 returned error: %v", err)
+	}
+	if sysctls[v4] != "1" {
+		t.Errorf("the IPv4 sysctl should still be set, got %v", sysctls)
+	}
+
+	// A write that does not stick is reported.
+	c.SysctlFunc = func(name string, params ...string) (string, error) { return "0", nil }
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should fail when the value does not read back")
+	}
+
+	if _, err := NewDualStackSysctlConfig("net.ipv4.conf.all.forwarding", "1", "0"); err == nil {
+		t.Error("a template without the placeholder should be rejected")
+	}
+}
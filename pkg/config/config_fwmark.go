@@ -0,0 +1,105 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// builtinChains are the chains iptables creates, which netd must never delete.
+var builtinChains = map[string]bool{
+	"PREROUTING":  true,
+	"INPUT":       true,
+	"FORWARD":     true,
+	"OUTPUT":      true,
+	"POSTROUTING": true,
+}
+
+// FwmarkPolicyConfig ties an iptables MARK rule to the ip rule routing the
+// packets it marks, so the two halves cannot drift apart. The mark is applied
+// before the rule and removed after it.
+type FwmarkPolicyConfig struct {
+	Mark IPTablesRuleConfig
+	Rule IPRuleConfig
+}
+
+// NewFwmarkPolicyConfig creates a FwmarkPolicyConfig that sets mark/mask on
+// packets matching matches in the given mangle chain, and looks packets with
+// that mark up in table at priority.
+func NewFwmarkPolicyConfig(chain string, matches IPTablesRuleSpec, mark, mask uint32, table, priority int) (FwmarkPolicyConfig, error) {
+	if mask == 0 || mark&^mask != 0 {
+		return FwmarkPolicyConfig{}, fmt.Errorf("fwmark 0x%x must be non-empty and within mask 0x%x", mark, mask)
+	}
+	if table <= 0 {
+		return FwmarkPolicyConfig{}, fmt.Errorf("invalid routing table %d", table)
+	}
+	markSpec := append(IPTablesRuleSpec{}, matches...)
+	markSpec = append(markSpec, "-j", "MARK", "--set-xmark", fmt.Sprintf("0x%x/0x%x", mark, mask))
+	markSpec = markSpec.WithComment(fmt.Sprintf("fwmark 0x%x/0x%x for table %d", mark, mask, table))
+
+	rule := newRuleConfig(table)
+	rule.Rule.Mark = int(mark)
+	rule.Rule.Mask = int(mask)
+	rule.Rule.Priority = priority
+
+	return FwmarkPolicyConfig{
+		Mark: IPTablesRuleConfig{
+			Spec: IPTablesChainSpec{
+				TableName:      tableMangle,
+				ChainName:      chain,
+				IsDefaultChain: builtinChains[chain],
+				IPT:            ipt,
+			},
+			RuleSpecs: []IPTablesRuleSpec{markSpec},
+			IPT:       ipt,
+		},
+		Rule: rule,
+	}, nil
+}
+
+// Dynamic FwmarkPolicyConfig
+func (f FwmarkPolicyConfig) Dynamic() bool {
+	return f.Rule.Dynamic()
+}
+
+// Chains FwmarkPolicyConfig
+func (f FwmarkPolicyConfig) Chains() []IPTablesChainSpec {
+	return f.Mark.Chains()
+}
+
+// Ensure FwmarkPolicyConfig
+func (f FwmarkPolicyConfig) Ensure(enabled bool) error {
+	_, err := f.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged FwmarkPolicyConfig. The rule is not added while the mark
+// cannot be, but both halves are always attempted on removal.
+func (f FwmarkPolicyConfig) EnsureChanged(enabled bool) (bool, error) {
+	if enabled {
+		markChanged, err := f.Mark.EnsureChanged(true)
+		if err != nil {
+			return markChanged, err
+		}
+		ruleChanged, err := f.Rule.EnsureChanged(true)
+		return markChanged || ruleChanged, err
+	}
+	ruleChanged, ruleErr := f.Rule.EnsureChanged(false)
+	markChanged, markErr := f.Mark.EnsureChanged(false)
+	return ruleChanged || markChanged, errors.Join(ruleErr, markErr)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// FwmarkBits is the registry of the fwmark bits owned by netd's features,
+// starting with the hairpin bit of policy routing.
+var FwmarkBits = &FwmarkRegistry{owners: map[string]uint32{"PolicyRouting": hairpinMask}}
+
+// FwmarkRegistry tracks which bits of the 32-bit fwmark each feature owns, so
+// that features sharing the mark never set or match each other's bits.
+type FwmarkRegistry struct {
+	mu     sync.Mutex
+	owners map[string]uint32
+}
+
+// NewFwmarkRegistry creates an empty FwmarkRegistry.
+func NewFwmarkRegistry() *FwmarkRegistry {
+	return &FwmarkRegistry{owners: make(map[string]uint32)}
+}
+
+// Register reserves the bits of mask for feature and returns the allocation
+// building its marks. A feature may register bits it already owns again, or
+// more bits, but bits owned by another feature are an error.
+func (r *FwmarkRegistry) Register(feature string, mask uint32) (FwmarkAllocation, error) {
+	if feature == "" || mask == 0 {
+		return FwmarkAllocation{}, fmt.Errorf("fwmark registration needs a feature and a non-empty mask, got %q and 0x%x", feature, mask)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owners := make([]string, 0, len(r.owners))
+	for owner := range r.owners {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		if overlap := r.owners[owner] & mask; owner != feature && overlap != 0 {
+			return FwmarkAllocation{}, fmt.Errorf("fwmark bits 0x%x requested by %s are owned by %s", overlap, feature, owner)
+		}
+	}
+	r.owners[feature] |= mask
+	return FwmarkAllocation{Feature: feature, Mask: mask}, nil
+}
+
+// Owned returns the bits feature has registered, zero if none.
+func (r *FwmarkRegistry) Owned(feature string) uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.owners[feature]
+}
+
+// FwmarkAllocation is a Mask of fwmark bits owned by Feature. Its configs set
+// and match only those bits.
+type FwmarkAllocation struct {
+	Feature string
+	Mask    uint32
+}
+
+func (a FwmarkAllocation) check(mark uint32) error {
+	if mark&^a.Mask != 0 {
+		return fmt.Errorf("fwmark 0x%x of %s uses bits outside its mask 0x%x", mark, a.Feature, a.Mask)
+	}
+	return nil
+}
+
+// MarkRuleSpec returns the target tokens "-j MARK --set-xmark <mark>/<mask>",
+// which leave the bits outside the mask untouched.
+func (a FwmarkAllocation) MarkRuleSpec(mark uint32) (IPTablesRuleSpec, error) {
+	if err := a.check(mark); err != nil {
+		return nil, err
+	}
+	return IPTablesRuleSpec{"-j", "MARK", "--set-xmark", fmt.Sprintf("0x%x/0x%x", mark, a.Mask)}, nil
+}
+
+// RuleConfig creates an IPRuleConfig for
+// "ip rule add pref <priority> fwmark <mark>/<mask> lookup <table>".
+func (a FwmarkAllocation) RuleConfig(mark uint32, table, priority int) (IPRuleConfig, error) {
+	if err := a.check(mark); err != nil {
+		return IPRuleConfig{}, err
+	}
+	if table <= 0 {
+		return IPRuleConfig{}, fmt.Errorf("invalid routing table %d", table)
+	}
+	rule := newRuleConfig(table)
+	rule.Rule.Mark = int(mark)
+	rule.Rule.Mask = int(a.Mask)
+	rule.Rule.Priority = priority
+	return rule, nil
+}
+
+// PolicyConfig creates the NewFwmarkPolicyConfig marking and routing the
+// packets matching matches with mark under the allocation's mask.
+func (a FwmarkAllocation) PolicyConfig(chain string, matches IPTablesRuleSpec, mark uint32, table, priority int) (FwmarkPolicyConfig, error) {
+	if err := a.check(mark); err != nil {
+		return FwmarkPolicyConfig{}, err
+	}
+	return NewFwmarkPolicyConfig(chain, matches, mark, a.Mask, table, priority)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFwmarkRegistry(t *testing.T) {
+	r := NewFwmarkRegistry()
+	a, err := r.Register("egress", 0xff0000)
+	if err != nil {
+		t.Fatalf("Register(egress) returned error: %v", err)
+	}
+	b, err := r.Register("ingress", 0x0000ff)
+	if err != nil {
+		t.Fatalf("Register(ingress) returned error: %v", err)
+	}
+	if _, err := r.Register("egress", 0x010000); err != nil {
+		t.Errorf("re-registering owned bits returned error: %v", err)
+	}
+	if _, err := r.Register("egress", 0x01000000); err != nil {
+		t.Errorf("registering more bits returned error: %v", err)
+	}
+	if got := r.Owned("egress"); got != 0x01ff0000 {
+		t.Errorf("Owned(egress) = 0x%x, want 0x1ff0000", got)
+	}
+
+	for _, mask := range []uint32{0x800000, 0x80, 0xffffffff} {
+		if _, err := r.Register("other", mask); err == nil {
+			t.Errorf("Register(other, 0x%x) should fail on overlapping bits", mask)
+		}
+	}
+	if _, err := r.Register("other", 0x0100); err != nil {
+		t.Errorf("Register(other, 0x100) returned error: %v", err)
+	}
+	if _, err := r.Register("ingress", 0x0100); err == nil {
+		t.Error("Register(ingress, 0x100) should fail on bits owned by other")
+	}
+	if _, err := r.Register("", 0x1); err == nil {
+		t.Error("Register without a feature should fail")
+	}
+	if _, err := r.Register("empty", 0); err == nil {
+		t.Error("Register with an empty mask should fail")
+	}
+
+	spec, err := a.MarkRuleSpec(0x10000)
+	if err != nil {
+		t.Fatalf("MarkRuleSpec returned error: %v", err)
+	}
+	if want := (IPTablesRuleSpec{"-j", "MARK", "--set-xmark", "0x10000/0xff0000"}); !reflect.DeepEqual(spec, want) {
+		t.Errorf("MarkRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableMangle, spec)
+
+	rule, err := b.RuleConfig(0x2, 200, 30000)
+	if err != nil {
+		t.Fatalf("RuleConfig returned error: %v", err)
+	}
+	if rule.Rule.Mark != 0x2 || rule.Rule.Mask != 0xff || rule.Rule.Table != 200 || rule.Rule.Priority != 30000 {
+		t.Errorf("RuleConfig built rule %v", rule.Rule)
+	}
+
+	c, err := a.PolicyConfig(preRoutingChain, nil, 0x20000, 200, 30000)
+	if err != nil || c.Rule.Rule.Mask != 0xff0000 {
+		t.Errorf("PolicyConfig = %v, %v; want the allocation's mask", c.Rule.Rule, err)
+	}
+
+	// Marks spilling into another feature's bits are rejected.
+	if _, err := a.MarkRuleSpec(0x1); err == nil {
+		t.Error("MarkRuleSpec should reject bits outside the mask")
+	}
+	if _, err := b.RuleConfig(0x100, 200, 30000); err == nil {
+		t.Error("RuleConfig should reject bits outside the mask")
+	}
+	if _, err := a.PolicyConfig(preRoutingChain, nil, 0x4000, 200, 30000); err == nil {
+		t.Error("PolicyConfig should reject bits outside the mask")
+	}
+}
+
+func TestFwmarkBitsReservesHairpin(t *testing.T) {
+	if _, err := FwmarkBits.Register("test", hairpinMask); err == nil {
+		t.Error("the policy routing hairpin bit should already be owned")
+	}
+}
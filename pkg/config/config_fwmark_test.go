@@ -0,0 +1,65 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFwmarkPolicyConfig(t *testing.T) {
+	c, err := NewFwmarkPolicyConfig(preRoutingChain, IPTablesRuleSpec{"-s", "10.4.0.0/24"}, 0x10000, 0xff0000, 200, 30000)
+	if err != nil {
+		t.Fatalf("NewFwmarkPolicyConfig returned error: %v", err)
+	}
+	if !c.Mark.Spec.IsDefaultChain || c.Mark.Spec.TableName != tableMangle {
+		t.Errorf("the MARK rule should go in the built-in mangle chain, got %+v", c.Mark.Spec)
+	}
+	if c.Rule.Rule.Mark != 0x10000 || c.Rule.Rule.Mask != 0xff0000 || c.Rule.Rule.Table != 200 {
+		t.Errorf("the ip rule should match the same mark, got %v", c.Rule.Rule)
+	}
+	if spec := strings.Join(c.Mark.RuleSpecs[0], " "); !strings.Contains(spec, "-j MARK --set-xmark 0x10000/0xff0000") {
+		t.Errorf("unexpected MARK rule %q", spec)
+	}
+
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	c.Mark.Spec.IPT, c.Mark.IPT = fakeIPT, fakeIPT
+	rules := &fakeRuleList{}
+	c.Rule = rules.config(c.Rule)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fakeIPT.iptCache[preRoutingChain]) != 1 || len(rules.rules) != 1 {
+		t.Fatalf("both halves should be applied once, got %v and %v", fakeIPT.iptCache, rules.rules)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fakeIPT.iptCache[preRoutingChain]) != 0 || len(rules.rules) != 0 {
+		t.Errorf("both halves should be removed, got %v and %v", fakeIPT.iptCache, rules.rules)
+	}
+
+	for _, tc := range []struct{ mark, mask uint32 }{{0x1, 0}, {0x100, 0xff}} {
+		if _, err := NewFwmarkPolicyConfig(preRoutingChain, nil, tc.mark, tc.mask, 200, 30000); err == nil {
+			t.Errorf("mark 0x%x mask 0x%x should be rejected", tc.mark, tc.mask)
+		}
+	}
+}
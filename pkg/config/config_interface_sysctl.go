@@ -0,0 +1,138 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/containernetworking/plugins/pkg/utils/sysctl"
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+)
+
+type linkLister func() ([]netlink.Link, error)
+
+// InterfaceSysctlConfig sets net.<Family>.conf.<interface>.<Suffix> on every
+// interface whose name satisfies Match. Interfaces are listed on each Ensure,
+// so ones created since the last reconcile are picked up and ones that went
+// away are no longer visited.
+type InterfaceSysctlConfig struct {
+	// Family is "ipv4" or "ipv6" and defaults to "ipv4".
+	Family                      string
+	Suffix, Value, DefaultValue string
+	Match                       func(name string) bool
+	LinkList                    linkLister
+	SysctlFunc                  sysctler
+}
+
+// NewInterfaceSysctlConfig creates an InterfaceSysctlConfig for the
+// interfaces whose names match the glob pattern, e.g. "eth*".
+func NewInterfaceSysctlConfig(family, pattern, suffix, value, defaultValue string) (InterfaceSysctlConfig, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return InterfaceSysctlConfig{}, fmt.Errorf("invalid interface pattern %q: %v", pattern, err)
+	}
+	return InterfaceSysctlConfig{
+		Family:       family,
+		Suffix:       suffix,
+		Value:        value,
+		DefaultValue: defaultValue,
+		Match: func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		},
+		LinkList:   netlink.LinkList,
+		SysctlFunc: sysctl.Sysctl,
+	}, nil
+}
+
+// Dynamic InterfaceSysctlConfig
+func (InterfaceSysctlConfig) Dynamic() bool {
+	return true
+}
+
+// Ensure InterfaceSysctlConfig
+func (c InterfaceSysctlConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged InterfaceSysctlConfig. Every matching interface is visited
+// even if an earlier one fails; the errors are returned together.
+func (c InterfaceSysctlConfig) EnsureChanged(enabled bool) (bool, error) {
+	names, err := c.interfaces()
+	if err != nil {
+		return false, err
+	}
+	var changed bool
+	var errs []error
+	for _, name := range names {
+		s := SysctlConfig{
+			Key:          c.key(name),
+			Value:        c.Value,
+			DefaultValue: c.DefaultValue,
+			SysctlFunc:   c.SysctlFunc,
+		}
+		ifChanged, err := s.EnsureChanged(enabled)
+		if errors.Is(err, os.ErrNotExist) {
+			// The interface was removed after it was listed.
+			glog.V(2).Infof("interface %s went away before %s could be set", name, s.Key)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		changed = changed || ifChanged
+	}
+	return changed, errors.Join(errs...)
+}
+
+// interfaces returns the sorted names of the interfaces to configure.
+func (c InterfaceSysctlConfig) interfaces() ([]string, error) {
+	links, err := c.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	var names []string
+	for _, link := range links {
+		name := link.Attrs().Name
+		if !c.Match(name) {
+			continue
+		}
+		// The sysctl helper maps every "." in a key to "/", so names such
+		// as VLAN interfaces "eth0.100" cannot be addressed.
+		if strings.Contains(name, ".") {
+			glog.Warningf("skipping interface %s: names containing '.' are not supported", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (c InterfaceSysctlConfig) key(name string) string {
+	family := c.Family
+	if family == "" {
+		family = "ipv4"
+	}
+	return fmt.Sprintf("net.%s.conf.%s.%s", family, name, c.Suffix)
+}
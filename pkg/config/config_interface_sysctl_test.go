@@ -0,0 +1,80 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestInterfaceSysctlConfig(t *testing.T) {
+	links := []string{"lo", "eth0", "eth1"}
+	mSysctl := make(map[string]string)
+	c, err := NewInterfaceSysctlConfig("ipv6", "eth*", "accept_ra", "0", "1")
+	if err != nil {
+		t.Fatalf("NewInterfaceSysctlConfig failed: %v", err)
+	}
+	c.LinkList = func() ([]netlink.Link, error) {
+		var ls []netlink.Link
+		for _, name := range links {
+			ls = append(ls, &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name}})
+		}
+		return ls, nil
+	}
+	c.SysctlFunc = func(name string, params ...string) (string, error) {
+		if name == "net.ipv6.conf.eth2.accept_ra" {
+			// eth2 disappears between the link listing and the write.
+			return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		if len(params) == 0 {
+			return mSysctl[name], nil
+		}
+		mSysctl[name] = params[0]
+		return "", nil
+	}
+
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) = %v, %v", changed, err)
+	}
+	for _, key := range []string{"net.ipv6.conf.eth0.accept_ra", "net.ipv6.conf.eth1.accept_ra"} {
+		if mSysctl[key] != "0" {
+			t.Errorf("%s = %q, want 0", key, mSysctl[key])
+		}
+	}
+	if _, ok := mSysctl["net.ipv6.conf.lo.accept_ra"]; ok {
+		t.Error("lo does not match eth* and should not be touched")
+	}
+	if changed, err := c.EnsureChanged(true); err != nil || changed {
+		t.Errorf("second EnsureChanged(true) = %v, %v; want no change", changed, err)
+	}
+
+	// A new interface is configured on the next reconcile; one that vanished
+	// mid-reconcile is skipped.
+	links = []string{"lo", "eth1", "eth2", "eth3"}
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) after link changes = %v, %v", changed, err)
+	}
+	if mSysctl["net.ipv6.conf.eth3.accept_ra"] != "0" {
+		t.Error("new interface eth3 should be configured")
+	}
+
+	if _, err := NewInterfaceSysctlConfig("ipv4", "[", "rp_filter", "0", "1"); err == nil {
+		t.Error("an invalid pattern should be rejected")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+)
+
+// maxIPSetNameLen is IPSET_MAXNAMELEN without the trailing NUL.
+const maxIPSetNameLen = 31
+
+// maxIPSetDirections is IPSET_DIM_MAX, the most dimensions a set type has.
+const maxIPSetDirections = 6
+
+type ipsetLister func(setname string) (*netlink.IPSetResult, error)
+
+// NewSetMatchRuleSpec returns the match tokens for
+// "-m set --match-set <name> <directions>", where each direction is "src" or
+// "dst" and selects the packet field matched against one set dimension.
+func NewSetMatchRuleSpec(name string, directions ...string) (IPTablesRuleSpec, error) {
+	if name == "" || len(name) > maxIPSetNameLen || strings.ContainsAny(name, " \t\n,") {
+		return nil, fmt.Errorf("invalid ipset name %q", name)
+	}
+	if len(directions) == 0 || len(directions) > maxIPSetDirections {
+		return nil, fmt.Errorf("ipset match needs 1 to %d directions, got %d", maxIPSetDirections, len(directions))
+	}
+	for _, d := range directions {
+		if d != "src" && d != "dst" {
+			return nil, fmt.Errorf("invalid ipset direction %q", d)
+		}
+	}
+	return IPTablesRuleSpec{"-m", "set", "--match-set", name, strings.Join(directions, ",")}, nil
+}
+
+// IPSetRuleConfig ensures rules that reference ipsets. iptables refuses rules
+// naming a set that does not exist, so while any of Sets is missing the rules
+// are skipped rather than failing the whole Set.
+type IPSetRuleConfig struct {
+	IPTablesRuleConfig
+	Sets      []string
+	IPSetList ipsetLister
+}
+
+// Ensure IPSetRuleConfig
+func (c IPSetRuleConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged IPSetRuleConfig
+func (c IPSetRuleConfig) EnsureChanged(enabled bool) (bool, error) {
+	if enabled {
+		for _, name := range c.Sets {
+			exists, err := c.ipsetExists(name)
+			if err != nil {
+				return false, err
+			}
+			if !exists {
+				glog.Warningf("skipping rules in chain %s: ipset %s does not exist", c.Spec.ChainName, name)
+				return false, nil
+			}
+		}
+	}
+	return c.IPTablesRuleConfig.EnsureChanged(enabled)
+}
+
+func (c IPSetRuleConfig) ipsetExists(name string) (bool, error) {
+	list := c.IPSetList
+	if list == nil {
+		list = netlink.IpsetList
+	}
+	if _, err := list(name); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to look up ipset %s: %w", name, err)
+	}
+	return true, nil
+}
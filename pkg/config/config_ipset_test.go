@@ -0,0 +1,96 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNewSetMatchRuleSpec(t *testing.T) {
+	spec, err := NewSetMatchRuleSpec("netd-allow", "src", "dst")
+	if err != nil {
+		t.Fatalf("NewSetMatchRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-m", "set", "--match-set", "netd-allow", "src,dst"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewSetMatchRuleSpec = %v, want %v", spec, want)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		directions []string
+	}{
+		{"", []string{"src"}},
+		{strings.Repeat("a", 32), []string{"src"}},
+		{"has space", []string{"src"}},
+		{"ok", nil},
+		{"ok", []string{"src", "both"}},
+		{"ok", []string{"src", "src", "src", "src", "src", "src", "src"}},
+	} {
+		if _, err := NewSetMatchRuleSpec(tc.name, tc.directions...); err == nil {
+			t.Errorf("NewSetMatchRuleSpec(%q, %v) should fail", tc.name, tc.directions)
+		}
+	}
+}
+
+func TestIPSetRuleConfig(t *testing.T) {
+	sets := map[string]bool{}
+	spec, _ := NewSetMatchRuleSpec("netd-allow", "src")
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	c := IPSetRuleConfig{
+		IPTablesRuleConfig: IPTablesRuleConfig{
+			Spec:      IPTablesChainSpec{TableName: tableFilter, ChainName: "GCP-IPSET", IPT: fakeIPT},
+			RuleSpecs: []IPTablesRuleSpec{append(spec, "-j", "ACCEPT")},
+			IPT:       fakeIPT,
+		},
+		Sets: []string{"netd-allow"},
+		IPSetList: func(name string) (*netlink.IPSetResult, error) {
+			if !sets[name] {
+				return nil, syscall.ENOENT
+			}
+			return &netlink.IPSetResult{SetName: name}, nil
+		},
+	}
+
+	if changed, err := c.EnsureChanged(true); err != nil || changed {
+		t.Errorf("EnsureChanged with a missing ipset = %v, %v; want a skip", changed, err)
+	}
+	if _, ok := fakeIPT.iptCache["GCP-IPSET"]; ok {
+		t.Error("nothing should be installed while the ipset is missing")
+	}
+
+	sets["netd-allow"] = true
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Errorf("EnsureChanged with the ipset present = %v, %v", changed, err)
+	}
+	if len(fakeIPT.iptCache["GCP-IPSET"]) != 1 {
+		t.Errorf("the ipset rule should be installed, got %v", fakeIPT.iptCache)
+	}
+
+	delete(sets, "netd-allow")
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if _, ok := fakeIPT.iptCache["GCP-IPSET"]; ok {
+		t.Error("teardown should not depend on the ipset existing")
+	}
+}
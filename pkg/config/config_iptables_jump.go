@@ -0,0 +1,86 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/golang/glog"
+)
+
+// IPTablesJumpConfig wires a netd chain into a parent chain, typically a
+// built-in hook such as POSTROUTING, with "-j <TargetChain>". It complements
+// IPTablesChainSpec, which creates the chain but does not hook it up.
+type IPTablesJumpConfig struct {
+	TableName   string
+	ParentChain string
+	TargetChain string
+	// Position is the 1-based position the jump is inserted at. Zero appends
+	// it. A jump that already exists is left where it is.
+	Position int
+	// Matches are optional tokens placed before the jump, e.g. a comment.
+	Matches IPTablesRuleSpec
+	// Goto wires the chain with "-g" instead of "-j", see NewGotoRuleSpec. A
+	// goto and a jump to the same chain are distinct rules.
+	Goto bool
+	IPT  iptabler
+}
+
+func (j IPTablesJumpConfig) ruleSpec() []string {
+	spec := append([]string{}, j.Matches...)
+	if j.Goto {
+		return append(spec, "-g", j.TargetChain)
+	}
+	return append(spec, "-j", j.TargetChain)
+}
+
+// Ensure IPTablesJumpConfig
+func (j IPTablesJumpConfig) Ensure(enabled bool) error {
+	_, err := j.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged IPTablesJumpConfig
+func (j IPTablesJumpConfig) EnsureChanged(enabled bool) (bool, error) {
+	rs := j.ruleSpec()
+	exists, err := j.IPT.Exists(j.TableName, j.ParentChain, rs...)
+	if err != nil {
+		return false, err
+	}
+	if enabled == exists {
+		return false, nil
+	}
+
+	switch {
+	case !enabled:
+		err = j.IPT.Delete(j.TableName, j.ParentChain, rs...)
+	case j.Position > 0:
+		err = j.IPT.Insert(j.TableName, j.ParentChain, j.Position, rs...)
+	default:
+		err = j.IPT.AppendUnique(j.TableName, j.ParentChain, rs...)
+	}
+	if err != nil {
+		glog.Errorf("failed to ensure(%v) %s from %s to %s in table %s: %v", enabled, j.kind(), j.ParentChain, j.TargetChain, j.TableName, err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (j IPTablesJumpConfig) kind() string {
+	if j.Goto {
+		return "goto"
+	}
+	return "jump"
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIPTablesJumpConfig(t *testing.T) {
+	fakeIPT := FakeIPTable{
+		iptCache: map[string][]string{postRoutingChain: {"-j KUBE-POSTROUTING"}},
+	}
+	j := IPTablesJumpConfig{
+		TableName:   tableNAT,
+		ParentChain: postRoutingChain,
+		TargetChain: "NETD-MASQ",
+		Position:    1,
+		Matches:     IPTablesRuleSpec{"-m", "comment", "--comment", "netd masquerade"},
+		IPT:         fakeIPT,
+	}
+	for i := 0; i < 2; i++ {
+		if err := j.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	want := []string{"-m comment --comment netd masquerade -j NETD-MASQ", "-j KUBE-POSTROUTING"}
+	if !reflect.DeepEqual(fakeIPT.iptCache[postRoutingChain], want) {
+		t.Errorf("jump should be inserted once at position 1, got %v", fakeIPT.iptCache[postRoutingChain])
+	}
+
+	if err := j.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if !reflect.DeepEqual(fakeIPT.iptCache[postRoutingChain], []string{"-j KUBE-POSTROUTING"}) {
+		t.Errorf("Ensure(false) should only remove the jump, got %v", fakeIPT.iptCache[postRoutingChain])
+	}
+
+	j.Position = 0
+	j.Ensure(true)
+	if got := fakeIPT.iptCache[postRoutingChain]; len(got) != 2 || got[1] != want[0] {
+		t.Errorf("jump without a position should be appended, got %v", got)
+	}
+}
+
+func TestIPTablesJumpConfigGoto(t *testing.T) {
+	fakeIPT := FakeIPTable{
+		iptCache: map[string][]string{"NETD-OUTER": {"-j NETD-INNER"}},
+	}
+	j := IPTablesJumpConfig{
+		TableName:   tableNAT,
+		ParentChain: "NETD-OUTER",
+		TargetChain: "NETD-INNER",
+		Goto:        true,
+		IPT:         fakeIPT,
+	}
+	if changed, err := j.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) = %v, %v; the existing jump must not satisfy the goto", changed, err)
+	}
+	if changed, err := j.EnsureChanged(true); err != nil || changed {
+		t.Errorf("a second EnsureChanged(true) = %v, %v; want false, nil", changed, err)
+	}
+	want := []string{"-j NETD-INNER", "-g NETD-INNER"}
+	if !reflect.DeepEqual(fakeIPT.iptCache["NETD-OUTER"], want) {
+		t.Errorf("chain = %v, want %v", fakeIPT.iptCache["NETD-OUTER"], want)
+	}
+
+	if err := j.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if !reflect.DeepEqual(fakeIPT.iptCache["NETD-OUTER"], []string{"-j NETD-INNER"}) {
+		t.Errorf("Ensure(false) should only remove the goto, got %v", fakeIPT.iptCache["NETD-OUTER"])
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"github.com/golang/glog"
+)
+
+type iptablesSaveRestorer interface {
+	Save(table string) ([]byte, error)
+	Restore(table string, data []byte) error
+}
+
+// execSaveRestorer shells out to iptables-save and iptables-restore.
+type execSaveRestorer struct{}
+
+func (execSaveRestorer) Save(table string) ([]byte, error) {
+	out, err := exec.Command("iptables-save", "-t", table).Output()
+	if err != nil {
+		return nil, fmt.Errorf("iptables-save -t %s failed: %v", table, err)
+	}
+	return out, nil
+}
+
+func (execSaveRestorer) Restore(table string, data []byte) error {
+	cmd := exec.Command("iptables-restore", "-T", table)
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("iptables-restore -T %s failed: %v (%s)", table, err, out)
+	}
+	return nil
+}
+
+// Snapshot holds the iptables-save output of a set of tables, keyed by table name.
+type Snapshot struct {
+	Tables map[string][]byte
+}
+
+// SnapshotTables saves the current contents of the given iptables tables.
+func SnapshotTables(tables ...string) (Snapshot, error) {
+	return snapshotTables(execSaveRestorer{}, tables...)
+}
+
+// RestoreSnapshot restores every table in s, replacing its current contents.
+func RestoreSnapshot(s Snapshot) error {
+	return restoreSnapshot(execSaveRestorer{}, s)
+}
+
+func snapshotTables(sr iptablesSaveRestorer, tables ...string) (Snapshot, error) {
+	s := Snapshot{Tables: make(map[string][]byte, len(tables))}
+	for _, table := range tables {
+		if !iptablesTables[table] {
+			return Snapshot{}, fmt.Errorf("unsupported iptables table %q", table)
+		}
+		data, err := sr.Save(table)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		s.Tables[table] = data
+	}
+	return s, nil
+}
+
+func restoreSnapshot(sr iptablesSaveRestorer, s Snapshot) error {
+	for table, data := range s.Tables {
+		if err := sr.Restore(table, data); err != nil {
+			glog.Errorf("failed to restore iptables table %s: %v", table, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+)
+
+type fakeSaveRestorer struct {
+	tables   map[string]string
+	restored map[string]string
+}
+
+func (f *fakeSaveRestorer) Save(table string) ([]byte, error) {
+	return []byte(f.tables[table]), nil
+}
+
+func (f *fakeSaveRestorer) Restore(table string, data []byte) error {
+	f.restored[table] = string(data)
+	return nil
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	sr := &fakeSaveRestorer{
+		tables: map[string]string{
+			tableMangle: "*mangle\n-A PREROUTING -j GCP-PREROUTING\nCOMMIT\n",
+			tableNAT:    "*nat\nCOMMIT\n",
+		},
+		restored: make(map[string]string),
+	}
+
+	s, err := snapshotTables(sr, tableMangle, tableNAT)
+	if err != nil {
+		t.Fatalf("snapshotTables returned error: %v", err)
+	}
+	if len(s.Tables) != 2 {
+		t.Fatalf("snapshot should contain 2 tables, got %d", len(s.Tables))
+	}
+
+	// Mutate the live tables; the snapshot must keep the saved blob.
+	sr.tables[tableMangle] = "*mangle\nCOMMIT\n"
+
+	if err := restoreSnapshot(sr, s); err != nil {
+		t.Fatalf("restoreSnapshot returned error: %v", err)
+	}
+	if got := sr.restored[tableMangle]; got != "*mangle\n-A PREROUTING -j GCP-PREROUTING\nCOMMIT\n" {
+		t.Errorf("restored mangle table = %q, want the saved blob", got)
+	}
+	if got := sr.restored[tableNAT]; got != "*nat\nCOMMIT\n" {
+		t.Errorf("restored nat table = %q, want the saved blob", got)
+	}
+}
+
+func TestSnapshotUnknownTable(t *testing.T) {
+	sr := &fakeSaveRestorer{tables: map[string]string{}, restored: map[string]string{}}
+	if _, err := snapshotTables(sr, "bogus"); err == nil {
+		t.Error("snapshotTables should reject an unknown table")
+	}
+}
@@ -0,0 +1,571 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+type addrLister func(link netlink.Link, family int) ([]netlink.Addr, error)
+
+// maxMultiportPorts is the xt_multiport limit; a port range counts as two.
+const maxMultiportPorts = 15
+
+// NewMultiportRuleSpec returns the match tokens for
+// "-p <protocol> -m multiport --dports|--sports <ports>". Each port is either
+// a single port or a "first:last" range. Append a target to complete the rule.
+func NewMultiportRuleSpec(protocol string, destination bool, ports []string) (IPTablesRuleSpec, error) {
+	if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+		return nil, fmt.Errorf("multiport does not support protocol %q", protocol)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("multiport requires at least one port")
+	}
+	count := 0
+	for _, p := range ports {
+		n, err := parsePortOrRange(p)
+		if err != nil {
+			return nil, err
+		}
+		count += n
+	}
+	if count > maxMultiportPorts {
+		return nil, fmt.Errorf("multiport supports at most %d ports, got %d", maxMultiportPorts, count)
+	}
+
+	flag := "--sports"
+	if destination {
+		flag = "--dports"
+	}
+	return IPTablesRuleSpec{"-p", protocol, "-m", "multiport", flag, strings.Join(ports, ",")}, nil
+}
+
+// parsePortOrRange validates "port" or "first:last" and returns how many
+// multiport slots it takes.
+func parsePortOrRange(s string) (int, error) {
+	first, last, isRange := strings.Cut(s, ":")
+	lo, err := parsePort(first)
+	if err != nil {
+		return 0, err
+	}
+	if !isRange {
+		return 1, nil
+	}
+	hi, err := parsePort(last)
+	if err != nil {
+		return 0, err
+	}
+	if lo > hi {
+		return 0, fmt.Errorf("invalid port range %q", s)
+	}
+	return 2, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+// NewSNATRuleSpec returns the target tokens "-j SNAT --to-source <ip>". When
+// addrList is non-nil, the address must also be assigned to a local
+// interface, e.g. pass netlink.AddrList.
+func NewSNATRuleSpec(toSource string, addrList addrLister) (IPTablesRuleSpec, error) {
+	ip := net.ParseIP(toSource)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid SNAT source address %q", toSource)
+	}
+	if addrList != nil {
+		local, err := isLocalAddr(ip, addrList)
+		if err != nil {
+			return nil, err
+		}
+		if !local {
+			return nil, fmt.Errorf("SNAT source address %s is not assigned to a local interface", ip)
+		}
+	}
+	return IPTablesRuleSpec{"-j", "SNAT", "--to-source", ip.String()}, nil
+}
+
+func isLocalAddr(ip net.IP, addrList addrLister) (bool, error) {
+	family := netlink.FAMILY_V4
+	if ip.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	addrs, err := addrList(nil, family)
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// maxHashlimitNameLen is the hashlimit name limit on older kernels.
+const maxHashlimitNameLen = 15
+
+// hashlimitModes lists the valid --hashlimit-mode values in the order
+// iptables prints them.
+var hashlimitModes = []string{"srcip", "srcport", "dstip", "dstport"}
+
+// HashlimitSpec describes a "-m hashlimit" match.
+type HashlimitSpec struct {
+	Name string
+	// Rate is "<n>/<unit>" with unit one of second, minute, hour or day, or
+	// their abbreviations (sec, s, min, m, h, d).
+	Rate  string
+	Burst int
+	// Mode lists the header fields the limit is tracked per. Empty means one
+	// bucket for all packets.
+	Mode []string
+	// Above matches packets exceeding the rate instead of those within it.
+	Above bool
+}
+
+// NewHashlimitRuleSpec returns the match tokens for h. Arguments are emitted
+// in a fixed order, and the modes in canonical order, so the same HashlimitSpec
+// always produces the same rule.
+func NewHashlimitRuleSpec(h HashlimitSpec) (IPTablesRuleSpec, error) {
+	if h.Name == "" || len(h.Name) > maxHashlimitNameLen {
+		return nil, fmt.Errorf("hashlimit name %q must be 1 to %d characters", h.Name, maxHashlimitNameLen)
+	}
+	if err := validateRate(h.Rate); err != nil {
+		return nil, err
+	}
+	if h.Burst <= 0 {
+		return nil, fmt.Errorf("hashlimit burst must be positive, got %d", h.Burst)
+	}
+	for _, m := range h.Mode {
+		if !containsString(hashlimitModes, m) {
+			return nil, fmt.Errorf("invalid hashlimit mode %q", m)
+		}
+	}
+	var modes []string
+	for _, m := range hashlimitModes {
+		if containsString(h.Mode, m) {
+			modes = append(modes, m)
+		}
+	}
+
+	limit := "--hashlimit-upto"
+	if h.Above {
+		limit = "--hashlimit-above"
+	}
+	spec := IPTablesRuleSpec{"-m", "hashlimit", limit, h.Rate, "--hashlimit-burst", strconv.Itoa(h.Burst)}
+	if len(modes) > 0 {
+		spec = append(spec, "--hashlimit-mode", strings.Join(modes, ","))
+	}
+	return append(spec, "--hashlimit-name", h.Name), nil
+}
+
+func validateRate(rate string) error {
+	n, unit, ok := strings.Cut(rate, "/")
+	if v, err := strconv.Atoi(n); !ok || err != nil || v <= 0 {
+		return fmt.Errorf("invalid rate %q", rate)
+	}
+	switch unit {
+	case "second", "sec", "s", "minute", "min", "m", "hour", "h", "day", "d":
+		return nil
+	}
+	return fmt.Errorf("invalid rate unit in %q", rate)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NewConnmarkSaveRuleSpec returns the target tokens
+// "-j CONNMARK --save-mark --nfmask <mask> --ctmask <mask>", which copies the
+// mask bits of the packet mark into the connection mark.
+func NewConnmarkSaveRuleSpec(mask uint32) (IPTablesRuleSpec, error) {
+	return newConnmarkRuleSpec("--save-mark", mask)
+}
+
+// NewConnmarkRestoreRuleSpec returns the target tokens
+// "-j CONNMARK --restore-mark --nfmask <mask> --ctmask <mask>", which copies
+// the mask bits of the connection mark back into the packet mark. Use the
+// mask of the fwmark IPRuleConfig the restored mark is routed by.
+func NewConnmarkRestoreRuleSpec(mask uint32) (IPTablesRuleSpec, error) {
+	return newConnmarkRuleSpec("--restore-mark", mask)
+}
+
+func newConnmarkRuleSpec(op string, mask uint32) (IPTablesRuleSpec, error) {
+	if mask == 0 {
+		return nil, fmt.Errorf("CONNMARK %s requires a non-zero mask", op)
+	}
+	// iptables prints masks in lower-case hex, so match it for Exists.
+	m := fmt.Sprintf("0x%x", mask)
+	return IPTablesRuleSpec{"-j", "CONNMARK", op, "--nfmask", m, "--ctmask", m}, nil
+}
+
+// NewNotrackRuleSpec returns "-s|-d <cidr> -j CT --notrack", exempting traffic
+// from or to the IPv4 cidr from connection tracking. CT --notrack is the
+// current form of the deprecated NOTRACK target.
+func NewNotrackRuleSpec(cidr string, destination bool) (IPTablesRuleSpec, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOTRACK CIDR %q: %v", cidr, err)
+	}
+	if ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("NOTRACK CIDR %q is not IPv4", cidr)
+	}
+	flag := "-s"
+	if destination {
+		flag = "-d"
+	}
+	// iptables prints the network address, so use it for Exists to match.
+	return IPTablesRuleSpec{flag, ipNet.String(), "-j", "CT", "--notrack"}, nil
+}
+
+// NewNotrackConfigs returns the configs installing the NewNotrackRuleSpec rule
+// in the raw table's PREROUTING chain, for forwarded and incoming traffic, and
+// OUTPUT chain, for locally generated traffic.
+func NewNotrackConfigs(cidr string, destination bool) ([]Config, error) {
+	spec, err := NewNotrackRuleSpec(cidr, destination)
+	if err != nil {
+		return nil, err
+	}
+	return rawTableConfigs(spec.WithComment("notrack " + spec[1])), nil
+}
+
+// rawTableConfigs installs spec in the raw table's PREROUTING and OUTPUT
+// chains, which see every packet before connection tracking.
+func rawTableConfigs(spec IPTablesRuleSpec) []Config {
+	var configs []Config
+	for _, chain := range []string{preRoutingChain, "OUTPUT"} {
+		configs = append(configs, IPTablesRuleConfig{
+			Spec:      IPTablesChainSpec{TableName: tableRaw, ChainName: chain, IsDefaultChain: true, IPT: ipt},
+			RuleSpecs: []IPTablesRuleSpec{spec},
+			IPT:       ipt,
+		})
+	}
+	return configs
+}
+
+// maxCTZone is the largest conntrack zone, zones being 16 bits.
+const maxCTZone = 65535
+
+// Conntrack zone directions: both by default, or only the original or reply
+// direction of a connection.
+const (
+	CTZoneBoth  = ""
+	CTZoneOrig  = "orig"
+	CTZoneReply = "reply"
+)
+
+// NewCTZoneRuleSpec returns the target tokens "-j CT --zone <zone>", or
+// --zone-orig or --zone-reply for one direction, which track the matching
+// connections in their own conntrack zone, so that overlapping addresses of
+// different tenants do not collide. Zone 0 is the default zone.
+func NewCTZoneRuleSpec(zone int, direction string) (IPTablesRuleSpec, error) {
+	if zone < 0 || zone > maxCTZone {
+		return nil, fmt.Errorf("conntrack zone must be 0 to %d, got %d", maxCTZone, zone)
+	}
+	flag := "--zone"
+	switch direction {
+	case CTZoneBoth:
+	case CTZoneOrig, CTZoneReply:
+		flag += "-" + direction
+	default:
+		return nil, fmt.Errorf("invalid conntrack zone direction %q", direction)
+	}
+	return IPTablesRuleSpec{"-j", "CT", flag, strconv.Itoa(zone)}, nil
+}
+
+// NewCTZoneConfigs returns the configs assigning the traffic matched by match,
+// e.g. "-i veth-tenant1", to zone in the raw table's PREROUTING chain, for
+// forwarded and incoming traffic, and OUTPUT chain, for locally generated
+// traffic.
+func NewCTZoneConfigs(match IPTablesRuleSpec, zone int, direction string) ([]Config, error) {
+	target, err := NewCTZoneRuleSpec(zone, direction)
+	if err != nil {
+		return nil, err
+	}
+	spec := append(append(IPTablesRuleSpec{}, match...), target...)
+	return rawTableConfigs(spec.WithComment(fmt.Sprintf("ct zone %d", zone))), nil
+}
+
+// maxLogPrefixLen is the xt_LOG prefix limit, 30 bytes including the
+// terminating NUL.
+const maxLogPrefixLen = 29
+
+// NewLogRuleSpec returns the target tokens
+// "-j LOG --log-prefix <prefix> --log-level <level>", which logs matching
+// packets to the kernel log at the given syslog level (0-7) and continues.
+// Put it before the DROP rule whose packets it should log.
+func NewLogRuleSpec(prefix string, level int) (IPTablesRuleSpec, error) {
+	if prefix == "" || len(prefix) > maxLogPrefixLen {
+		return nil, fmt.Errorf("LOG prefix %q must be 1 to %d characters", prefix, maxLogPrefixLen)
+	}
+	if strings.ContainsAny(prefix, "\x00\n") {
+		return nil, fmt.Errorf("LOG prefix %q contains a NUL or newline", prefix)
+	}
+	if level < 0 || level > 7 {
+		return nil, fmt.Errorf("LOG level must be 0 to 7, got %d", level)
+	}
+	return IPTablesRuleSpec{"-j", "LOG", "--log-prefix", prefix, "--log-level", strconv.Itoa(level)}, nil
+}
+
+// Fixed MSS bounds: the IPv4 minimum MSS, and the largest MSS an IPv4 packet
+// of the maximum size can carry.
+const (
+	minFixedMSS = 536
+	maxFixedMSS = 65535 - 40
+)
+
+// tcpSYNMatch matches the TCP SYN packets the MSS option is negotiated in.
+var tcpSYNMatch = IPTablesRuleSpec{"-p", "tcp", "--tcp-flags", "SYN,RST", "SYN"}
+
+// NewClampMSSToPMTURuleSpec returns
+// "-p tcp --tcp-flags SYN,RST SYN -j TCPMSS --clamp-mss-to-pmtu", which lowers
+// the MSS of forwarded connections to fit the path MTU, e.g. for overlays.
+func NewClampMSSToPMTURuleSpec() IPTablesRuleSpec {
+	return append(append(IPTablesRuleSpec{}, tcpSYNMatch...), "-j", "TCPMSS", "--clamp-mss-to-pmtu")
+}
+
+// NewSetMSSRuleSpec returns
+// "-p tcp --tcp-flags SYN,RST SYN -j TCPMSS --set-mss <mss>".
+func NewSetMSSRuleSpec(mss int) (IPTablesRuleSpec, error) {
+	if mss < minFixedMSS || mss > maxFixedMSS {
+		return nil, fmt.Errorf("MSS must be %d to %d, got %d", minFixedMSS, maxFixedMSS, mss)
+	}
+	return append(append(IPTablesRuleSpec{}, tcpSYNMatch...), "-j", "TCPMSS", "--set-mss", strconv.Itoa(mss)), nil
+}
+
+// NewMSSClampConfig returns the config installing the TCPMSS rule spec,
+// marked with comment, in chain of the mangle table, normally FORWARD or
+// POSTROUTING. A chain that is not built in is created and owned by netd.
+func NewMSSClampConfig(chain string, spec IPTablesRuleSpec, comment string) IPTablesRuleConfig {
+	return IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableMangle, ChainName: chain, IsDefaultChain: builtinChains[chain], IPT: ipt},
+		RuleSpecs: []IPTablesRuleSpec{spec.WithComment(comment)},
+		IPT:       ipt,
+	}
+}
+
+// ConnlimitSpec describes a "-m connlimit" match on the number of concurrent
+// connections per group of source or destination addresses.
+type ConnlimitSpec struct {
+	Limit int
+	// Above matches connections beyond Limit instead of those up to it.
+	Above bool
+	// Mask is the prefix length addresses are grouped by, 0 to 32.
+	Mask int
+	// Destination groups connections by destination instead of source address.
+	Destination bool
+}
+
+// NewConnlimitRuleSpec returns the match tokens for c in the order iptables
+// prints them.
+func NewConnlimitRuleSpec(c ConnlimitSpec) (IPTablesRuleSpec, error) {
+	if c.Limit < 0 {
+		return nil, fmt.Errorf("connlimit must not be negative, got %d", c.Limit)
+	}
+	if c.Mask < 0 || c.Mask > 32 {
+		return nil, fmt.Errorf("connlimit mask must be 0 to 32, got %d", c.Mask)
+	}
+	limit := "--connlimit-upto"
+	if c.Above {
+		limit = "--connlimit-above"
+	}
+	addr := "--connlimit-saddr"
+	if c.Destination {
+		addr = "--connlimit-daddr"
+	}
+	return IPTablesRuleSpec{"-m", "connlimit", limit, strconv.Itoa(c.Limit), "--connlimit-mask", strconv.Itoa(c.Mask), addr}, nil
+}
+
+// ConnbytesSpec describes a "-m connbytes" match on how much a connection has
+// transferred so far.
+type ConnbytesSpec struct {
+	// From and To bound the counter. A zero To leaves the range open.
+	From, To uint64
+	// Dir is original, reply or both.
+	Dir string
+	// Mode is packets, bytes or avgpkt.
+	Mode string
+}
+
+// NewConnbytesRuleSpec returns the match tokens for c.
+func NewConnbytesRuleSpec(c ConnbytesSpec) (IPTablesRuleSpec, error) {
+	if c.To != 0 && c.To < c.From {
+		return nil, fmt.Errorf("invalid connbytes range %d:%d", c.From, c.To)
+	}
+	if c.Dir != "original" && c.Dir != "reply" && c.Dir != "both" {
+		return nil, fmt.Errorf("invalid connbytes direction %q", c.Dir)
+	}
+	if c.Mode != "packets" && c.Mode != "bytes" && c.Mode != "avgpkt" {
+		return nil, fmt.Errorf("invalid connbytes mode %q", c.Mode)
+	}
+	bytes := strconv.FormatUint(c.From, 10)
+	if c.To != 0 {
+		bytes += ":" + strconv.FormatUint(c.To, 10)
+	}
+	return IPTablesRuleSpec{"-m", "connbytes", "--connbytes", bytes, "--connbytes-mode", c.Mode, "--connbytes-dir", c.Dir}, nil
+}
+
+// RejectTCPReset answers TCP packets with a RST instead of an ICMP error.
+const RejectTCPReset = "tcp-reset"
+
+// rejectWithV4 and rejectWithV6 are the --reject-with types of iptables and
+// ip6tables, besides RejectTCPReset.
+var (
+	rejectWithV4 = []string{
+		"icmp-net-unreachable", "icmp-host-unreachable", "icmp-port-unreachable", "icmp-proto-unreachable",
+		"icmp-net-prohibited", "icmp-host-prohibited", "icmp-admin-prohibited",
+	}
+	rejectWithV6 = []string{
+		"icmp6-no-route", "icmp6-adm-prohibited", "icmp6-addr-unreachable", "icmp6-port-unreachable",
+		"icmp6-policy-fail", "icmp6-reject-route",
+	}
+)
+
+// NewRejectRuleSpec returns the target tokens
+// "-j REJECT --reject-with <rejectWith>", which drops matching packets and
+// tells the sender, e.g. with icmp-admin-prohibited. ipv6 selects the
+// ip6tables types. RejectTCPReset is only valid for TCP, so its spec also
+// matches "-p tcp".
+func NewRejectRuleSpec(rejectWith string, ipv6 bool) (IPTablesRuleSpec, error) {
+	if rejectWith == RejectTCPReset {
+		return IPTablesRuleSpec{"-p", "tcp", "-j", "REJECT", "--reject-with", rejectWith}, nil
+	}
+	types := rejectWithV4
+	if ipv6 {
+		types = rejectWithV6
+	}
+	if !containsString(types, rejectWith) {
+		return nil, fmt.Errorf("invalid reject-with type %q, must be %s or one of %v", rejectWith, RejectTCPReset, types)
+	}
+	return IPTablesRuleSpec{"-j", "REJECT", "--reject-with", rejectWith}, nil
+}
+
+// maxInterfaceNameLen is IFNAMSIZ without the terminating NUL.
+const maxInterfaceNameLen = 15
+
+// NewInterfaceMatchSpec returns "-i <name>", or "-o <name>" when output is
+// set, preceded by "!" when negate is set. A trailing "+" matches every
+// interface starting with the rest of name, e.g. "veth+"; iptables prints it
+// back unchanged, so such rules are found and deleted like any other. -i is
+// only valid in PREROUTING, INPUT and FORWARD, and -o in FORWARD, OUTPUT and
+// POSTROUTING, or chains jumped to from them.
+func NewInterfaceMatchSpec(name string, output, negate bool) (IPTablesRuleSpec, error) {
+	if err := validateInterfaceMatch(name); err != nil {
+		return nil, err
+	}
+	flag := "-i"
+	if output {
+		flag = "-o"
+	}
+	if negate {
+		return IPTablesRuleSpec{"!", flag, name}, nil
+	}
+	return IPTablesRuleSpec{flag, name}, nil
+}
+
+func validateInterfaceMatch(name string) error {
+	base := strings.TrimSuffix(name, "+")
+	if name == "" || name == "+" {
+		return fmt.Errorf("interface name %q must not be empty, use no match for every interface", name)
+	}
+	if len(name) > maxInterfaceNameLen {
+		return fmt.Errorf("interface name %q is longer than %d characters", name, maxInterfaceNameLen)
+	}
+	if strings.Contains(base, "+") {
+		return fmt.Errorf("interface name %q may only end in the + wildcard", name)
+	}
+	if base == "." || base == ".." || strings.ContainsAny(base, "/: \t\n!\"") {
+		return fmt.Errorf("invalid interface name %q", name)
+	}
+	return nil
+}
+
+// addrtypeTypes are the address types of "-m addrtype", in the order iptables
+// prints them.
+var addrtypeTypes = []string{
+	"UNSPEC", "UNICAST", "LOCAL", "BROADCAST", "ANYCAST", "MULTICAST",
+	"BLACKHOLE", "UNREACHABLE", "PROHIBIT", "THROW", "NAT", "XRESOLVE",
+}
+
+// NewAddrtypeRuleSpec returns the match tokens
+// "-m addrtype [!] --src-type|--dst-type <types>", matching packets whose
+// source, or destination when destination is set, has one of types, e.g.
+// LOCAL to exempt node-local traffic. The types are listed in iptables order
+// without duplicates.
+func NewAddrtypeRuleSpec(types []string, destination, negate bool) (IPTablesRuleSpec, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("addrtype requires at least one type")
+	}
+	for _, t := range types {
+		if !containsString(addrtypeTypes, t) {
+			return nil, fmt.Errorf("invalid addrtype type %q, must be one of %v", t, addrtypeTypes)
+		}
+	}
+	var ordered []string
+	for _, t := range addrtypeTypes {
+		if containsString(types, t) {
+			ordered = append(ordered, t)
+		}
+	}
+	spec := IPTablesRuleSpec{"-m", "addrtype"}
+	if negate {
+		spec = append(spec, "!")
+	}
+	flag := "--src-type"
+	if destination {
+		flag = "--dst-type"
+	}
+	return append(spec, flag, strings.Join(ordered, ",")), nil
+}
+
+// maxChainNameLen is XT_EXTENSION_MAXNAMELEN without the terminating NUL.
+const maxChainNameLen = 28
+
+// builtinTargets are the targets iptables reserves, which "-g" cannot name.
+var builtinTargets = []string{"ACCEPT", "DROP", "QUEUE", "RETURN"}
+
+// NewGotoRuleSpec returns the target tokens "-g <chain>". Unlike "-j", when
+// chain returns, processing continues after the rule that jumped to the
+// current chain instead of after this one, like a tail call. iptables keeps
+// the two apart, so a goto and a jump to the same chain are distinct rules
+// and deleting one leaves the other.
+func NewGotoRuleSpec(chain string) (IPTablesRuleSpec, error) {
+	if chain == "" || strings.HasPrefix(chain, "-") || strings.ContainsAny(chain, " \t\n") {
+		return nil, fmt.Errorf("invalid goto chain %q", chain)
+	}
+	if len(chain) > maxChainNameLen {
+		return nil, fmt.Errorf("goto chain %q is longer than %d characters", chain, maxChainNameLen)
+	}
+	if containsString(builtinTargets, chain) {
+		return nil, fmt.Errorf("goto requires a custom chain, got built-in target %s", chain)
+	}
+	return IPTablesRuleSpec{"-g", chain}, nil
+}
@@ -0,0 +1,571 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// ensureSpecRoundTrip applies spec twice to a fake default chain, then
+// removes it, checking the rule is added exactly once and fully deleted.
+func ensureSpecRoundTrip(t *testing.T, table string, spec IPTablesRuleSpec) {
+	t.Helper()
+	spec = spec.WithComment("test")
+	fakeIPT := FakeIPTable{
+		iptCache: make(map[string][]string),
+	}
+	c := IPTablesRuleConfig{
+		Spec: IPTablesChainSpec{
+			TableName:      table,
+			ChainName:      "GCP-TEST",
+			IsDefaultChain: true,
+			IPT:            fakeIPT,
+		},
+		RuleSpecs: []IPTablesRuleSpec{spec},
+		IPT:       fakeIPT,
+	}
+	c.Ensure(true)
+	c.Ensure(true)
+	if len(fakeIPT.iptCache["GCP-TEST"]) != 1 {
+		t.Errorf("rule %v should be appended once, got %v", spec, fakeIPT.iptCache["GCP-TEST"])
+	}
+	c.Ensure(false)
+	if len(fakeIPT.iptCache["GCP-TEST"]) != 0 {
+		t.Errorf("rule %v should be deleted, got %v", spec, fakeIPT.iptCache["GCP-TEST"])
+	}
+}
+
+func TestNewMultiportRuleSpec(t *testing.T) {
+	spec, err := NewMultiportRuleSpec("tcp", true, []string{"80", "443", "8000:8080"})
+	if err != nil {
+		t.Fatalf("NewMultiportRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-p", "tcp", "-m", "multiport", "--dports", "80,443,8000:8080"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewMultiportRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableFilter, append(spec, "-j", "ACCEPT"))
+
+	spec, _ = NewMultiportRuleSpec("udp", false, []string{"53"})
+	if spec[4] != "--sports" {
+		t.Errorf("source ports should use --sports, got %v", spec)
+	}
+}
+
+func TestNewMultiportRuleSpecInvalid(t *testing.T) {
+	over := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15:16"}
+	for _, tc := range []struct {
+		protocol string
+		ports    []string
+	}{
+		{"tcp", over},
+		{"tcp", nil},
+		{"tcp", []string{"70000"}},
+		{"tcp", []string{"90:80"}},
+		{"tcp", []string{"http"}},
+		{"icmp", []string{"80"}},
+	} {
+		if _, err := NewMultiportRuleSpec(tc.protocol, true, tc.ports); err == nil {
+			t.Errorf("NewMultiportRuleSpec(%q, %v) should fail", tc.protocol, tc.ports)
+		}
+	}
+
+	exactly15 := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14:15"}
+	if _, err := NewMultiportRuleSpec("tcp", true, exactly15); err != nil {
+		t.Errorf("NewMultiportRuleSpec should accept 15 ports: %v", err)
+	}
+}
+
+func TestNewSNATRuleSpec(t *testing.T) {
+	spec, err := NewSNATRuleSpec("10.128.0.5", nil)
+	if err != nil {
+		t.Fatalf("NewSNATRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-j", "SNAT", "--to-source", "10.128.0.5"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewSNATRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableNAT, append(IPTablesRuleSpec{"-s", "10.0.0.0/8"}, spec...))
+
+	if _, err := NewSNATRuleSpec("10.128.0.300", nil); err == nil {
+		t.Error("NewSNATRuleSpec should reject an invalid address")
+	}
+}
+
+func TestNewSNATRuleSpecLocal(t *testing.T) {
+	addrList := func(_ netlink.Link, family int) ([]netlink.Addr, error) {
+		return []netlink.Addr{{IPNet: &net.IPNet{IP: net.IPv4(10, 128, 0, 5), Mask: net.CIDRMask(32, 32)}}}, nil
+	}
+	if _, err := NewSNATRuleSpec("10.128.0.5", addrList); err != nil {
+		t.Errorf("NewSNATRuleSpec should accept a local address: %v", err)
+	}
+	if _, err := NewSNATRuleSpec("10.128.0.6", addrList); err == nil {
+		t.Error("NewSNATRuleSpec should reject an address that is not local")
+	}
+}
+
+func TestNewHashlimitRuleSpec(t *testing.T) {
+	spec, err := NewHashlimitRuleSpec(HashlimitSpec{
+		Name:  "netd-dns",
+		Rate:  "100/sec",
+		Burst: 20,
+		Mode:  []string{"dstport", "srcip"},
+		Above: true,
+	})
+	if err != nil {
+		t.Fatalf("NewHashlimitRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-m", "hashlimit", "--hashlimit-above", "100/sec", "--hashlimit-burst", "20",
+		"--hashlimit-mode", "srcip,dstport", "--hashlimit-name", "netd-dns"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewHashlimitRuleSpec = %v, want %v", spec, want)
+	}
+
+	// The same limit declared with modes in another order is the same rule.
+	again, _ := NewHashlimitRuleSpec(HashlimitSpec{Name: "netd-dns", Rate: "100/sec", Burst: 20,
+		Mode: []string{"srcip", "dstport"}, Above: true})
+	if !reflect.DeepEqual(spec, again) {
+		t.Errorf("mode order should not change the spec: %v vs %v", spec, again)
+	}
+	ensureSpecRoundTrip(t, tableFilter, append(spec, "-j", "DROP"))
+}
+
+func TestNewHashlimitRuleSpecInvalid(t *testing.T) {
+	for _, h := range []HashlimitSpec{
+		{Name: "", Rate: "1/s", Burst: 1},
+		{Name: "a-very-long-hashlimit-name", Rate: "1/s", Burst: 1},
+		{Name: "n", Rate: "fast", Burst: 1},
+		{Name: "n", Rate: "10/fortnight", Burst: 1},
+		{Name: "n", Rate: "0/s", Burst: 1},
+		{Name: "n", Rate: "1/s", Burst: 0},
+		{Name: "n", Rate: "1/s", Burst: 1, Mode: []string{"srcmac"}},
+	} {
+		if _, err := NewHashlimitRuleSpec(h); err == nil {
+			t.Errorf("NewHashlimitRuleSpec(%+v) should fail", h)
+		}
+	}
+}
+
+func TestConnmarkRuleSpecs(t *testing.T) {
+	save, err := NewConnmarkSaveRuleSpec(hairpinMask)
+	if err != nil {
+		t.Fatalf("NewConnmarkSaveRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-j", "CONNMARK", "--save-mark", "--nfmask", "0x4000", "--ctmask", "0x4000"}
+	if !reflect.DeepEqual(save, want) {
+		t.Errorf("NewConnmarkSaveRuleSpec = %v, want %v", save, want)
+	}
+	restore, err := NewConnmarkRestoreRuleSpec(hairpinMask)
+	if err != nil {
+		t.Fatalf("NewConnmarkRestoreRuleSpec returned error: %v", err)
+	}
+	want = IPTablesRuleSpec{"-j", "CONNMARK", "--restore-mark", "--nfmask", "0x4000", "--ctmask", "0x4000"}
+	if !reflect.DeepEqual(restore, want) {
+		t.Errorf("NewConnmarkRestoreRuleSpec = %v, want %v", restore, want)
+	}
+	ensureSpecRoundTrip(t, tableMangle, save)
+	ensureSpecRoundTrip(t, tableMangle, restore)
+
+	if _, err := NewConnmarkSaveRuleSpec(0); err == nil {
+		t.Error("a zero mask should be rejected")
+	}
+}
+
+func TestNotrackRuleSpecs(t *testing.T) {
+	spec, err := NewNotrackRuleSpec("10.0.0.5/8", true)
+	if err != nil {
+		t.Fatalf("NewNotrackRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-d", "10.0.0.0/8", "-j", "CT", "--notrack"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewNotrackRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableRaw, spec)
+
+	configs, err := NewNotrackConfigs("172.16.0.0/12", false)
+	if err != nil {
+		t.Fatalf("NewNotrackConfigs returned error: %v", err)
+	}
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	var chains []string
+	for _, c := range configs {
+		rc := c.(IPTablesRuleConfig)
+		if rc.Spec.TableName != tableRaw || !rc.Spec.IsDefaultChain || rc.RuleSpecs[0][0] != "-s" {
+			t.Errorf("unexpected NOTRACK config %+v", rc)
+		}
+		chains = append(chains, rc.Spec.ChainName)
+		rc.Spec.IPT, rc.IPT = fakeIPT, fakeIPT
+		for i := 0; i < 2; i++ {
+			if err := rc.Ensure(true); err != nil {
+				t.Fatalf("Ensure(true) returned error: %v", err)
+			}
+		}
+		if err := rc.Ensure(false); err != nil {
+			t.Fatalf("Ensure(false) returned error: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(chains, []string{"PREROUTING", "OUTPUT"}) {
+		t.Errorf("NOTRACK chains = %v", chains)
+	}
+	if len(fakeIPT.iptCache["PREROUTING"]) != 0 || len(fakeIPT.iptCache["OUTPUT"]) != 0 {
+		t.Errorf("NOTRACK rules should be removed, got %v", fakeIPT.iptCache)
+	}
+
+	for _, cidr := range []string{"10.0.0.1", "fd00::/64", "bogus"} {
+		if _, err := NewNotrackRuleSpec(cidr, true); err == nil {
+			t.Errorf("NewNotrackRuleSpec(%q) should fail", cidr)
+		}
+	}
+}
+
+func TestCTZoneRuleSpecs(t *testing.T) {
+	for _, tc := range []struct {
+		zone      int
+		direction string
+		want      IPTablesRuleSpec
+	}{
+		{5, CTZoneBoth, IPTablesRuleSpec{"-j", "CT", "--zone", "5"}},
+		{0, CTZoneBoth, IPTablesRuleSpec{"-j", "CT", "--zone", "0"}},
+		{65535, CTZoneOrig, IPTablesRuleSpec{"-j", "CT", "--zone-orig", "65535"}},
+		{7, CTZoneReply, IPTablesRuleSpec{"-j", "CT", "--zone-reply", "7"}},
+	} {
+		spec, err := NewCTZoneRuleSpec(tc.zone, tc.direction)
+		if err != nil {
+			t.Errorf("NewCTZoneRuleSpec(%d, %q) returned error: %v", tc.zone, tc.direction, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, tc.want) {
+			t.Errorf("NewCTZoneRuleSpec(%d, %q) = %v, want %v", tc.zone, tc.direction, spec, tc.want)
+		}
+		ensureSpecRoundTrip(t, tableRaw, append(IPTablesRuleSpec{"-s", "10.0.0.0/8"}, spec...))
+	}
+	for _, tc := range []struct {
+		zone      int
+		direction string
+	}{
+		{-1, CTZoneBoth},
+		{65536, CTZoneBoth},
+		{5, "both"},
+	} {
+		if _, err := NewCTZoneRuleSpec(tc.zone, tc.direction); err == nil {
+			t.Errorf("NewCTZoneRuleSpec(%d, %q) should fail", tc.zone, tc.direction)
+		}
+	}
+
+	configs, err := NewCTZoneConfigs(IPTablesRuleSpec{"-i", "veth-tenant1"}, 10, CTZoneBoth)
+	if err != nil {
+		t.Fatalf("NewCTZoneConfigs returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-i", "veth-tenant1", "-j", "CT", "--zone", "10", "-m", "comment", "--comment", "netd: ct zone 10"}
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	var chains []string
+	for _, c := range configs {
+		rc := c.(IPTablesRuleConfig)
+		if rc.Spec.TableName != tableRaw || !rc.Spec.IsDefaultChain || !reflect.DeepEqual(rc.RuleSpecs, []IPTablesRuleSpec{want}) {
+			t.Errorf("unexpected CT zone config %+v", rc)
+		}
+		chains = append(chains, rc.Spec.ChainName)
+		rc.Spec.IPT, rc.IPT = fakeIPT, fakeIPT
+		for i := 0; i < 2; i++ {
+			if err := rc.Ensure(true); err != nil {
+				t.Fatalf("Ensure(true) returned error: %v", err)
+			}
+		}
+		if n := len(fakeIPT.iptCache[rc.Spec.ChainName]); n != 1 {
+			t.Errorf("%s has %d rules after repeated Ensure, want 1", rc.Spec.ChainName, n)
+		}
+		if err := rc.Ensure(false); err != nil {
+			t.Fatalf("Ensure(false) returned error: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(chains, []string{"PREROUTING", "OUTPUT"}) {
+		t.Errorf("CT zone chains = %v", chains)
+	}
+	if len(fakeIPT.iptCache["PREROUTING"]) != 0 || len(fakeIPT.iptCache["OUTPUT"]) != 0 {
+		t.Errorf("CT zone rules should be removed, got %v", fakeIPT.iptCache)
+	}
+}
+
+func TestNewInterfaceMatchSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		output, negate bool
+		want           IPTablesRuleSpec
+	}{
+		{"eth0", false, false, IPTablesRuleSpec{"-i", "eth0"}},
+		{"eth0", true, false, IPTablesRuleSpec{"-o", "eth0"}},
+		{"veth+", false, false, IPTablesRuleSpec{"-i", "veth+"}},
+		{"gke+", true, true, IPTablesRuleSpec{"!", "-o", "gke+"}},
+		{"abcdefghijklmn+", false, false, IPTablesRuleSpec{"-i", "abcdefghijklmn+"}},
+		{"eth0.100", false, true, IPTablesRuleSpec{"!", "-i", "eth0.100"}},
+	} {
+		spec, err := NewInterfaceMatchSpec(tc.name, tc.output, tc.negate)
+		if err != nil {
+			t.Errorf("NewInterfaceMatchSpec(%q, %v, %v) returned error: %v", tc.name, tc.output, tc.negate, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, tc.want) {
+			t.Errorf("NewInterfaceMatchSpec(%q, %v, %v) = %v, want %v", tc.name, tc.output, tc.negate, spec, tc.want)
+		}
+		ensureSpecRoundTrip(t, tableNAT, append(spec, "-j", "MASQUERADE"))
+	}
+	for _, name := range []string{"", "+", "abcdefghijklmnop", "et+h0", "eth++", "eth 0", "eth/0", "eth0:1", "..", "!eth0"} {
+		if _, err := NewInterfaceMatchSpec(name, false, false); err == nil {
+			t.Errorf("NewInterfaceMatchSpec(%q) should fail", name)
+		}
+	}
+}
+
+func TestNewAddrtypeRuleSpec(t *testing.T) {
+	for _, tc := range []struct {
+		types               []string
+		destination, negate bool
+		want                IPTablesRuleSpec
+	}{
+		{[]string{"LOCAL"}, true, false, IPTablesRuleSpec{"-m", "addrtype", "--dst-type", "LOCAL"}},
+		{[]string{"LOCAL"}, true, true, IPTablesRuleSpec{"-m", "addrtype", "!", "--dst-type", "LOCAL"}},
+		{[]string{"MULTICAST", "BROADCAST", "MULTICAST"}, false, false, IPTablesRuleSpec{"-m", "addrtype", "--src-type", "BROADCAST,MULTICAST"}},
+	} {
+		spec, err := NewAddrtypeRuleSpec(tc.types, tc.destination, tc.negate)
+		if err != nil {
+			t.Errorf("NewAddrtypeRuleSpec(%v, %v, %v) returned error: %v", tc.types, tc.destination, tc.negate, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, tc.want) {
+			t.Errorf("NewAddrtypeRuleSpec(%v, %v, %v) = %v, want %v", tc.types, tc.destination, tc.negate, spec, tc.want)
+		}
+		ensureSpecRoundTrip(t, tableNAT, append(spec, "-j", "RETURN"))
+	}
+	for _, types := range [][]string{nil, {"local"}, {"LOCAL", "HOST"}, {""}} {
+		if _, err := NewAddrtypeRuleSpec(types, true, false); err == nil {
+			t.Errorf("NewAddrtypeRuleSpec(%q) should fail", types)
+		}
+	}
+}
+
+func TestNewLogRuleSpec(t *testing.T) {
+	spec, err := NewLogRuleSpec("netd-drop: ", 4)
+	if err != nil {
+		t.Fatalf("NewLogRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-j", "LOG", "--log-prefix", "netd-drop: ", "--log-level", "4"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewLogRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableFilter, append(IPTablesRuleSpec{"-s", "10.0.0.0/8"}, spec...))
+
+	if _, err := NewLogRuleSpec(strings.Repeat("p", maxLogPrefixLen), 0); err != nil {
+		t.Errorf("a %d character prefix should be accepted: %v", maxLogPrefixLen, err)
+	}
+	for _, tc := range []struct {
+		prefix string
+		level  int
+	}{
+		{strings.Repeat("p", maxLogPrefixLen+1), 4},
+		{"", 4},
+		{"bad\nprefix", 4},
+		{"netd-drop: ", 8},
+		{"netd-drop: ", -1},
+	} {
+		if _, err := NewLogRuleSpec(tc.prefix, tc.level); err == nil {
+			t.Errorf("NewLogRuleSpec(%q, %d) should fail", tc.prefix, tc.level)
+		}
+	}
+}
+
+func TestNewRejectRuleSpec(t *testing.T) {
+	for _, tc := range []struct {
+		types []string
+		ipv6  bool
+	}{
+		{rejectWithV4, false},
+		{rejectWithV6, true},
+	} {
+		for _, rejectWith := range tc.types {
+			spec, err := NewRejectRuleSpec(rejectWith, tc.ipv6)
+			if err != nil {
+				t.Errorf("NewRejectRuleSpec(%q, %v) returned error: %v", rejectWith, tc.ipv6, err)
+				continue
+			}
+			want := IPTablesRuleSpec{"-j", "REJECT", "--reject-with", rejectWith}
+			if !reflect.DeepEqual(spec, want) {
+				t.Errorf("NewRejectRuleSpec(%q, %v) = %v, want %v", rejectWith, tc.ipv6, spec, want)
+			}
+		}
+		spec, err := NewRejectRuleSpec(RejectTCPReset, tc.ipv6)
+		want := IPTablesRuleSpec{"-p", "tcp", "-j", "REJECT", "--reject-with", "tcp-reset"}
+		if err != nil || !reflect.DeepEqual(spec, want) {
+			t.Errorf("NewRejectRuleSpec(tcp-reset, %v) = %v, %v; want %v", tc.ipv6, spec, err, want)
+		}
+	}
+
+	spec, err := NewRejectRuleSpec("icmp-admin-prohibited", false)
+	if err != nil {
+		t.Fatalf("NewRejectRuleSpec returned error: %v", err)
+	}
+	ensureSpecRoundTrip(t, tableFilter, append(IPTablesRuleSpec{"-d", "169.254.169.254/32"}, spec...))
+
+	for _, tc := range []struct {
+		rejectWith string
+		ipv6       bool
+	}{
+		{"", false},
+		{"icmp-admin-prohibited", true},
+		{"icmp6-adm-prohibited", false},
+		{"admin-prohibited", false},
+		{"ICMP-ADMIN-PROHIBITED", false},
+	} {
+		if _, err := NewRejectRuleSpec(tc.rejectWith, tc.ipv6); err == nil {
+			t.Errorf("NewRejectRuleSpec(%q, %v) should fail", tc.rejectWith, tc.ipv6)
+		}
+	}
+}
+
+func TestMSSClampRuleSpecs(t *testing.T) {
+	pmtu := NewClampMSSToPMTURuleSpec()
+	want := IPTablesRuleSpec{"-p", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", "TCPMSS", "--clamp-mss-to-pmtu"}
+	if !reflect.DeepEqual(pmtu, want) {
+		t.Errorf("NewClampMSSToPMTURuleSpec = %v, want %v", pmtu, want)
+	}
+	fixed, err := NewSetMSSRuleSpec(1400)
+	if err != nil {
+		t.Fatalf("NewSetMSSRuleSpec returned error: %v", err)
+	}
+	want = IPTablesRuleSpec{"-p", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", "TCPMSS", "--set-mss", "1400"}
+	if !reflect.DeepEqual(fixed, want) {
+		t.Errorf("NewSetMSSRuleSpec = %v, want %v", fixed, want)
+	}
+	ensureSpecRoundTrip(t, tableMangle, pmtu)
+	ensureSpecRoundTrip(t, tableMangle, fixed)
+
+	for _, mss := range []int{0, minFixedMSS - 1, maxFixedMSS + 1} {
+		if _, err := NewSetMSSRuleSpec(mss); err == nil {
+			t.Errorf("NewSetMSSRuleSpec(%d) should fail", mss)
+		}
+	}
+
+	c := NewMSSClampConfig("FORWARD", pmtu, "clamp mss")
+	if c.Spec.TableName != tableMangle || !c.Spec.IsDefaultChain || !hasOwnershipMarker(c.RuleSpecs[0]) {
+		t.Errorf("unexpected MSS clamp config %+v", c)
+	}
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	c.Spec.IPT, c.IPT = fakeIPT, fakeIPT
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fakeIPT.iptCache["FORWARD"]) != 1 {
+		t.Errorf("expected a single TCPMSS rule in FORWARD, got %v", fakeIPT.iptCache["FORWARD"])
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fakeIPT.iptCache["FORWARD"]) != 0 {
+		t.Errorf("the TCPMSS rule should be removed, got %v", fakeIPT.iptCache["FORWARD"])
+	}
+}
+
+func TestNewConnlimitRuleSpec(t *testing.T) {
+	spec, err := NewConnlimitRuleSpec(ConnlimitSpec{Limit: 100, Above: true, Mask: 24})
+	if err != nil {
+		t.Fatalf("NewConnlimitRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-m", "connlimit", "--connlimit-above", "100", "--connlimit-mask", "24", "--connlimit-saddr"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewConnlimitRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableFilter, append(append(IPTablesRuleSpec{"-p", "tcp", "--dport", "443"}, spec...), "-j", "REJECT"))
+
+	spec, err = NewConnlimitRuleSpec(ConnlimitSpec{Limit: 5, Mask: 32, Destination: true})
+	if err != nil || spec[2] != "--connlimit-upto" || spec[6] != "--connlimit-daddr" {
+		t.Errorf("NewConnlimitRuleSpec(upto, daddr) = %v, %v", spec, err)
+	}
+
+	for _, c := range []ConnlimitSpec{{Limit: -1, Mask: 32}, {Limit: 1, Mask: 33}, {Limit: 1, Mask: -1}} {
+		if _, err := NewConnlimitRuleSpec(c); err == nil {
+			t.Errorf("NewConnlimitRuleSpec(%+v) should fail", c)
+		}
+	}
+}
+
+func TestNewConnbytesRuleSpec(t *testing.T) {
+	spec, err := NewConnbytesRuleSpec(ConnbytesSpec{From: 500000, Dir: "both", Mode: "bytes"})
+	if err != nil {
+		t.Fatalf("NewConnbytesRuleSpec returned error: %v", err)
+	}
+	want := IPTablesRuleSpec{"-m", "connbytes", "--connbytes", "500000", "--connbytes-mode", "bytes", "--connbytes-dir", "both"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewConnbytesRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableMangle, append(spec, "-j", "DSCP", "--set-dscp", "8"))
+
+	spec, err = NewConnbytesRuleSpec(ConnbytesSpec{From: 10, To: 20, Dir: "original", Mode: "packets"})
+	if err != nil || spec[3] != "10:20" {
+		t.Errorf("NewConnbytesRuleSpec(10:20) = %v, %v", spec, err)
+	}
+
+	for _, c := range []ConnbytesSpec{
+		{From: 20, To: 10, Dir: "both", Mode: "bytes"},
+		{Dir: "sideways", Mode: "bytes"},
+		{Dir: "both", Mode: "bits"},
+	} {
+		if _, err := NewConnbytesRuleSpec(c); err == nil {
+			t.Errorf("NewConnbytesRuleSpec(%+v) should fail", c)
+		}
+	}
+}
+
+func TestNewGotoRuleSpec(t *testing.T) {
+	spec, err := NewGotoRuleSpec("NETD-MASQ")
+	if err != nil {
+		t.Fatalf("NewGotoRuleSpec returned error: %v", err)
+	}
+	if want := (IPTablesRuleSpec{"-g", "NETD-MASQ"}); !reflect.DeepEqual(spec, want) {
+		t.Errorf("NewGotoRuleSpec = %v, want %v", spec, want)
+	}
+	ensureSpecRoundTrip(t, tableNAT, append(IPTablesRuleSpec{"-d", "10.0.0.0/8"}, spec...))
+
+	// A goto and a jump to the same chain are distinct rules.
+	jump := IPTablesRuleSpec{"-d", "10.0.0.0/8", "-j", "NETD-MASQ"}.WithComment("test")
+	fakeIPT := FakeIPTable{iptCache: map[string][]string{"GCP-TEST": {strings.Join(jump, " ")}}}
+	c := IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableNAT, ChainName: "GCP-TEST", IsDefaultChain: true, IPT: fakeIPT},
+		RuleSpecs: []IPTablesRuleSpec{append(IPTablesRuleSpec{"-d", "10.0.0.0/8"}, spec...).WithComment("test")},
+		IPT:       fakeIPT,
+	}
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) = %v, %v; want the goto added next to the jump", changed, err)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if got := fakeIPT.iptCache["GCP-TEST"]; !reflect.DeepEqual(got, []string{strings.Join(jump, " ")}) {
+		t.Errorf("removing the goto should leave the jump, got %v", got)
+	}
+
+	for _, chain := range []string{"", "RETURN", "ACCEPT", "-j", "NETD CHAIN", "NETD-A-CHAIN-NAME-LONGER-THAN-28"} {
+		if _, err := NewGotoRuleSpec(chain); err == nil {
+			t.Errorf("NewGotoRuleSpec(%q) should fail", chain)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// KernelVersion is the major.minor.patch of a kernel release.
+type KernelVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v KernelVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is the same as or newer than o.
+func (v KernelVersion) AtLeast(o KernelVersion) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// ParseKernelVersion parses a release such as "5.15.0-1045-gke" or "6.1".
+// Anything after the numeric components is ignored.
+func ParseKernelVersion(release string) (KernelVersion, error) {
+	release, _, _ = strings.Cut(release, "-")
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return KernelVersion{}, fmt.Errorf("invalid kernel version %q", release)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		// Strip suffixes such as "0+" or "0_rc1" from the last component.
+		end := strings.IndexFunc(p, func(r rune) bool { return r < '0' || r > '9' })
+		if end >= 0 {
+			p = p[:end]
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return KernelVersion{}, fmt.Errorf("invalid kernel version %q", release)
+		}
+		nums[i] = n
+	}
+	return KernelVersion{nums[0], nums[1], nums[2]}, nil
+}
+
+// CurrentKernelVersion returns the version of the running kernel from uname.
+func CurrentKernelVersion() (KernelVersion, error) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return KernelVersion{}, err
+	}
+	return ParseKernelVersion(unix.ByteSliceToString(uts.Release[:]))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	for release, want := range map[string]KernelVersion{
+		"5.15.0-1045-gke": {5, 15, 0},
+		"6.1":             {6, 1, 0},
+		"4.19.287+":       {4, 19, 287},
+		"6.18.44-fc-v130": {6, 18, 44},
+	} {
+		got, err := ParseKernelVersion(release)
+		if err != nil || got != want {
+			t.Errorf("ParseKernelVersion(%q) = %v, %v; want %v", release, got, err, want)
+		}
+	}
+	for _, release := range []string{"", "5", "five.four"} {
+		if _, err := ParseKernelVersion(release); err == nil {
+			t.Errorf("ParseKernelVersion(%q) should fail", release)
+		}
+	}
+	if !(KernelVersion{5, 10, 0}).AtLeast(KernelVersion{5, 4, 200}) || (KernelVersion{4, 19, 0}).AtLeast(KernelVersion{5, 4, 0}) {
+		t.Error("AtLeast compares versions incorrectly")
+	}
+}
+
+func TestSysctlConfigMinKernel(t *testing.T) {
+	mSysctl := make(map[string]string)
+	c := SysctlConfig{
+		Key:          "net.ipv4.tcp_migrate_req",
+		Value:        "1",
+		DefaultValue: "0",
+		SysctlFunc: func(name string, params ...string) (string, error) {
+			if len(params) == 0 {
+				return mSysctl[name], nil
+			}
+			mSysctl[name] = params[0]
+			return "", nil
+		},
+		MinKernel:         "5.14",
+		KernelVersionFunc: func() (KernelVersion, error) { return KernelVersion{5, 10, 0}, nil },
+	}
+	if changed, err := c.EnsureChanged(true); err != nil || changed {
+		t.Errorf("EnsureChanged on an old kernel = %v, %v; want a skip", changed, err)
+	}
+	if _, ok := mSysctl[c.Key]; ok {
+		t.Error("the sysctl should not be written on an old kernel")
+	}
+
+	c.KernelVersionFunc = func() (KernelVersion, error) { return KernelVersion{5, 15, 0}, nil }
+	if changed, err := c.EnsureChanged(true); err != nil || !changed || mSysctl[c.Key] != "1" {
+		t.Errorf("EnsureChanged on a new kernel = %v, %v; want the sysctl applied", changed, err)
+	}
+}
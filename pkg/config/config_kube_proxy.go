@@ -0,0 +1,134 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// KubeProxyMode is the proxy mode kube-proxy runs in.
+type KubeProxyMode string
+
+// The kube-proxy modes netd distinguishes.
+const (
+	KubeProxyModeIPTables KubeProxyMode = "iptables"
+	KubeProxyModeIPVS     KubeProxyMode = "ipvs"
+	KubeProxyModeNFTables KubeProxyMode = "nftables"
+)
+
+// KubeProxyModeURL is kube-proxy's metrics endpoint reporting its mode.
+const KubeProxyModeURL = "http://127.0.0.1:10249/proxyMode"
+
+// KubeProxyModeDetector returns the mode kube-proxy currently runs in.
+type KubeProxyModeDetector func() (KubeProxyMode, error)
+
+// NewKubeProxyModeDetector creates a KubeProxyModeDetector querying the
+// /proxyMode endpoint at url, e.g. KubeProxyModeURL.
+func NewKubeProxyModeDetector(url string) KubeProxyModeDetector {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func() (KubeProxyMode, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("kube-proxy mode %s: unexpected status %s", url, resp.Status)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return KubeProxyMode(strings.TrimSpace(string(body))), nil
+	}
+}
+
+// CachedFor returns a detector reusing the last mode d detected for maxAge.
+// With maxAge below the reconcile interval, each reconcile detects the mode
+// once and all of its gated configs see the same mode. Errors are not cached.
+func (d KubeProxyModeDetector) CachedFor(maxAge time.Duration) KubeProxyModeDetector {
+	var mu sync.Mutex
+	var mode KubeProxyMode
+	var detected time.Time
+	return func() (KubeProxyMode, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !detected.IsZero() && time.Since(detected) < maxAge {
+			return mode, nil
+		}
+		m, err := d()
+		if err != nil {
+			return "", err
+		}
+		mode, detected = m, time.Now()
+		return mode, nil
+	}
+}
+
+// KubeProxyModeGatedConfig ensures Config only while kube-proxy runs in one of
+// Modes, e.g. to install a rule that would conflict with kube-proxy's own in
+// ipvs mode only in iptables mode, and removes it otherwise. The mode is
+// detected on every Ensure(true), see CachedFor to share one detection between
+// configs. While it cannot be detected, Config is left as it is and Ensure
+// fails with ErrDeferred. Ensure(false) removes Config without detecting it.
+type KubeProxyModeGatedConfig struct {
+	Config
+	Modes    []KubeProxyMode
+	Detector KubeProxyModeDetector
+}
+
+// Dynamic KubeProxyModeGatedConfig
+func (KubeProxyModeGatedConfig) Dynamic() bool {
+	return true
+}
+
+// Chains KubeProxyModeGatedConfig
+func (c KubeProxyModeGatedConfig) Chains() []IPTablesChainSpec {
+	return configChains(c.Config)
+}
+
+// Ensure KubeProxyModeGatedConfig
+func (c KubeProxyModeGatedConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged KubeProxyModeGatedConfig
+func (c KubeProxyModeGatedConfig) EnsureChanged(enabled bool) (bool, error) {
+	if !enabled {
+		return EnsureChanged(c.Config, false)
+	}
+	mode, err := c.Detector()
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to detect the kube-proxy mode for %v: %v", ErrDeferred, c.Config, err)
+	}
+	match := false
+	for _, m := range c.Modes {
+		match = match || m == mode
+	}
+	if !match {
+		glog.V(2).Infof("kube-proxy runs in %s mode, removing %v", mode, c.Config)
+	}
+	return EnsureChanged(c.Config, match)
+}
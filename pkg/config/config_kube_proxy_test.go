@@ -0,0 +1,109 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKubeProxyModeGatedConfig(t *testing.T) {
+	var log []string
+	var mode KubeProxyMode
+	var detectErr error
+	c := KubeProxyModeGatedConfig{
+		Config:   recordingConfig{"masq", &log, nil},
+		Modes:    []KubeProxyMode{KubeProxyModeIPTables, KubeProxyModeNFTables},
+		Detector: func() (KubeProxyMode, error) { return mode, detectErr },
+	}
+	for _, m := range []KubeProxyMode{KubeProxyModeIPTables, KubeProxyModeIPVS, KubeProxyModeNFTables} {
+		mode = m
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) in %s mode returned error: %v", m, err)
+		}
+	}
+	// An undetectable mode leaves the config alone, but does not stop its
+	// removal.
+	detectErr = errors.New("connection refused")
+	if err := c.Ensure(true); !errors.Is(err, ErrDeferred) {
+		t.Errorf("Ensure(true) without a mode returned %v, want ErrDeferred", err)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Errorf("Ensure(false) without a mode returned error: %v", err)
+	}
+
+	want := []string{"masq:true", "masq:false", "masq:true", "masq:false"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v, want %v", log, want)
+	}
+}
+
+func TestKubeProxyModeDetectorCachedFor(t *testing.T) {
+	calls := 0
+	var detectErr error
+	detector := KubeProxyModeDetector(func() (KubeProxyMode, error) {
+		calls++
+		return KubeProxyModeIPVS, detectErr
+	})
+
+	detectErr = errors.New("connection refused")
+	cached := detector.CachedFor(time.Hour)
+	if _, err := cached(); err == nil {
+		t.Fatal("the detector error should be returned")
+	}
+	detectErr = nil
+	for i := 0; i < 3; i++ {
+		if mode, err := cached(); err != nil || mode != KubeProxyModeIPVS {
+			t.Fatalf("cached detector returned %q, %v; want ipvs", mode, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("detector called %d times, want the error retried and the mode then reused", calls)
+	}
+
+	calls = 0
+	uncached := detector.CachedFor(0)
+	uncached()
+	uncached()
+	if calls != 2 {
+		t.Errorf("detector called %d times with a zero maxAge, want 2", calls)
+	}
+}
+
+func TestKubeProxyModeDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxyMode" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "ipvs")
+	}))
+	defer server.Close()
+
+	mode, err := NewKubeProxyModeDetector(server.URL + "/proxyMode")()
+	if err != nil || mode != KubeProxyModeIPVS {
+		t.Errorf("detected %q, %v; want ipvs", mode, err)
+	}
+	if _, err := NewKubeProxyModeDetector(server.URL + "/missing")(); err == nil {
+		t.Error("a 404 should fail detection")
+	}
+}
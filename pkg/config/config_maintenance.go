@@ -0,0 +1,88 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrScheduled is returned, wrapped, by a disruptive config that Set.Ensure
+// holds back until its maintenance window opens. It is an ErrDeferred.
+var ErrScheduled = fmt.Errorf("%w until the maintenance window", ErrDeferred)
+
+// MaintenanceWindow is a daily time range, such as 02:00 for 3h, during which
+// the disruptive configs of a Set may be applied.
+type MaintenanceWindow struct {
+	// Start is the time of day the window opens at, as an offset from
+	// midnight in Location.
+	Start time.Duration
+	// Length is how long the window stays open. It may run past midnight.
+	Length time.Duration
+	// Days restricts the window to the days it opens on. Empty means every
+	// day.
+	Days []time.Weekday
+	// Location defaults to UTC.
+	Location *time.Location
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Open reports whether the window is open now.
+func (w MaintenanceWindow) Open() bool {
+	now := time.Now
+	if w.Now != nil {
+		now = w.Now
+	}
+	return w.OpenAt(now())
+}
+
+// OpenAt reports whether the window is open at t.
+func (w MaintenanceWindow) OpenAt(t time.Time) bool {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	// A window opened yesterday may still be open.
+	for _, days := range []int{0, -1} {
+		y, m, d := t.AddDate(0, 0, days).Date()
+		start := time.Date(y, m, d, 0, 0, 0, 0, loc).Add(w.Start)
+		if len(w.Days) > 0 && !containsWeekday(w.Days, start.Weekday()) {
+			continue
+		}
+		if !t.Before(start) && t.Before(start.Add(w.Length)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduled reports whether c must wait for the window of a Set to apply.
+func (w *MaintenanceWindow) scheduled(c Config, enabled bool) bool {
+	n, ok := c.(NamedConfig)
+	return w != nil && enabled && ok && n.Disruptive && !w.Open()
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowOpenAt(t *testing.T) {
+	// 23:00 to 01:00 UTC, opening on Saturdays only.
+	w := MaintenanceWindow{Start: 23 * time.Hour, Length: 2 * time.Hour, Days: []time.Weekday{time.Saturday}}
+	sat := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		t    time.Time
+		open bool
+	}{
+		{sat.Add(22 * time.Hour), false},
+		{sat.Add(23 * time.Hour), true},
+		{sat.Add(24*time.Hour + 59*time.Minute), true},
+		{sat.Add(25 * time.Hour), false},
+		// The window opening on Friday night does not exist.
+		{sat.Add(30 * time.Minute), false},
+		{sat.Add(6*24*time.Hour + 23*time.Hour), false},
+	} {
+		if got := w.OpenAt(tc.t); got != tc.open {
+			t.Errorf("OpenAt(%v) = %v, want %v", tc.t, got, tc.open)
+		}
+	}
+
+	// Location shifts the window.
+	est := time.FixedZone("EST", -5*3600)
+	w = MaintenanceWindow{Start: 2 * time.Hour, Length: time.Hour, Location: est}
+	if !w.OpenAt(time.Date(2026, 10, 17, 7, 30, 0, 0, time.UTC)) {
+		t.Error("02:30 EST should be in the window")
+	}
+	if w.OpenAt(time.Date(2026, 10, 17, 2, 30, 0, 0, time.UTC)) {
+		t.Error("02:30 UTC should not be in the window")
+	}
+}
+
+func TestSetEnsureMaintenanceWindow(t *testing.T) {
+	var log []string
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs: []Config{
+			recordingConfig{"sysctl", &log, nil},
+			NamedConfig{Config: recordingConfig{"flush", &log, nil}, Name: "flush", Disruptive: true},
+		},
+		Window: &MaintenanceWindow{Start: 2 * time.Hour, Length: time.Hour, Now: func() time.Time { return now }},
+	}
+	var summary ReconcileSummary
+	s.OnReconcileComplete = func(rs ReconcileSummary) { summary = rs }
+
+	err := s.Ensure()
+	if !errors.Is(err, ErrScheduled) || !errors.Is(err, ErrDeferred) {
+		t.Errorf("Ensure() outside the window = %v, want ErrScheduled", err)
+	}
+	if want := []string{"sysctl:true"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v outside the window, want %v", log, want)
+	}
+	if summary.Deferred != 1 || summary.Failed != 0 {
+		t.Errorf("summary = %+v, want the disruptive config deferred", summary)
+	}
+
+	log = nil
+	now = time.Date(2026, 10, 18, 2, 15, 0, 0, time.UTC)
+	if err := s.Ensure(); err != nil {
+		t.Errorf("Ensure() inside the window returned error: %v", err)
+	}
+	if want := []string{"sysctl:true", "flush:true"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v inside the window, want %v", log, want)
+	}
+
+	// Removal does not wait for the window.
+	log = nil
+	now = now.Add(6 * time.Hour)
+	s.Enabled = false
+	if err := s.Ensure(); err != nil {
+		t.Errorf("Ensure() of the disabled Set returned error: %v", err)
+	}
+	if want := []string{"flush:false", "sysctl:false"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v when disabled, want %v", log, want)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+type memTotaler func() (uint64, error)
+
+// MemoryRatioSysctlConfig sets a sysctl to the node's total memory in bytes
+// times Ratio, clamped to [Min, Max], so the value follows the node size. For
+// example, Ratio 1.0/16384 gives one conntrack entry per 16KiB of memory.
+type MemoryRatioSysctlConfig struct {
+	Key          string
+	Ratio        float64
+	Min, Max     int64
+	DefaultValue string
+	SysctlFunc   sysctler
+	// MemTotalFunc defaults to reading the total memory with sysinfo(2).
+	MemTotalFunc memTotaler
+}
+
+// Ensure MemoryRatioSysctlConfig
+func (m MemoryRatioSysctlConfig) Ensure(enabled bool) error {
+	_, err := m.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged MemoryRatioSysctlConfig
+func (m MemoryRatioSysctlConfig) EnsureChanged(enabled bool) (bool, error) {
+	s := SysctlConfig{Key: m.Key, DefaultValue: m.DefaultValue, SysctlFunc: m.SysctlFunc}
+	if enabled {
+		value, err := m.value()
+		if err != nil {
+			return false, err
+		}
+		s.Value = strconv.FormatInt(value, 10)
+	}
+	return s.EnsureChanged(enabled)
+}
+
+// value computes the sysctl value from the current total memory.
+func (m MemoryRatioSysctlConfig) value() (int64, error) {
+	if m.Ratio <= 0 || m.Min > m.Max {
+		return 0, fmt.Errorf("invalid memory ratio %v or bounds [%d, %d] for %s", m.Ratio, m.Min, m.Max, m.Key)
+	}
+	memTotal := m.MemTotalFunc
+	if memTotal == nil {
+		memTotal = sysinfoMemTotal
+	}
+	mem, err := memTotal()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read total memory for %s: %w", m.Key, err)
+	}
+	value := float64(mem) * m.Ratio
+	switch {
+	case value < float64(m.Min):
+		return m.Min, nil
+	case value > float64(m.Max):
+		return m.Max, nil
+	}
+	return int64(value), nil
+}
+
+func sysinfoMemTotal() (uint64, error) {
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		return 0, err
+	}
+	return uint64(info.Totalram) * uint64(info.Unit), nil
+}
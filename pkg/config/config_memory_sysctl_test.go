@@ -0,0 +1,68 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+)
+
+func TestMemoryRatioSysctlConfig(t *testing.T) {
+	const gib = 1 << 30
+	mSysctl := make(map[string]string)
+	var mem uint64
+	c := MemoryRatioSysctlConfig{
+		Key:          sysctlConntrackMax,
+		Ratio:        1.0 / 16384,
+		Min:          131072,
+		Max:          1048576,
+		DefaultValue: "262144",
+		SysctlFunc: func(name string, params ...string) (string, error) {
+			if len(params) == 0 {
+				return mSysctl[name], nil
+			}
+			mSysctl[name] = params[0]
+			return "", nil
+		},
+		MemTotalFunc: func() (uint64, error) { return mem, nil },
+	}
+
+	for _, tc := range []struct {
+		mem  uint64
+		want string
+	}{
+		{8 * gib, "524288"},
+		{1 * gib, "131072"},   // 65536 clamped to Min
+		{64 * gib, "1048576"}, // 4194304 clamped to Max
+	} {
+		mem = tc.mem
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) with %d bytes returned error: %v", tc.mem, err)
+		}
+		if got := mSysctl[c.Key]; got != tc.want {
+			t.Errorf("with %d GiB, %s = %s, want %s", tc.mem/gib, c.Key, got, tc.want)
+		}
+	}
+
+	if err := c.Ensure(false); err != nil || mSysctl[c.Key] != "262144" {
+		t.Errorf("Ensure(false) = %v, %s = %s; want the default", err, c.Key, mSysctl[c.Key])
+	}
+
+	c.Min, c.Max = 10, 1
+	if err := c.Ensure(true); err == nil {
+		t.Error("Min above Max should be rejected")
+	}
+}
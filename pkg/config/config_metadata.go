@@ -0,0 +1,151 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	gceMetadataHost    = "metadata.google.internal"
+	gceMetadataTimeout = 5 * time.Second
+)
+
+// MetadataProvider looks up instance metadata by path relative to the
+// metadata root, e.g. "instance/network-interfaces/0/network".
+type MetadataProvider interface {
+	Get(path string) (string, error)
+}
+
+// GCEMetadata is a MetadataProvider backed by the GCE metadata server.
+type GCEMetadata struct {
+	Host   string
+	Client *http.Client
+}
+
+// NewGCEMetadata creates a GCEMetadata for the metadata server of this VM.
+func NewGCEMetadata() *GCEMetadata {
+	return &GCEMetadata{
+		Host:   gceMetadataHost,
+		Client: &http.Client{Timeout: gceMetadataTimeout},
+	}
+}
+
+// Get GCEMetadata
+func (g *GCEMetadata) Get(path string) (string, error) {
+	url := fmt.Sprintf("http://%s/computeMetadata/v1/%s", g.Host, strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata %s: unexpected status %s", path, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// MetadataGate enables a Set or Config only while the metadata value at Path
+// satisfies Match. It is evaluated on every reconcile.
+type MetadataGate struct {
+	Provider MetadataProvider
+	Path     string
+	Match    func(value string) bool
+}
+
+// Evaluate reports whether the gate is open. known is false when the metadata
+// could not be read, in which case callers should leave the system untouched.
+func (g MetadataGate) Evaluate() (open, known bool) {
+	value, err := g.Provider.Get(g.Path)
+	if err != nil {
+		glog.Warningf("failed to read metadata %s, treating it as unknown: %v", g.Path, err)
+		return false, false
+	}
+	return g.Match(value), true
+}
+
+// MetadataGatedConfig ensures Config only while Gate is open, and removes it
+// while the gate is closed. While the gate is unknown, Config is left as it is
+// and Ensure fails with ErrDeferred. Ensure(false) removes Config without
+// evaluating the gate.
+type MetadataGatedConfig struct {
+	Config
+	Gate MetadataGate
+}
+
+// Dynamic MetadataGatedConfig
+func (MetadataGatedConfig) Dynamic() bool {
+	return true
+}
+
+// Chains MetadataGatedConfig
+func (c MetadataGatedConfig) Chains() []IPTablesChainSpec {
+	return configChains(c.Config)
+}
+
+// Ensure MetadataGatedConfig
+func (c MetadataGatedConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged MetadataGatedConfig
+func (c MetadataGatedConfig) EnsureChanged(enabled bool) (bool, error) {
+	if !enabled {
+		return EnsureChanged(c.Config, false)
+	}
+	open, known := c.Gate.Evaluate()
+	if !known {
+		glog.V(2).Infof("skipping %v: metadata %s is unknown", c.Config, c.Gate.Path)
+		return false, fmt.Errorf("%w: metadata %s is unknown for %v", ErrDeferred, c.Gate.Path, c.Config)
+	}
+	return EnsureChanged(c.Config, open)
+}
+
+// Resolve returns s with Enabled narrowed by its Canary and Gate. ok is false
+// when the gate could not be evaluated and the Set should be skipped this
+// reconcile.
+func (s Set) Resolve() (resolved Set, ok bool) {
+	if s.Enabled && s.Canary != nil && !s.Canary.Selected(s.FeatureName) {
+		s.Enabled = false
+	}
+	if s.Gate == nil || !s.Enabled {
+		return s, true
+	}
+	open, known := s.Gate.Evaluate()
+	if !known {
+		glog.Infof("skipping %s: metadata %s is unknown", s.FeatureName, s.Gate.Path)
+		return s, false
+	}
+	s.Enabled = open
+	return s, true
+}
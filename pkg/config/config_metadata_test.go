@@ -0,0 +1,128 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeMetadata is a MetadataProvider serving values from a map. Paths that
+// are missing fail as if the metadata server were unreachable.
+type fakeMetadata map[string]string
+
+func (f fakeMetadata) Get(path string) (string, error) {
+	v, ok := f[path]
+	if !ok {
+		return "", errors.New("metadata server unreachable")
+	}
+	return v, nil
+}
+
+func TestSetMetadataGate(t *testing.T) {
+	const aliasPath = "instance/network-interfaces/0/ip-aliases/0"
+	md := fakeMetadata{aliasPath: "10.8.0.0/24"}
+	var log []string
+	s := Set{
+		Enabled:     true,
+		FeatureName: "gated",
+		Configs:     []Config{recordingConfig{name: "a", log: &log}},
+		Gate: &MetadataGate{
+			Provider: md,
+			Path:     aliasPath,
+			Match:    func(v string) bool { return v != "" },
+		},
+	}
+
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure failed: %v", err)
+	}
+	if want := []string{"a:true"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("open gate: got %v, want %v", log, want)
+	}
+
+	log = nil
+	md[aliasPath] = ""
+	if err := ApplyAll([]Set{s}); err != nil {
+		t.Fatalf("ApplyAll failed: %v", err)
+	}
+	if want := []string{"a:false"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("closed gate: got %v, want %v", log, want)
+	}
+
+	log = nil
+	delete(md, aliasPath)
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure with unknown metadata failed: %v", err)
+	}
+	if len(log) != 0 {
+		t.Errorf("unknown gate should leave the set untouched, got %v", log)
+	}
+}
+
+func TestMetadataGatedConfig(t *testing.T) {
+	md := fakeMetadata{"instance/attributes/feature": "on"}
+	var log []string
+	c := MetadataGatedConfig{
+		Config: recordingConfig{name: "a", log: &log},
+		Gate: MetadataGate{
+			Provider: md,
+			Path:     "instance/attributes/feature",
+			Match:    func(v string) bool { return v == "on" },
+		},
+	}
+	_ = c.Ensure(true)
+	md["instance/attributes/feature"] = "off"
+	_ = c.Ensure(true)
+	delete(md, "instance/attributes/feature")
+	if changed, err := c.EnsureChanged(true); changed || !errors.Is(err, ErrDeferred) {
+		t.Errorf("EnsureChanged(true) with an unknown gate = %v, %v, want false, ErrDeferred", changed, err)
+	}
+	_ = c.Ensure(false)
+	if want := []string{"a:true", "a:false", "a:false"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("got %v, want %v", log, want)
+	}
+}
+
+func TestGCEMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/computeMetadata/v1/instance/network-interfaces/0/network" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("projects/1/networks/default\n"))
+	}))
+	defer srv.Close()
+
+	g := NewGCEMetadata()
+	g.Host = strings.TrimPrefix(srv.URL, "http://")
+	got, err := g.Get("instance/network-interfaces/0/network")
+	if err != nil || got != "projects/1/networks/default" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if _, err := g.Get("instance/missing"); err == nil {
+		t.Error("Get of a missing path should fail")
+	}
+}
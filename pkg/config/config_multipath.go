@@ -0,0 +1,204 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/metrics"
+)
+
+// MultipathRouteConfig keeps a multipath route balanced over its live
+// nexthops. A nexthop whose link is down or gone, or which the kernel flagged
+// RTNH_F_DEAD, is left out of the installed route until its link is up again,
+// and the full set is restored then. While no nexthop is live, the full set
+// is kept rather than removing the route.
+type MultipathRouteConfig struct {
+	// Route lists every nexthop in MultiPath.
+	Route        netlink.Route
+	RouteList    routeLister
+	RouteReplace routeReplacer
+	RouteDel     routeDeler
+	LinkByIndex  linkByIndexer
+}
+
+// NewMultipathRouteConfig creates a MultipathRouteConfig for a route to dst in
+// table over nexthops, each of which needs a link.
+func NewMultipathRouteConfig(dst net.IPNet, table int, nexthops []*netlink.NexthopInfo) (MultipathRouteConfig, error) {
+	if len(nexthops) < 2 {
+		return MultipathRouteConfig{}, fmt.Errorf("multipath route to %v needs at least two nexthops, got %d", dst.String(), len(nexthops))
+	}
+	for _, nh := range nexthops {
+		if nh.LinkIndex <= 0 {
+			return MultipathRouteConfig{}, fmt.Errorf("nexthop %v of the route to %v has no link", nh, dst.String())
+		}
+	}
+	return MultipathRouteConfig{
+		Route: netlink.Route{
+			Dst:       &dst,
+			Table:     table,
+			MultiPath: nexthops,
+			Protocol:  RouteProtocolNetd,
+		},
+		RouteList:    netlink.RouteListFiltered,
+		RouteReplace: netlink.RouteReplace,
+		RouteDel:     netlink.RouteDel,
+		LinkByIndex:  netlink.LinkByIndex,
+	}, nil
+}
+
+// Dynamic MultipathRouteConfig
+func (MultipathRouteConfig) Dynamic() bool {
+	return true
+}
+
+// Ensure MultipathRouteConfig
+func (m MultipathRouteConfig) Ensure(enabled bool) error {
+	_, err := m.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged MultipathRouteConfig. The route is only replaced when its set
+// of live nexthops changed.
+func (m MultipathRouteConfig) EnsureChanged(enabled bool) (bool, error) {
+	installed, err := m.installed()
+	if err != nil {
+		return false, err
+	}
+	if !enabled {
+		if installed == nil {
+			return false, nil
+		}
+		if err := m.RouteDel(installed); err != nil {
+			if errors.Is(err, syscall.ESRCH) {
+				return false, nil
+			}
+			metrics.RecordNetlinkError("route_del", err)
+			return false, err
+		}
+		diffLogf("deleted multipath ip route %s", describeRoute(m.Route))
+		return true, nil
+	}
+
+	live, err := m.liveNexthops(installed)
+	if err != nil {
+		return false, err
+	}
+	if installed != nil && sameNexthops(installed.MultiPath, live) {
+		return false, nil
+	}
+	route := m.Route
+	route.MultiPath = live
+	err = m.RouteReplace(&route)
+	metrics.RecordNetlinkError("route_replace", err)
+	if err != nil {
+		return false, err
+	}
+	diffLogf("replaced multipath ip route %s over %d of %d nexthops", describeRoute(route), len(live), len(m.Route.MultiPath))
+	return true, nil
+}
+
+// installed returns the route to Route.Dst installed in its table, or nil.
+func (m MultipathRouteConfig) installed() (*netlink.Route, error) {
+	filter := &netlink.Route{Table: routeTable(m.Route)}
+	routes, err := m.RouteList(routeFamily(m.Route), filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		metrics.RecordNetlinkError("route_list", err)
+		return nil, fmt.Errorf("failed to list routes in table %d: %w", routeTable(m.Route), err)
+	}
+	for i := range routes {
+		if routeMatches(m.Route, routes[i]) {
+			return &routes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// liveNexthops returns the nexthops of Route whose link is up and which
+// installed does not flag dead, or all of them if none is live.
+func (m MultipathRouteConfig) liveNexthops(installed *netlink.Route) ([]*netlink.NexthopInfo, error) {
+	var live []*netlink.NexthopInfo
+	for _, nh := range m.Route.MultiPath {
+		up, err := m.linkUp(nh.LinkIndex)
+		if err != nil {
+			return nil, err
+		}
+		if up && !flaggedDead(installed, nh) {
+			live = append(live, nh)
+		}
+	}
+	if len(live) == 0 {
+		glog.Warningf("every nexthop of the route to %v is dead, keeping all of them", m.Route.Dst)
+		return m.Route.MultiPath, nil
+	}
+	return live, nil
+}
+
+func (m MultipathRouteConfig) linkUp(index int) (bool, error) {
+	link, err := m.LinkByIndex(index)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get link %d: %w", index, err)
+	}
+	attrs := link.Attrs()
+	return attrs.Flags&net.FlagUp != 0 && attrs.OperState != netlink.OperDown, nil
+}
+
+// flaggedDead reports whether installed carries nh flagged RTNH_F_DEAD.
+func flaggedDead(installed *netlink.Route, nh *netlink.NexthopInfo) bool {
+	if installed == nil {
+		return false
+	}
+	for _, got := range installed.MultiPath {
+		if sameNexthop(got, nh) {
+			return got.Flags&unix.RTNH_F_DEAD != 0
+		}
+	}
+	return false
+}
+
+// sameNexthops compares two nexthop sets by link and gateway, ignoring order
+// and the flags the kernel sets.
+func sameNexthops(a, b []*netlink.NexthopInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			found = found || sameNexthop(x, y)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sameNexthop(a, b *netlink.NexthopInfo) bool {
+	return a.LinkIndex == b.LinkIndex && a.Gw.Equal(b.Gw)
+}
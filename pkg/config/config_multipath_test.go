@@ -0,0 +1,149 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakeLinks maps link indexes to whether the link is up.
+type fakeLinks map[int]bool
+
+func (f fakeLinks) linkByIndex(index int) (netlink.Link, error) {
+	up, ok := f[index]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	attrs := netlink.NewLinkAttrs()
+	attrs.Index = index
+	attrs.OperState = netlink.OperUp
+	if up {
+		attrs.Flags = net.FlagUp
+	} else {
+		attrs.OperState = netlink.OperDown
+	}
+	return &netlink.Dummy{LinkAttrs: attrs}, nil
+}
+
+func TestMultipathRouteConfigDeadNexthops(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.8.0.0/16")
+	nh := func(link int, gw string) *netlink.NexthopInfo {
+		return &netlink.NexthopInfo{LinkIndex: link, Gw: net.ParseIP(gw)}
+	}
+	m, err := NewMultipathRouteConfig(*dst, customRouteTable, []*netlink.NexthopInfo{nh(2, "10.0.0.1"), nh(3, "10.0.1.1"), nh(4, "10.0.2.1")})
+	if err != nil {
+		t.Fatalf("NewMultipathRouteConfig returned error: %v", err)
+	}
+	routes := &fakeRouteTable{}
+	links := fakeLinks{2: true, 3: true, 4: true}
+	var replaced int
+	m.RouteList = routes.list
+	m.RouteDel = routes.del
+	m.RouteReplace = func(route *netlink.Route) error {
+		replaced++
+		// The kernel reports its own copy of each nexthop.
+		r := *route
+		r.MultiPath = nil
+		for _, nh := range route.MultiPath {
+			c := *nh
+			r.MultiPath = append(r.MultiPath, &c)
+		}
+		return routes.replace(&r)
+	}
+	m.LinkByIndex = links.linkByIndex
+
+	installed := func() []*netlink.NexthopInfo {
+		t.Helper()
+		if len(routes.routes) != 1 {
+			t.Fatalf("expected one installed route, got %v", routes.routes)
+		}
+		return routes.routes[0].MultiPath
+	}
+	ensure := func(wantChanged bool, wantLinks ...int) {
+		t.Helper()
+		changed, err := m.EnsureChanged(true)
+		if err != nil || changed != wantChanged {
+			t.Fatalf("EnsureChanged(true) = %v, %v; want %v, nil", changed, err, wantChanged)
+		}
+		var got []int
+		for _, nh := range installed() {
+			got = append(got, nh.LinkIndex)
+		}
+		if len(got) != len(wantLinks) {
+			t.Fatalf("installed nexthops on links %v, want %v", got, wantLinks)
+		}
+		for i := range got {
+			if got[i] != wantLinks[i] {
+				t.Fatalf("installed nexthops on links %v, want %v", got, wantLinks)
+			}
+		}
+	}
+
+	ensure(true, 2, 3, 4)
+	ensure(false, 2, 3, 4)
+
+	// The kernel flags the nexthop on link 3 dead when the link goes down.
+	installed()[1].Flags |= unix.RTNH_F_DEAD
+	links[3] = false
+	ensure(true, 2, 4)
+	ensure(false, 2, 4)
+
+	// A link that disappears is dead too.
+	delete(links, 4)
+	ensure(true, 2)
+
+	// Every nexthop dead: the full set is kept instead of dropping the route.
+	links[2] = false
+	ensure(true, 2, 3, 4)
+
+	// Recovered links bring their nexthops back.
+	links[2], links[3], links[4] = true, true, true
+	ensure(false, 2, 3, 4)
+	installed()[1].Flags |= unix.RTNH_F_DEAD
+	ensure(true, 2, 4)
+	ensure(true, 2, 3, 4)
+	if replaced != 6 {
+		t.Errorf("the route was replaced %d times, want 6", replaced)
+	}
+
+	if err := m.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(routes.routes) != 0 {
+		t.Errorf("Ensure(false) should delete the route, got %v", routes.routes)
+	}
+	if changed, err := m.EnsureChanged(false); err != nil || changed {
+		t.Errorf("a second EnsureChanged(false) = %v, %v; want false, nil", changed, err)
+	}
+
+	m.LinkByIndex = func(int) (netlink.Link, error) { return nil, syscall.EPERM }
+	if err := m.Ensure(true); err == nil {
+		t.Error("Ensure(true) should fail when links cannot be read")
+	}
+
+	if _, err := NewMultipathRouteConfig(*dst, customRouteTable, []*netlink.NexthopInfo{nh(2, "10.0.0.1")}); err == nil {
+		t.Error("a single nexthop should be rejected")
+	}
+	if _, err := NewMultipathRouteConfig(*dst, customRouteTable, []*netlink.NexthopInfo{nh(2, "10.0.0.1"), nh(0, "10.0.1.1")}); err == nil {
+		t.Error("a nexthop without a link should be rejected")
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vishvananda/netlink"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/metrics"
+)
+
+func netlinkErrorCount(t *testing.T, op, errno string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.NetlinkErrors.WithLabelValues(op, errno).Write(&m); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestNetlinkErrorMetrics(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	route := IPRouteConfig{
+		Route:    netlink.Route{Dst: dst},
+		RouteAdd: func(*netlink.Route) error { return syscall.EPERM },
+		RouteDel: func(*netlink.Route) error { return syscall.ESRCH },
+	}
+	before := netlinkErrorCount(t, "route_add", "EPERM")
+	route.Ensure(true)
+	if got := netlinkErrorCount(t, "route_add", "EPERM"); got != before+1 {
+		t.Errorf("route_add EPERM count = %v, want %v", got, before+1)
+	}
+	// An expected ESRCH on delete is not a failure.
+	before = netlinkErrorCount(t, "route_del", "ESRCH")
+	route.Ensure(false)
+	if got := netlinkErrorCount(t, "route_del", "ESRCH"); got != before {
+		t.Errorf("route_del ESRCH should not be counted, got %v want %v", got, before)
+	}
+	route.RouteAdd = func(*netlink.Route) error { return os.ErrExist }
+	before = netlinkErrorCount(t, "route_add", "other")
+	route.Ensure(true)
+	if got := netlinkErrorCount(t, "route_add", "other"); got != before {
+		t.Errorf("an existing route should not be counted, got %v want %v", got, before)
+	}
+
+	rule := newRuleConfig(100)
+	rule.RuleList = func(int) ([]netlink.Rule, error) { return nil, errors.New("dump failed") }
+	before = netlinkErrorCount(t, "rule_list", "other")
+	rule.Ensure(true)
+	if got := netlinkErrorCount(t, "rule_list", "other"); got != before+1 {
+		t.Errorf("rule_list count = %v, want %v", got, before+1)
+	}
+
+	rules := &fakeRuleList{}
+	rule = rules.config(newRuleConfig(100))
+	rule.RuleAdd = func(*netlink.Rule) error { return syscall.ENOBUFS }
+	before = netlinkErrorCount(t, "rule_add", "ENOBUFS")
+	rule.Ensure(true)
+	if got := netlinkErrorCount(t, "rule_add", "ENOBUFS"); got != before+1 {
+		t.Errorf("rule_add ENOBUFS count = %v, want %v", got, before+1)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+)
+
+// ErrNodeNotReady is returned, wrapped, by a NodeReadyGatedConfig held back
+// while the node is NotReady or cordoned. It is an ErrDeferred.
+var ErrNodeNotReady = fmt.Errorf("%w until the node is ready", ErrDeferred)
+
+// NodeReadyGatedConfig applies Config only while Ready reports the node
+// healthy, so that netd does not compound a node problem with disruptive
+// changes. Application is deferred, not failed, while the node is NotReady
+// or its readiness cannot be read. Removal proceeds at any time.
+type NodeReadyGatedConfig struct {
+	Config
+	Ready func() (bool, error)
+}
+
+// Dynamic NodeReadyGatedConfig
+func (NodeReadyGatedConfig) Dynamic() bool {
+	return true
+}
+
+// Chains NodeReadyGatedConfig
+func (c NodeReadyGatedConfig) Chains() []IPTablesChainSpec {
+	return configChains(c.Config)
+}
+
+// Ensure NodeReadyGatedConfig
+func (c NodeReadyGatedConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged NodeReadyGatedConfig
+func (c NodeReadyGatedConfig) EnsureChanged(enabled bool) (bool, error) {
+	if enabled {
+		ready, err := c.Ready()
+		if err != nil {
+			return false, fmt.Errorf("%w: %v: failed to read the node's readiness: %v", ErrNodeNotReady, c.Config, err)
+		}
+		if !ready {
+			return false, fmt.Errorf("%w: %v", ErrNodeNotReady, c.Config)
+		}
+	}
+	return EnsureChanged(c.Config, enabled)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNodeReadyGatedConfig(t *testing.T) {
+	var log []string
+	var ready bool
+	var readErr error
+	c := NodeReadyGatedConfig{
+		Config: recordingConfig{"route", &log, nil},
+		Ready:  func() (bool, error) { return ready, readErr },
+	}
+
+	if err := c.Ensure(true); !errors.Is(err, ErrNodeNotReady) || !errors.Is(err, ErrDeferred) {
+		t.Errorf("Ensure(true) on a NotReady node = %v, want ErrNodeNotReady", err)
+	}
+	readErr = errors.New("connection refused")
+	if err := c.Ensure(true); !errors.Is(err, ErrNodeNotReady) {
+		t.Errorf("Ensure(true) with unknown readiness = %v, want ErrNodeNotReady", err)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Errorf("Ensure(false) on a NotReady node returned error: %v", err)
+	}
+	readErr = nil
+	ready = true
+	if err := c.Ensure(true); err != nil {
+		t.Errorf("Ensure(true) on a Ready node returned error: %v", err)
+	}
+
+	want := []string{"route:false", "route:true"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ensured %v, want %v", log, want)
+	}
+
+	// Set.Ensure counts the held-back config as deferred.
+	ready = false
+	var summary ReconcileSummary
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{c}, OnReconcileComplete: func(r ReconcileSummary) { summary = r }}
+	s.Ensure()
+	if summary.Deferred != 1 || summary.Failed != 0 {
+		t.Errorf("summary = %+v, want one deferred config", summary)
+	}
+}
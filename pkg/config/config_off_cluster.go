@@ -0,0 +1,59 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// NewOffClusterNATConfig creates an ordered IPTablesRuleConfig in chain of
+// the nat table that first RETURNs traffic to each of clusterCIDRs, the pod
+// and service ranges, and then applies natSpec, e.g. "-j MASQUERADE", so only
+// traffic leaving the cluster is NATed. Rules are marked with a comment so
+// they can be reordered in a built-in chain such as POSTROUTING.
+func NewOffClusterNATConfig(chain string, clusterCIDRs []string, natSpec IPTablesRuleSpec) (IPTablesRuleConfig, error) {
+	if len(clusterCIDRs) == 0 {
+		return IPTablesRuleConfig{}, fmt.Errorf("at least one cluster CIDR is required")
+	}
+	if len(natSpec) == 0 {
+		return IPTablesRuleConfig{}, fmt.Errorf("a NAT rule is required")
+	}
+	var specs []IPTablesRuleSpec
+	for _, cidr := range clusterCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return IPTablesRuleConfig{}, fmt.Errorf("invalid cluster CIDR %q: %v", cidr, err)
+		}
+		if ipNet.IP.To4() == nil {
+			return IPTablesRuleConfig{}, fmt.Errorf("cluster CIDR %q is not IPv4", cidr)
+		}
+		// iptables prints the network address, so use it for Exists to match.
+		specs = append(specs, IPTablesRuleSpec{"-d", ipNet.String(), "-j", "RETURN"}.WithComment("in-cluster "+ipNet.String()))
+	}
+	if !hasOwnershipMarker(natSpec) {
+		natSpec = natSpec.WithComment("off-cluster nat")
+	}
+	specs = append(specs, natSpec)
+
+	return IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableNAT, ChainName: chain, IsDefaultChain: builtinChains[chain], IPT: ipt},
+		RuleSpecs: specs,
+		IPT:       ipt,
+		Ordered:   true,
+	}, nil
+}
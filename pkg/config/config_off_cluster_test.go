@@ -0,0 +1,70 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOffClusterNATConfig(t *testing.T) {
+	c, err := NewOffClusterNATConfig(postRoutingChain, []string{"10.4.0.0/14", "10.8.0.1/20"}, IPTablesRuleSpec{"-j", "MASQUERADE"})
+	if err != nil {
+		t.Fatalf("NewOffClusterNATConfig returned error: %v", err)
+	}
+	if c.Spec.TableName != tableNAT || !c.Spec.IsDefaultChain || !c.Ordered {
+		t.Errorf("expected an ordered config in the built-in nat chain, got %+v", c.Spec)
+	}
+
+	// A rule of another owner, and a NAT rule left ahead of the RETURNs.
+	fakeIPT := FakeIPTable{iptCache: map[string][]string{
+		postRoutingChain: {"-j MASQUERADE -m comment --comment netd: off-cluster nat", "-j KUBE-POSTROUTING"},
+	}}
+	c.Spec.IPT, c.IPT = fakeIPT, fakeIPT
+	for i := 0; i < 2; i++ {
+		changed, err := c.EnsureChanged(true)
+		if err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+		// Once in order, the rules are left alone rather than rewritten.
+		if changed != (i == 0) {
+			t.Errorf("EnsureChanged(true) #%d = %v, want a change only the first time", i, changed)
+		}
+	}
+	want := []string{
+		"-j KUBE-POSTROUTING",
+		"-d 10.4.0.0/14 -j RETURN -m comment --comment netd: in-cluster 10.4.0.0/14",
+		"-d 10.8.0.0/20 -j RETURN -m comment --comment netd: in-cluster 10.8.0.0/20",
+		"-j MASQUERADE -m comment --comment netd: off-cluster nat",
+	}
+	if got := fakeIPT.iptCache[postRoutingChain]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("chain contents = %q, want %q", got, want)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if got := fakeIPT.iptCache[postRoutingChain]; len(got) != 1 {
+		t.Errorf("only the foreign rule should remain, got %q", got)
+	}
+
+	for _, cidrs := range [][]string{nil, {"bogus"}, {"fd00::/8"}} {
+		if _, err := NewOffClusterNATConfig(postRoutingChain, cidrs, IPTablesRuleSpec{"-j", "MASQUERADE"}); err == nil {
+			t.Errorf("cluster CIDRs %q should be rejected", cidrs)
+		}
+	}
+}
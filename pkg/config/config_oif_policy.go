@@ -0,0 +1,126 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+)
+
+// OifPolicyConfig ties an ip rule matching an output interface to the routes
+// of the table it looks up, so neither is left behind without the other. The
+// routes are applied before the rule, and the rule is removed before them.
+type OifPolicyConfig struct {
+	Rule   IPRuleConfig
+	Routes []IPRouteConfig
+}
+
+// NewOifPolicyConfig creates an OifPolicyConfig for
+// "ip rule add pref <priority> oif <oif> lookup <table>" and the given routes,
+// which are installed in table.
+func NewOifPolicyConfig(oif string, table, priority int, routes []netlink.Route) (OifPolicyConfig, error) {
+	if oif == "" {
+		return OifPolicyConfig{}, fmt.Errorf("an output interface is required")
+	}
+	if table <= 0 {
+		return OifPolicyConfig{}, fmt.Errorf("invalid routing table %d", table)
+	}
+	if len(routes) == 0 {
+		return OifPolicyConfig{}, fmt.Errorf("table %d for oif %s needs at least one route", table, oif)
+	}
+	rule := newRuleConfig(table)
+	rule.Rule.OifName = oif
+	rule.Rule.Priority = priority
+
+	c := OifPolicyConfig{Rule: rule}
+	for _, route := range routes {
+		route.Table = table
+		route.Protocol = RouteProtocolNetd
+		c.Routes = append(c.Routes, IPRouteConfig{
+			Route:        route,
+			RouteAdd:     netlink.RouteAdd,
+			RouteDel:     netlink.RouteDel,
+			RouteList:    netlink.RouteListFiltered,
+			RouteReplace: netlink.RouteReplace,
+		})
+	}
+	return c, nil
+}
+
+// Dynamic OifPolicyConfig
+func (o OifPolicyConfig) Dynamic() bool {
+	if o.Rule.Dynamic() {
+		return true
+	}
+	for _, r := range o.Routes {
+		if r.Dynamic() {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure OifPolicyConfig
+func (o OifPolicyConfig) Ensure(enabled bool) error {
+	_, err := o.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged OifPolicyConfig. When a route cannot be added, the routes
+// added by this call are removed again and the rule is not added. Every part
+// is attempted on removal.
+func (o OifPolicyConfig) EnsureChanged(enabled bool) (bool, error) {
+	if !enabled {
+		changed, err := o.Rule.EnsureChanged(false)
+		errs := []error{err}
+		for _, r := range o.Routes {
+			routeChanged, err := r.EnsureChanged(false)
+			changed = changed || routeChanged
+			errs = append(errs, err)
+		}
+		return changed, errors.Join(errs...)
+	}
+
+	var added []IPRouteConfig
+	for _, r := range o.Routes {
+		routeChanged, err := r.EnsureChanged(true)
+		if err != nil {
+			o.rollback(added)
+			return false, fmt.Errorf("failed to add route %v for oif %s: %w", r.Route.Dst, o.Rule.Rule.OifName, err)
+		}
+		if routeChanged {
+			added = append(added, r)
+		}
+	}
+	ruleChanged, err := o.Rule.EnsureChanged(true)
+	if err != nil {
+		o.rollback(added)
+		return false, err
+	}
+	return len(added) > 0 || ruleChanged, nil
+}
+
+func (o OifPolicyConfig) rollback(added []IPRouteConfig) {
+	for i := len(added) - 1; i >= 0; i-- {
+		if _, err := added[i].EnsureChanged(false); err != nil {
+			glog.Errorf("failed to roll back route %v for oif %s: %v", added[i].Route.Dst, o.Rule.Rule.OifName, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestOifPolicyConfig(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.2.0.0/24")
+	c, err := NewOifPolicyConfig("eth1", 300, 31000, []netlink.Route{
+		{Dst: subnet, LinkIndex: 3, Scope: netlink.SCOPE_LINK},
+		{Gw: net.IPv4(10, 2, 0, 1), LinkIndex: 3},
+	})
+	if err != nil {
+		t.Fatalf("NewOifPolicyConfig returned error: %v", err)
+	}
+	if c.Rule.Rule.OifName != "eth1" || c.Rule.Rule.Table != 300 || c.Routes[1].Route.Table != 300 {
+		t.Fatalf("the rule and routes should share table 300, got %v and %v", c.Rule.Rule, c.Routes)
+	}
+
+	routes := &fakeRouteTable{}
+	rules := &fakeRuleList{}
+	c.Rule = rules.config(c.Rule)
+	for i := range c.Routes {
+		c.Routes[i] = routes.config(c.Routes[i].Route)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(routes.routes) != 2 || len(rules.rules) != 1 {
+		t.Fatalf("the rule and both routes should be applied once, got %v and %v", rules.rules, routes.routes)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(routes.routes) != 0 || len(rules.rules) != 0 {
+		t.Fatalf("the rule and routes should be removed, got %v and %v", rules.rules, routes.routes)
+	}
+
+	// When the second route fails, the first is rolled back and no rule is
+	// left pointing at a half-filled table.
+	c.Routes[1].RouteAdd = func(*netlink.Route) error { return errors.New("boom") }
+	if err := c.Ensure(true); err == nil {
+		t.Fatal("Ensure(true) should fail when a route cannot be added")
+	}
+	if len(routes.routes) != 0 || len(rules.rules) != 0 {
+		t.Errorf("a failed apply should leave nothing behind, got %v and %v", rules.rules, routes.routes)
+	}
+
+	if _, err := NewOifPolicyConfig("", 300, 31000, nil); err == nil {
+		t.Error("a missing oif should be rejected")
+	}
+}
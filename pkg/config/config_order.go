@@ -0,0 +1,122 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamedConfig gives Config a Name that other configs of its Set can depend
+// on, and lists the names of the configs that must be applied before it, e.g.
+// a jump rule depending on the chain it jumps to. Configs with a higher
+// Priority are applied before the rest of their Set, so that the must-have
+// ones are in place even when an optional one fails. Disruptive configs, which
+// briefly interrupt traffic while applied, wait for the Set's Window.
+type NamedConfig struct {
+	Config
+	Name       string
+	DependsOn  []string
+	Priority   int
+	Disruptive bool
+}
+
+// Chains NamedConfig
+func (c NamedConfig) Chains() []IPTablesChainSpec {
+	return configChains(c.Config)
+}
+
+// EnsureChanged NamedConfig
+func (c NamedConfig) EnsureChanged(enabled bool) (bool, error) {
+	return EnsureChanged(c.Config, enabled)
+}
+
+// OrderedConfigs returns the configs of the Set in apply order: every
+// NamedConfig after the configs it depends on, and otherwise by descending
+// Priority, then declaration order. Tear down in the reverse order. Duplicate
+// or unknown names and dependency cycles are errors.
+func (s Set) OrderedConfigs() ([]Config, error) {
+	names := make(map[string]bool)
+	for _, c := range s.Configs {
+		if n, ok := c.(NamedConfig); ok && n.Name != "" {
+			if names[n.Name] {
+				return nil, fmt.Errorf("%s: duplicate config name %q", s.FeatureName, n.Name)
+			}
+			names[n.Name] = true
+		}
+	}
+	for _, c := range s.Configs {
+		if n, ok := c.(NamedConfig); ok {
+			for _, dep := range n.DependsOn {
+				if !names[dep] {
+					return nil, fmt.Errorf("%s: config %q depends on unknown config %q", s.FeatureName, n.Name, dep)
+				}
+			}
+		}
+	}
+
+	// Repeatedly take the highest-priority pending config whose dependencies
+	// are all applied, the first one on ties, which keeps declaration order
+	// wherever the priorities and the graph allow.
+	applied := make(map[string]bool)
+	pending := append([]Config(nil), s.Configs...)
+	ordered := make([]Config, 0, len(pending))
+	for len(pending) > 0 {
+		next := -1
+		for i, c := range pending {
+			if ready(c, applied) && (next < 0 || priority(c) > priority(pending[next])) {
+				next = i
+			}
+		}
+		if next < 0 {
+			var blocked []string
+			for _, c := range pending {
+				if n, ok := c.(NamedConfig); ok {
+					blocked = append(blocked, n.Name)
+				}
+			}
+			return nil, fmt.Errorf("%s: dependency cycle among configs %s", s.FeatureName, strings.Join(blocked, ", "))
+		}
+		c := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		if n, ok := c.(NamedConfig); ok && n.Name != "" {
+			applied[n.Name] = true
+		}
+		ordered = append(ordered, c)
+	}
+	return ordered, nil
+}
+
+func ready(c Config, applied map[string]bool) bool {
+	n, ok := c.(NamedConfig)
+	if !ok {
+		return true
+	}
+	for _, dep := range n.DependsOn {
+		if !applied[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+func priority(c Config) int {
+	if n, ok := c.(NamedConfig); ok {
+		return n.Priority
+	}
+	return 0
+}
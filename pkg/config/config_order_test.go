@@ -0,0 +1,120 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetEnsureDependencyOrder(t *testing.T) {
+	var log []string
+	named := func(name string, deps ...string) Config {
+		return NamedConfig{Config: recordingConfig{name, &log, nil}, Name: name, DependsOn: deps}
+	}
+	// Declared in the wrong order: the routes use the table the rule looks
+	// up, and the jump needs its chain.
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs: []Config{
+			named("routes", "rule"),
+			named("jump", "chain"),
+			recordingConfig{"sysctl", &log, nil},
+			named("rule", "jump"),
+			named("chain"),
+		},
+	}
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure() returned error: %v", err)
+	}
+	s.Enabled = false
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure() returned error: %v", err)
+	}
+	want := []string{
+		"sysctl:true", "chain:true", "jump:true", "rule:true", "routes:true",
+		"routes:false", "rule:false", "jump:false", "chain:false", "sysctl:false",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("Set.Ensure order = %v, want %v", log, want)
+	}
+}
+
+func TestSetEnsurePriorityOrder(t *testing.T) {
+	var log []string
+	prio := func(name string, priority int, err error, deps ...string) Config {
+		return NamedConfig{Config: recordingConfig{name, &log, err}, Name: name, DependsOn: deps, Priority: priority}
+	}
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs: []Config{
+			prio("optional", -1, errors.New("no room")),
+			recordingConfig{"plain", &log, nil},
+			prio("critical", 10, nil),
+			// The dependency wins over the priority.
+			prio("important", 5, nil, "helper"),
+			prio("helper", 0, nil),
+			prio("also-critical", 10, nil),
+		},
+	}
+	if err := s.Ensure(); err == nil {
+		t.Error("Ensure() should report the failing optional config")
+	}
+	want := []string{"critical:true", "also-critical:true", "plain:true", "helper:true", "important:true", "optional:true"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("Set.Ensure order = %v, want %v", log, want)
+	}
+
+	log = nil
+	s.Enabled = false
+	s.Ensure()
+	for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+		want[i], want[j] = want[j], want[i]
+	}
+	for i := range want {
+		want[i] = want[i][:len(want[i])-len("true")] + "false"
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("Set.Ensure teardown order = %v, want %v", log, want)
+	}
+}
+
+func TestOrderedConfigsInvalid(t *testing.T) {
+	var log []string
+	named := func(name string, deps ...string) Config {
+		return NamedConfig{Config: recordingConfig{name, &log, nil}, Name: name, DependsOn: deps}
+	}
+	for desc, configs := range map[string][]Config{
+		"cycle":     {named("a", "b"), named("b", "a")},
+		"unknown":   {named("a", "missing")},
+		"duplicate": {named("a"), named("a")},
+	} {
+		s := Set{Enabled: true, FeatureName: "test", Configs: configs}
+		if _, err := s.OrderedConfigs(); err == nil {
+			t.Errorf("%s: OrderedConfigs() should fail", desc)
+		}
+		if err := s.Ensure(); err == nil {
+			t.Errorf("%s: Ensure() should fail", desc)
+		}
+	}
+	if len(log) != 0 {
+		t.Errorf("no config should be ensured when ordering fails, got %v", log)
+	}
+}
@@ -31,7 +31,6 @@ const (
 )
 
 const (
-	tableMangle         = "mangle"
 	preRoutingChain     = "PREROUTING"
 	postRoutingChain    = "POSTROUTING"
 	gcpPreRoutingChain  = "GCP-PREROUTING"
@@ -65,11 +64,13 @@ var (
 	localNetdev      string
 )
 
-// PolicyRoutingConfigSet defines the Policy Routing rules
+// PolicyRoutingConfigSet defines the Policy Routing rules. Its configs are
+// declared in dependency order, sysctls, iptables marks, the gateway route,
+// then the rules that look it up, so that a Set applies them in that order
+// and tears them down in reverse.
 var PolicyRoutingConfigSet = Set{
-	false,
-	"PolicyRouting",
-	nil,
+	Enabled:     false,
+	FeatureName: "PolicyRouting",
 }
 
 func init() {
@@ -102,58 +103,58 @@ func init() {
 			SysctlFunc:   sysctl.Sysctl,
 		},
 		IPTablesRuleConfig{
-			IPTablesChainSpec{
+			Spec: IPTablesChainSpec{
 				TableName:      tableMangle,
 				ChainName:      gcpPreRoutingChain,
 				IsDefaultChain: false,
 				IPT:            ipt,
 			},
-			[]IPTablesRuleSpec{
+			RuleSpecs: []IPTablesRuleSpec{
 				[]string{
 					"-j", "CONNMARK", "--restore-mark", "--nfmask", hairpinMaskStr, "--ctmask", hairpinMaskStr,
 					"-m", "comment", "--comment", policyRoutingGcpPreRoutingComment,
 				},
 			},
-			ipt,
+			IPT: ipt,
 		},
 		IPTablesRuleConfig{
-			IPTablesChainSpec{
+			Spec: IPTablesChainSpec{
 				TableName:      tableMangle,
 				ChainName:      preRoutingChain,
 				IsDefaultChain: true,
 				IPT:            ipt,
 			},
-			[]IPTablesRuleSpec{
+			RuleSpecs: []IPTablesRuleSpec{
 				[]string{"-j", gcpPreRoutingChain, "-m", "comment", "--comment", policyRoutingPreRoutingComment},
 			},
-			ipt,
+			IPT: ipt,
 		},
 		IPTablesRuleConfig{
-			IPTablesChainSpec{
+			Spec: IPTablesChainSpec{
 				TableName:      tableMangle,
 				ChainName:      gcpPostRoutingChain,
 				IsDefaultChain: false,
 				IPT:            ipt,
 			},
-			[]IPTablesRuleSpec{
+			RuleSpecs: []IPTablesRuleSpec{
 				[]string{"-m", "mark", "--mark",
 					fmt.Sprintf("0x%x/0x%x", hairpinMark, hairpinMask),
 					"-j", "CONNMARK", "--save-mark", "--nfmask", hairpinMaskStr, "--ctmask", hairpinMaskStr, "-m",
 					"comment", "--comment", policyRoutingGcpPostRoutingComment},
 			},
-			ipt,
+			IPT: ipt,
 		},
 		IPTablesRuleConfig{
-			IPTablesChainSpec{
+			Spec: IPTablesChainSpec{
 				TableName:      tableMangle,
 				ChainName:      postRoutingChain,
 				IsDefaultChain: true,
 				IPT:            ipt,
 			},
-			[]IPTablesRuleSpec{
+			RuleSpecs: []IPTablesRuleSpec{
 				[]string{"-j", gcpPostRoutingChain, "-m", "comment", "--comment", policyRoutingPostRoutingComment},
 			},
-			ipt,
+			IPT: ipt,
 		},
 		IPRouteConfig{
 			Route: netlink.Route{
@@ -161,9 +162,14 @@ func init() {
 				LinkIndex: defaultLinkIndex,
 				Gw:        defaultGateway,
 				Dst:       nil,
+				Protocol:  RouteProtocolNetd,
 			},
 			RouteAdd: netlink.RouteAdd,
 			RouteDel: netlink.RouteDel,
+			// Listing lets a route added before netd set its protocol be
+			// replaced, and so adopted, instead of left as a duplicate.
+			RouteList:    netlink.RouteListFiltered,
+			RouteReplace: netlink.RouteReplace,
 		},
 		IPRuleConfig{
 			Rule: netlink.Rule{
@@ -0,0 +1,171 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type linkByIndexer func(index int) (netlink.Link, error)
+
+// PrimaryInterface is the interface holding the node's default route.
+type PrimaryInterface struct {
+	Name  string
+	Index int
+}
+
+// PrimaryInterfaceDetector finds the primary interface from the IPv4 default
+// route in the main table. Every Detect looks the route up again, so a moved
+// default route is picked up on the next reconcile.
+type PrimaryInterfaceDetector struct {
+	RouteList   routeLister
+	LinkByIndex linkByIndexer
+
+	mu   sync.Mutex
+	last PrimaryInterface
+}
+
+// NewPrimaryInterfaceDetector creates a PrimaryInterfaceDetector using netlink.
+func NewPrimaryInterfaceDetector() *PrimaryInterfaceDetector {
+	return &PrimaryInterfaceDetector{
+		RouteList:   netlink.RouteListFiltered,
+		LinkByIndex: netlink.LinkByIndex,
+	}
+}
+
+// Detect returns the current primary interface, and whether it differs from
+// the one the previous Detect returned. The first detection is not a change.
+func (d *PrimaryInterfaceDetector) Detect() (PrimaryInterface, bool, error) {
+	filter := &netlink.Route{Table: unix.RT_TABLE_MAIN}
+	routes, err := d.RouteList(netlink.FAMILY_V4, filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return PrimaryInterface{}, false, fmt.Errorf("failed to list routes: %w", err)
+	}
+	index := 0
+	metric := 0
+	for _, route := range routes {
+		if !isDefaultDst(route.Dst) || routeTable(route) != unix.RT_TABLE_MAIN {
+			continue
+		}
+		link := route.LinkIndex
+		if link == 0 && len(route.MultiPath) > 0 {
+			link = route.MultiPath[0].LinkIndex
+		}
+		// With several default routes the kernel prefers the lowest metric.
+		if link != 0 && (index == 0 || route.Priority < metric) {
+			index, metric = link, route.Priority
+		}
+	}
+	if index == 0 {
+		return PrimaryInterface{}, false, fmt.Errorf("no IPv4 default route in the main table")
+	}
+	link, err := d.LinkByIndex(index)
+	if err != nil {
+		return PrimaryInterface{}, false, fmt.Errorf("failed to get link %d of the default route: %w", index, err)
+	}
+	primary := PrimaryInterface{Name: link.Attrs().Name, Index: index}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changed := d.last != PrimaryInterface{} && d.last != primary
+	if changed {
+		glog.Infof("primary interface moved from %s to %s", d.last.Name, primary.Name)
+	}
+	d.last = primary
+	return primary, changed, nil
+}
+
+// PrimaryInterfaceConfig ensures the config Build returns for the current
+// primary interface, e.g. a per-interface sysctl, so callers need not
+// hardcode "eth0". When the primary interface moves, the config built for the
+// previous one is disabled first. Create it with NewPrimaryInterfaceConfig.
+type PrimaryInterfaceConfig struct {
+	Detector *PrimaryInterfaceDetector
+	Build    func(PrimaryInterface) Config
+
+	mu      *sync.Mutex
+	current *PrimaryInterface
+}
+
+// NewPrimaryInterfaceConfig creates a PrimaryInterfaceConfig.
+func NewPrimaryInterfaceConfig(detector *PrimaryInterfaceDetector, build func(PrimaryInterface) Config) PrimaryInterfaceConfig {
+	return PrimaryInterfaceConfig{
+		Detector: detector,
+		Build:    build,
+		mu:       &sync.Mutex{},
+		current:  &PrimaryInterface{},
+	}
+}
+
+// Dynamic PrimaryInterfaceConfig
+func (PrimaryInterfaceConfig) Dynamic() bool {
+	return true
+}
+
+// Chains returns the chains of the config built for the primary interface it
+// last ensured, detecting the interface if it has not ensured one yet.
+func (p PrimaryInterfaceConfig) Chains() []IPTablesChainSpec {
+	if p.mu == nil || p.current == nil {
+		return nil
+	}
+	p.mu.Lock()
+	primary := *p.current
+	p.mu.Unlock()
+	if primary == (PrimaryInterface{}) {
+		detected, _, err := p.Detector.Detect()
+		if err != nil {
+			glog.Warningf("failed to detect the primary interface, listing the chains built without it: %v", err)
+		}
+		primary = detected
+	}
+	return configChains(p.Build(primary))
+}
+
+// Ensure PrimaryInterfaceConfig
+func (p PrimaryInterfaceConfig) Ensure(enabled bool) error {
+	_, err := p.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged PrimaryInterfaceConfig
+func (p PrimaryInterfaceConfig) EnsureChanged(enabled bool) (bool, error) {
+	if p.mu == nil || p.current == nil {
+		return false, fmt.Errorf("PrimaryInterfaceConfig must be created with NewPrimaryInterfaceConfig")
+	}
+	primary, _, err := p.Detector.Detect()
+	if err != nil {
+		return false, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := false
+	if previous := *p.current; previous != (PrimaryInterface{}) && previous != primary {
+		torn, err := EnsureChanged(p.Build(previous), false)
+		if err != nil {
+			glog.Warningf("failed to disable config for previous primary interface %s: %v", previous.Name, err)
+		}
+		changed = torn
+	}
+	*p.current = primary
+	ensured, err := EnsureChanged(p.Build(primary), enabled)
+	return changed || ensured, err
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func newFakePrimaryDetector(routes *fakeRouteTable) *PrimaryInterfaceDetector {
+	names := map[int]string{2: "eth0", 3: "ens4"}
+	return &PrimaryInterfaceDetector{
+		RouteList: routes.list,
+		LinkByIndex: func(index int) (netlink.Link, error) {
+			name, ok := names[index]
+			if !ok {
+				return nil, netlink.LinkNotFoundError{}
+			}
+			return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name, Index: index}}, nil
+		},
+	}
+}
+
+func TestPrimaryInterfaceDetector(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.128.0.0/20")
+	routes := &fakeRouteTable{routes: []netlink.Route{
+		{Dst: subnet, LinkIndex: 3},
+		{Gw: net.IPv4(10, 128, 0, 1), LinkIndex: 2, Priority: 100},
+		{Gw: net.IPv4(10, 129, 0, 1), LinkIndex: 3, Priority: 200},
+	}}
+	d := newFakePrimaryDetector(routes)
+
+	primary, changed, err := d.Detect()
+	if err != nil || changed {
+		t.Fatalf("Detect() = %v, %v, %v; want no change and no error", primary, changed, err)
+	}
+	if primary != (PrimaryInterface{Name: "eth0", Index: 2}) {
+		t.Errorf("Detect() = %+v, want eth0 holding the lowest-metric default route", primary)
+	}
+
+	routes.routes = routes.routes[:1]
+	routes.routes = append(routes.routes, netlink.Route{Gw: net.IPv4(10, 128, 0, 1), LinkIndex: 3})
+	primary, changed, err = d.Detect()
+	if err != nil || !changed || primary.Name != "ens4" {
+		t.Errorf("Detect() after the default route moved = %+v, %v, %v; want ens4, changed", primary, changed, err)
+	}
+
+	routes.routes = routes.routes[:1]
+	if _, _, err := d.Detect(); err == nil {
+		t.Error("Detect() without a default route should fail")
+	}
+}
+
+func TestPrimaryInterfaceConfig(t *testing.T) {
+	routes := &fakeRouteTable{routes: []netlink.Route{{Gw: net.IPv4(10, 128, 0, 1), LinkIndex: 2}}}
+	mSysctl := make(map[string]string)
+	c := NewPrimaryInterfaceConfig(newFakePrimaryDetector(routes), func(p PrimaryInterface) Config {
+		return SysctlConfig{
+			Key:          fmt.Sprintf("net.ipv4.conf.%s.rp_filter", p.Name),
+			Value:        "2",
+			DefaultValue: "1",
+			SysctlFunc: func(name string, params ...string) (string, error) {
+				if len(params) == 0 {
+					return mSysctl[name], nil
+				}
+				mSysctl[name] = params[0]
+				return "", nil
+			},
+		}
+	})
+
+	if changed, err := c.EnsureChanged(true); !changed || err != nil {
+		t.Fatalf("EnsureChanged(true) = %v, %v, want true, nil", changed, err)
+	}
+	if mSysctl["net.ipv4.conf.eth0.rp_filter"] != "2" {
+		t.Fatalf("rp_filter should be set on eth0, got %v", mSysctl)
+	}
+	if changed, err := c.EnsureChanged(true); changed || err != nil {
+		t.Errorf("EnsureChanged(true) again = %v, %v, want false, nil", changed, err)
+	}
+
+	routes.routes[0].LinkIndex = 3
+	mSysctl["net.ipv4.conf.ens4.rp_filter"] = "2"
+	if changed, err := c.EnsureChanged(true); !changed || err != nil {
+		t.Fatalf("EnsureChanged(true) after a move = %v, %v, want true, nil for the teardown on eth0", changed, err)
+	}
+	if mSysctl["net.ipv4.conf.eth0.rp_filter"] != "1" || mSysctl["net.ipv4.conf.ens4.rp_filter"] != "2" {
+		t.Errorf("rp_filter should follow the default route to ens4, got %v", mSysctl)
+	}
+
+	var zero PrimaryInterfaceConfig
+	if err := zero.Ensure(true); err == nil {
+		t.Error("Ensure on a zero PrimaryInterfaceConfig should fail instead of panicking")
+	}
+}
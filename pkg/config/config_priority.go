@@ -0,0 +1,112 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// CheckRulePriorityConflicts reports every pair of different ip rules that
+// features register at the same priority and family. The kernel evaluates
+// such rules in an unspecified order, so a mistake here causes intermittent
+// routing bugs. Rules without a priority and rules repeated by the same
+// feature are not conflicts.
+func CheckRulePriorityConflicts(sets []Set) error {
+	type owner struct {
+		feature string
+		rule    netlink.Rule
+	}
+	type key struct{ family, priority int }
+	seen := make(map[key][]owner)
+	var errs []error
+	for _, s := range sets {
+		for _, rule := range setRules(s.Configs) {
+			if rule.Priority < 0 {
+				continue
+			}
+			k := key{ruleFamily(rule), rule.Priority}
+			for _, o := range seen[k] {
+				if o.feature != s.FeatureName && !ruleEqual(o.rule, rule) {
+					errs = append(errs, fmt.Errorf("ip rule priority %d is used by %s (%s) and %s (%s)",
+						rule.Priority, o.feature, describeRule(o.rule), s.FeatureName, describeRule(rule)))
+				}
+			}
+			seen[k] = append(seen[k], owner{s.FeatureName, rule})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkConflictPolicies rejects a ConflictOverwrite rule sharing its priority
+// and family with a different rule of the same Set, which it would delete as
+// foreign on every Ensure.
+func checkConflictPolicies(configs []Config) error {
+	rules := setRuleConfigs(configs)
+	var errs []error
+	for _, r := range rules {
+		if r.ConflictPolicy != ConflictOverwrite || r.Rule.Priority < 0 {
+			continue
+		}
+		for _, o := range rules {
+			if o.Rule.Priority == r.Rule.Priority && ruleFamily(o.Rule) == ruleFamily(r.Rule) && !ruleEqual(o.Rule, r.Rule) {
+				errs = append(errs, fmt.Errorf("ip rule %s overwrites foreign rules but shares its priority with %s of the same feature",
+					describeRule(r.Rule), describeRule(o.Rule)))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setRules returns the rules of the IPRuleConfigs among configs, including
+// those inside composite configs.
+func setRules(configs []Config) []netlink.Rule {
+	var rules []netlink.Rule
+	for _, r := range setRuleConfigs(configs) {
+		rules = append(rules, r.Rule)
+	}
+	return rules
+}
+
+// setRuleConfigs returns the IPRuleConfigs among configs, including those
+// inside composite configs.
+func setRuleConfigs(configs []Config) []IPRuleConfig {
+	var rules []IPRuleConfig
+	for _, c := range configs {
+		switch c := c.(type) {
+		case IPRuleConfig:
+			rules = append(rules, c)
+		case DualStackRuleConfig:
+			rules = append(rules, c.V4, c.V6)
+		case FwmarkPolicyConfig:
+			rules = append(rules, c.Rule)
+		case OifPolicyConfig:
+			rules = append(rules, c.Rule)
+		case NamedConfig:
+			rules = append(rules, setRuleConfigs([]Config{c.Config})...)
+		case MetadataGatedConfig:
+			rules = append(rules, setRuleConfigs([]Config{c.Config})...)
+		case KubeProxyModeGatedConfig:
+			rules = append(rules, setRuleConfigs([]Config{c.Config})...)
+		case NodeReadyGatedConfig:
+			rules = append(rules, setRuleConfigs([]Config{c.Config})...)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRulePriorityConflicts(t *testing.T) {
+	rule := func(table, priority int) IPRuleConfig {
+		c := newRuleConfig(table)
+		c.Rule.Priority = priority
+		return c
+	}
+	fwmark, err := NewFwmarkPolicyConfig(preRoutingChain, nil, 0x10000, 0xff0000, 200, 30000)
+	if err != nil {
+		t.Fatalf("NewFwmarkPolicyConfig returned error: %v", err)
+	}
+	sets := []Set{
+		{FeatureName: "a", Configs: []Config{rule(100, 30000), rule(100, 30001)}},
+		{FeatureName: "b", Configs: []Config{NamedConfig{Config: fwmark, Name: "fwmark"}}},
+		// The same rule declared by two features is not a conflict.
+		{FeatureName: "c", Configs: []Config{rule(100, 30001), rule(101, -1)}},
+	}
+
+	err = CheckRulePriorityConflicts(sets)
+	if err == nil {
+		t.Fatal("the two rules at priority 30000 should conflict")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "priority 30000 is used by a") || strings.Contains(msg, "30001") {
+		t.Errorf("unexpected conflict report %q", msg)
+	}
+
+	if err := CheckRulePriorityConflicts(sets[1:]); err != nil {
+		t.Errorf("CheckRulePriorityConflicts() = %v, want no conflict", err)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"time"
+)
+
+// RetryBudget caps the retries of failed configs across a whole
+// Set.EnsureWithBudget pass, both in number and in time. It is not safe for
+// concurrent use; create one per pass.
+type RetryBudget struct {
+	// Retries is the number of retries left.
+	Retries int
+	// Deadline is when retrying stops.
+	Deadline time.Time
+	// Backoff is the delay before each retry.
+	Backoff time.Duration
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewRetryBudget creates a RetryBudget allowing maxRetries retries, each after
+// backoff, within maxDuration from now.
+func NewRetryBudget(maxRetries int, maxDuration, backoff time.Duration) *RetryBudget {
+	return &RetryBudget{
+		Retries:  maxRetries,
+		Deadline: time.Now().Add(maxDuration),
+		Backoff:  backoff,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// take waits out the backoff and reports whether one more retry fits in the
+// budget, consuming it.
+func (b *RetryBudget) take() bool {
+	if b == nil || b.Retries <= 0 || !b.now().Add(b.Backoff).Before(b.Deadline) {
+		return false
+	}
+	b.Retries--
+	b.sleep(b.Backoff)
+	return true
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a RetryBudget clock whose sleep advances now.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestBudget(retries int, maxDuration, backoff time.Duration) (*RetryBudget, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	b := NewRetryBudget(retries, maxDuration, backoff)
+	b.Deadline = clock.t.Add(maxDuration)
+	b.now, b.sleep = clock.now, clock.sleep
+	return b, clock
+}
+
+func TestEnsureWithBudgetRetries(t *testing.T) {
+	var log []string
+	attempts := 0
+	flaky := funcConfig{"flaky", func(name string, enabled bool) error {
+		recordingConfig{name, &log, nil}.Ensure(enabled)
+		if attempts++; attempts < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}}
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{flaky}}
+	budget, _ := newTestBudget(5, time.Minute, time.Second)
+	if err := s.EnsureWithBudget(budget); err != nil {
+		t.Fatalf("EnsureWithBudget() returned error: %v", err)
+	}
+	if len(log) != 3 || budget.Retries != 3 {
+		t.Errorf("ensured %d times leaving %d retries, want 3 and 3", len(log), budget.Retries)
+	}
+}
+
+func TestEnsureWithBudgetShared(t *testing.T) {
+	var log []string
+	failing := func(name string) Config {
+		return recordingConfig{name, &log, errors.New("failed")}
+	}
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{failing("a"), failing("b"), failing("c")}}
+
+	// The retry count runs out during the second config.
+	budget, _ := newTestBudget(3, time.Hour, time.Second)
+	if err := s.EnsureWithBudget(budget); err == nil {
+		t.Error("EnsureWithBudget() should fail")
+	}
+	if want := 3 + 3; len(log) != want {
+		t.Errorf("ensured %d times, want %d: %v", len(log), want, log)
+	}
+
+	// So does the time, with retries to spare.
+	log = nil
+	budget, clock := newTestBudget(100, 10*time.Second, 3*time.Second)
+	start := clock.t
+	s.EnsureWithBudget(budget)
+	if want := 3 + 3; len(log) != want {
+		t.Errorf("ensured %d times, want %d: %v", len(log), want, log)
+	}
+	if elapsed := clock.t.Sub(start); elapsed > 10*time.Second {
+		t.Errorf("retried for %v, past the 10s budget", elapsed)
+	}
+
+	// Without a budget, every config is attempted once.
+	log = nil
+	s.Ensure()
+	if len(log) != 3 {
+		t.Errorf("ensured %d times without a budget, want 3", len(log))
+	}
+}
@@ -0,0 +1,486 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/metrics"
+)
+
+// RouteProtocolNetd is the route protocol (RTPROT) netd sets on the routes it
+// adds, so they can be told apart from kernel, DHCP or other daemons' routes.
+// It shows as "proto 78" in ip route unless named in rt_protos.
+const RouteProtocolNetd netlink.RouteProtocol = 78
+
+// ErrRouteConflict is returned when a different route to the same destination
+// occupies the table and cannot be replaced.
+var ErrRouteConflict = errors.New("conflicting route")
+
+// ErrDeferred is returned, wrapped, by a config waiting for something another
+// config or component provides, such as a local address. Set.Ensure counts it
+// as deferred rather than failed, and the next reconcile tries again.
+var ErrDeferred = errors.New("deferred")
+
+// NewLinkScopeRouteConfig creates an IPRouteConfig for a connected route to
+// dst on the given link, in the given table.
+func NewLinkScopeRouteConfig(dst net.IPNet, linkIndex, table int) IPRouteConfig {
+	return IPRouteConfig{
+		Route: netlink.Route{
+			Dst:       &dst,
+			LinkIndex: linkIndex,
+			Table:     table,
+			Scope:     netlink.SCOPE_LINK,
+			Protocol:  RouteProtocolNetd,
+		},
+		RouteAdd:  netlink.RouteAdd,
+		RouteDel:  netlink.RouteDel,
+		RouteList: netlink.RouteListFiltered,
+	}
+}
+
+// NewRouteWithPrefSrc creates an IPRouteConfig for a route to dst via gw that
+// uses src as the preferred source address. A route to dst with a different
+// preferred source is replaced.
+func NewRouteWithPrefSrc(dst net.IPNet, gw, src net.IP, link int) IPRouteConfig {
+	return IPRouteConfig{
+		Route: netlink.Route{
+			Dst:       &dst,
+			Gw:        gw,
+			Src:       src,
+			LinkIndex: link,
+			Protocol:  RouteProtocolNetd,
+		},
+		RouteAdd:     netlink.RouteAdd,
+		RouteDel:     netlink.RouteDel,
+		RouteList:    netlink.RouteListFiltered,
+		RouteReplace: netlink.RouteReplace,
+	}
+}
+
+// loopbackIndex is the ifindex of lo, which is always the first interface of
+// a network namespace.
+const loopbackIndex = 1
+
+// NewLocalVIPRouteConfig creates an IPRouteConfig for a host route to vip in
+// the local table, which makes the host accept traffic to vip on lo like
+// "ip route add local <vip> dev lo table local".
+func NewLocalVIPRouteConfig(vip net.IP) IPRouteConfig {
+	dst := net.IPNet{IP: vip.To4(), Mask: net.CIDRMask(32, 32)}
+	// The kernel reports local routes with host scope for IPv4 but ignores
+	// scope, and reports universe, for IPv6.
+	scope := netlink.SCOPE_HOST
+	if dst.IP == nil {
+		dst = net.IPNet{IP: vip.To16(), Mask: net.CIDRMask(128, 128)}
+		scope = netlink.SCOPE_UNIVERSE
+	}
+	return IPRouteConfig{
+		Route: netlink.Route{
+			Dst:       &dst,
+			LinkIndex: loopbackIndex,
+			Table:     unix.RT_TABLE_LOCAL,
+			Type:      unix.RTN_LOCAL,
+			Scope:     scope,
+			Protocol:  RouteProtocolNetd,
+		},
+		RouteAdd:  netlink.RouteAdd,
+		RouteDel:  netlink.RouteDel,
+		RouteList: netlink.RouteListFiltered,
+	}
+}
+
+// NewTableBlackholeDefault creates an IPRouteConfig for an IPv4 blackhole
+// default route in table, like "ip route add blackhole default table
+// <table>", so traffic routed to the table and matching none of its routes is
+// dropped instead of falling through to the next rule.
+func NewTableBlackholeDefault(table int) IPRouteConfig {
+	return newTableBlackholeDefault(table, net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)})
+}
+
+// NewTableBlackholeDefaultV6 is NewTableBlackholeDefault for IPv6.
+func NewTableBlackholeDefaultV6(table int) IPRouteConfig {
+	return newTableBlackholeDefault(table, net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)})
+}
+
+func newTableBlackholeDefault(table int, dst net.IPNet) IPRouteConfig {
+	// The kernel lists IPv6 blackhole routes on lo, so LinkIndex is left
+	// unset and not compared.
+	return IPRouteConfig{
+		Route: netlink.Route{
+			Dst:      &dst,
+			Table:    table,
+			Type:     unix.RTN_BLACKHOLE,
+			Protocol: RouteProtocolNetd,
+		},
+		RouteAdd:  netlink.RouteAdd,
+		RouteDel:  netlink.RouteDel,
+		RouteList: netlink.RouteListFiltered,
+	}
+}
+
+// NewLocalRouteConfigs returns, for each of ips, e.g. the node's internal
+// IPs, the NewLocalVIPRouteConfig route delivering traffic to it on this host,
+// on the link with index linkIndex instead of lo.
+func NewLocalRouteConfigs(ips []net.IP, linkIndex int) []Config {
+	var configs []Config
+	for _, ip := range ips {
+		c := NewLocalVIPRouteConfig(ip)
+		c.Route.LinkIndex = linkIndex
+		configs = append(configs, c)
+	}
+	return configs
+}
+
+// FlushRoutes deletes every route matching filter on the fields selected by
+// mask, a combination of the netlink.RT_FILTER_* flags. Routes of all families
+// are flushed unless filter.Family is set.
+func FlushRoutes(filter netlink.Route, mask uint64) error {
+	_, err := flushRoutes(netlink.RouteListFiltered, netlink.RouteDel, filter, mask)
+	return err
+}
+
+// flushRoutes reports whether it deleted any route.
+func flushRoutes(list routeLister, del routeDeler, filter netlink.Route, mask uint64) (bool, error) {
+	family := filter.Family
+	if family == 0 {
+		family = netlink.FAMILY_ALL
+	}
+	routes, err := list(family, &filter, mask)
+	if err != nil {
+		return false, fmt.Errorf("failed to list routes to flush: %w", err)
+	}
+	deleted := false
+	var errs []error
+	for i := range routes {
+		// RouteListFiltered applies only some filter combinations, so every
+		// route is checked again before it is deleted.
+		if !routeFilterMatches(filter, mask, routes[i]) {
+			continue
+		}
+		if err := del(&routes[i]); err != nil {
+			if !errors.Is(err, syscall.ESRCH) {
+				errs = append(errs, fmt.Errorf("failed to delete route %v: %w", routes[i], err))
+			}
+			continue
+		}
+		diffLogf("deleted ip route %s", describeRoute(routes[i]))
+		deleted = true
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func routeFilterMatches(filter netlink.Route, mask uint64, route netlink.Route) bool {
+	switch {
+	case mask&netlink.RT_FILTER_TABLE != 0 && filter.Table != unix.RT_TABLE_UNSPEC && routeTable(filter) != routeTable(route):
+		return false
+	case mask&netlink.RT_FILTER_PROTOCOL != 0 && filter.Protocol != route.Protocol:
+		return false
+	case mask&netlink.RT_FILTER_SCOPE != 0 && filter.Scope != route.Scope:
+		return false
+	case mask&netlink.RT_FILTER_TYPE != 0 && filter.Type != route.Type:
+		return false
+	case mask&netlink.RT_FILTER_OIF != 0 && filter.LinkIndex != route.LinkIndex:
+		return false
+	case mask&netlink.RT_FILTER_GW != 0 && !filter.Gw.Equal(route.Gw):
+		return false
+	case mask&netlink.RT_FILTER_DST != 0 && !ipNetEqual(filter.Dst, route.Dst):
+		return false
+	}
+	return true
+}
+
+// RouteFlushConfig flushes the routes matching Filter and Mask when its Set
+// is disabled, removing every route of a feature at once. Enabling it is a
+// no-op.
+type RouteFlushConfig struct {
+	Filter    netlink.Route
+	Mask      uint64
+	RouteList routeLister
+	RouteDel  routeDeler
+}
+
+// NewRouteFlushConfig creates a RouteFlushConfig using netlink.
+func NewRouteFlushConfig(filter netlink.Route, mask uint64) RouteFlushConfig {
+	return RouteFlushConfig{
+		Filter:    filter,
+		Mask:      mask,
+		RouteList: netlink.RouteListFiltered,
+		RouteDel:  netlink.RouteDel,
+	}
+}
+
+// Dynamic RouteFlushConfig
+func (RouteFlushConfig) Dynamic() bool {
+	return true
+}
+
+// Ensure RouteFlushConfig
+func (f RouteFlushConfig) Ensure(enabled bool) error {
+	_, err := f.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged RouteFlushConfig
+func (f RouteFlushConfig) EnsureChanged(enabled bool) (bool, error) {
+	if enabled {
+		return false, nil
+	}
+	return flushRoutes(f.RouteList, f.RouteDel, f.Filter, f.Mask)
+}
+
+// NewMTURouteConfig creates an IPRouteConfig for a route to dst via gw with
+// the given path MTU and, if non-zero, advertised MSS. A route to dst with
+// other metrics is replaced. The vendored netlink cannot set RTAX_LOCK, so
+// the MTU is not locked against PMTU discovery.
+func NewMTURouteConfig(dst net.IPNet, gw net.IP, link, mtu, advmss int) IPRouteConfig {
+	return IPRouteConfig{
+		Route: netlink.Route{
+			Dst:       &dst,
+			Gw:        gw,
+			LinkIndex: link,
+			MTU:       mtu,
+			AdvMSS:    advmss,
+			Protocol:  RouteProtocolNetd,
+		},
+		RouteAdd:     netlink.RouteAdd,
+		RouteDel:     netlink.RouteDel,
+		RouteList:    netlink.RouteListFiltered,
+		RouteReplace: netlink.RouteReplace,
+	}
+}
+
+func (r IPRouteConfig) ensureListed(enabled bool) (bool, error) {
+	present, conflict, err := r.lookup()
+	if err != nil {
+		glog.Errorf("failed to list routes in table %d: %v", r.Route.Table, err)
+		return false, err
+	}
+
+	if enabled && !present {
+		if conflict && r.KeepForeignRoutes {
+			return false, fmt.Errorf("%w: another route to %v is installed in table %d", ErrRouteConflict, r.Route.Dst, routeTable(r.Route))
+		}
+		if conflict && r.RouteReplace != nil {
+			glog.Infof("replacing route to %v in table %d", r.Route.Dst, r.Route.Table)
+			err = r.RouteReplace(&r.Route)
+			metrics.RecordNetlinkError("route_replace", err)
+			if err == nil {
+				diffLogf("replaced ip route %s", describeRoute(r.Route))
+			}
+			return err == nil, err
+		}
+		if err = r.RouteAdd(&r.Route); err != nil {
+			if os.IsExist(err) {
+				return false, fmt.Errorf("%w: a route to %v already exists in table %d", ErrRouteConflict, r.Route.Dst, routeTable(r.Route))
+			}
+			metrics.RecordNetlinkError("route_add", err)
+			return false, err
+		}
+		diffLogf("added ip route %s", describeRoute(r.Route))
+		return true, nil
+	} else if !enabled && present {
+		if err = r.RouteDel(&r.Route); err != nil {
+			if errors.Is(err, syscall.ESRCH) {
+				return false, nil
+			}
+			metrics.RecordNetlinkError("route_del", err)
+			return false, err
+		}
+		diffLogf("deleted ip route %s", describeRoute(r.Route))
+		return true, nil
+	}
+	return false, nil
+}
+
+// WithLinkName returns r following the link named name, resolved with
+// linkByName, or netlink.LinkByName when nil. Copies of the returned config
+// share the index last resolved.
+func (r IPRouteConfig) WithLinkName(name string, linkByName linkByNamer) IPRouteConfig {
+	r.LinkName = name
+	r.LinkByName = linkByName
+	r.linkIndex = &atomic.Int64{}
+	return r
+}
+
+// resolveLink returns r with the current index of LinkName, and the index the
+// route was last ensured on, or else configured with, if the link has since
+// moved to another one.
+func (r IPRouteConfig) resolveLink() (IPRouteConfig, int, error) {
+	linkByName := r.LinkByName
+	if linkByName == nil {
+		linkByName = netlink.LinkByName
+	}
+	link, err := linkByName(r.LinkName)
+	if err != nil {
+		return r, 0, fmt.Errorf("failed to resolve link %s for route %v: %w", r.LinkName, r.Route.Dst, err)
+	}
+	index := link.Attrs().Index
+	stale := r.Route.LinkIndex
+	if r.linkIndex != nil {
+		if last := int(r.linkIndex.Load()); last != 0 {
+			stale = last
+		}
+	}
+	if stale == index {
+		stale = 0
+	}
+	r.Route.LinkIndex = index
+	return r, stale, nil
+}
+
+// deleteStale deletes the route installed on the stale link index, if any.
+func (r IPRouteConfig) deleteStale(stale int) (bool, error) {
+	route := r.Route
+	route.LinkIndex = stale
+	if err := r.RouteDel(&route); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return false, nil
+		}
+		metrics.RecordNetlinkError("route_del", err)
+		return false, fmt.Errorf("failed to delete route %v on stale link index %d: %w", route.Dst, stale, err)
+	}
+	diffLogf("deleted ip route %s on stale index of link %s", describeRoute(route), r.LinkName)
+	return true, nil
+}
+
+// verify confirms the route is listed by the kernel after it was added.
+func (r IPRouteConfig) verify() error {
+	present, _, err := r.lookup()
+	if err != nil {
+		return err
+	}
+	if !present {
+		return fmt.Errorf("route %v is missing from table %d after it was added", r.Route, routeTable(r.Route))
+	}
+	return nil
+}
+
+// lookup reports whether a route matching r.Route is installed in its table,
+// and whether some other route to the same destination occupies that table.
+func (r IPRouteConfig) lookup() (present, conflict bool, err error) {
+	filter := &netlink.Route{Table: routeTable(r.Route)}
+	routes, err := r.RouteList(routeFamily(r.Route), filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		metrics.RecordNetlinkError("route_list", err)
+		return false, false, err
+	}
+	for _, route := range routes {
+		if routeMatches(r.Route, route) {
+			return true, false, nil
+		}
+		// The kernel keys a route on its metric as well as its destination,
+		// so a route with another metric is added alongside, not replaced.
+		if ipNetEqual(r.Route.Dst, route.Dst) && (r.Route.Priority == 0 || r.Route.Priority == route.Priority) {
+			conflict = true
+		}
+	}
+	return false, conflict, nil
+}
+
+// routeMatches reports whether got, as listed from the kernel, satisfies want.
+// Gateway, link, preferred source, protocol, metric, MTU and advmss are only
+// compared when want sets them.
+// Attributes the kernel updates on its own, such as the remaining lifetime of
+// an expiring route, are never compared so they cannot cause churn.
+func routeMatches(want, got netlink.Route) bool {
+	if !ipNetEqual(want.Dst, got.Dst) || routeTable(want) != routeTable(got) {
+		return false
+	}
+	if want.Scope != got.Scope {
+		return false
+	}
+	// A local, blackhole or other typed route must not be satisfied by a
+	// unicast route to the same destination.
+	if want.Type != got.Type && !(isUnicast(want.Type) && isUnicast(got.Type)) {
+		return false
+	}
+	if want.Gw != nil && !want.Gw.Equal(got.Gw) {
+		return false
+	}
+	if want.LinkIndex != 0 && want.LinkIndex != got.LinkIndex {
+		return false
+	}
+	if want.Src != nil && !want.Src.Equal(got.Src) {
+		return false
+	}
+	// A route to the same destination from another owner is not netd's.
+	if want.Protocol != 0 && want.Protocol != got.Protocol {
+		return false
+	}
+	// A route differing only in metric is a separate route to the kernel.
+	if want.Priority != 0 && want.Priority != got.Priority {
+		return false
+	}
+	if want.MTU != 0 && want.MTU != got.MTU {
+		return false
+	}
+	if want.AdvMSS != 0 && want.AdvMSS != got.AdvMSS {
+		return false
+	}
+	return true
+}
+
+// isUnicast reports whether t is RTN_UNICAST, which a route left with the zero
+// RTN_UNSPEC type is added as.
+func isUnicast(t int) bool {
+	return t == unix.RTN_UNSPEC || t == unix.RTN_UNICAST
+}
+
+func routeTable(route netlink.Route) int {
+	if route.Table == unix.RT_TABLE_UNSPEC {
+		return unix.RT_TABLE_MAIN
+	}
+	return route.Table
+}
+
+func routeFamily(route netlink.Route) int {
+	switch {
+	case route.Dst != nil && route.Dst.IP.To4() == nil:
+		return netlink.FAMILY_V6
+	case route.Dst == nil && route.Gw != nil && route.Gw.To4() == nil:
+		return netlink.FAMILY_V6
+	}
+	return netlink.FAMILY_V4
+}
+
+// ipNetEqual compares two route destinations. A nil destination, as the
+// kernel reports for default routes, equals 0.0.0.0/0 and ::/0.
+func ipNetEqual(a, b *net.IPNet) bool {
+	if a == nil || b == nil {
+		return isDefaultDst(a) && isDefaultDst(b)
+	}
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	return a.IP.Equal(b.IP) && aOnes == bOnes && aBits == bBits
+}
+
+func isDefaultDst(dst *net.IPNet) bool {
+	if dst == nil {
+		return true
+	}
+	ones, _ := dst.Mask.Size()
+	return ones == 0 && dst.IP.IsUnspecified()
+}
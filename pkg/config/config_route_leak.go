@@ -0,0 +1,132 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+)
+
+// RouteLeakConfig mirrors the route to Dst in SrcTable, e.g. the main table,
+// into DstTable, a policy table. The source is read on every Ensure, so the
+// mirror follows changes to its gateway or device, and is removed while the
+// source route is missing.
+type RouteLeakConfig struct {
+	Dst                net.IPNet
+	SrcTable, DstTable int
+	RouteAdd           routeAdder
+	RouteDel           routeDeler
+	RouteList          routeLister
+	RouteReplace       routeReplacer
+}
+
+// NewRouteLeakConfig creates a RouteLeakConfig using netlink.
+func NewRouteLeakConfig(dst net.IPNet, srcTable, dstTable int) RouteLeakConfig {
+	return RouteLeakConfig{
+		Dst:          dst,
+		SrcTable:     srcTable,
+		DstTable:     dstTable,
+		RouteAdd:     netlink.RouteAdd,
+		RouteDel:     netlink.RouteDel,
+		RouteList:    netlink.RouteListFiltered,
+		RouteReplace: netlink.RouteReplace,
+	}
+}
+
+// Dynamic RouteLeakConfig
+func (RouteLeakConfig) Dynamic() bool {
+	return true
+}
+
+// Ensure RouteLeakConfig
+func (l RouteLeakConfig) Ensure(enabled bool) error {
+	_, err := l.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged RouteLeakConfig
+func (l RouteLeakConfig) EnsureChanged(enabled bool) (bool, error) {
+	if !enabled {
+		return l.deleteMirror()
+	}
+	source, found, err := l.source()
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		glog.V(2).Infof("no route to %v in table %d to mirror into table %d", &l.Dst, l.SrcTable, l.DstTable)
+		return l.deleteMirror()
+	}
+	mirror := source
+	mirror.Table = l.DstTable
+	mirror.Protocol = RouteProtocolNetd
+	r := IPRouteConfig{
+		Route:        mirror,
+		RouteAdd:     l.RouteAdd,
+		RouteDel:     l.RouteDel,
+		RouteList:    l.RouteList,
+		RouteReplace: l.RouteReplace,
+	}
+	return r.EnsureChanged(true)
+}
+
+// source returns the route to Dst in SrcTable.
+func (l RouteLeakConfig) source() (netlink.Route, bool, error) {
+	family := routeFamily(netlink.Route{Dst: &l.Dst})
+	routes, err := l.RouteList(family, &netlink.Route{Table: l.SrcTable}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return netlink.Route{}, false, fmt.Errorf("failed to list routes in table %d: %w", l.SrcTable, err)
+	}
+	for _, route := range routes {
+		if routeTable(route) == routeTable(netlink.Route{Table: l.SrcTable}) && ipNetEqual(route.Dst, &l.Dst) {
+			return route, true, nil
+		}
+	}
+	return netlink.Route{}, false, nil
+}
+
+// deleteMirror removes netd's routes to Dst from DstTable, whatever their
+// next hop.
+func (l RouteLeakConfig) deleteMirror() (bool, error) {
+	family := routeFamily(netlink.Route{Dst: &l.Dst})
+	routes, err := l.RouteList(family, &netlink.Route{Table: l.DstTable}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return false, fmt.Errorf("failed to list routes in table %d: %w", l.DstTable, err)
+	}
+	changed := false
+	var errs []error
+	for i := range routes {
+		route := routes[i]
+		if routeTable(route) != l.DstTable || !ipNetEqual(route.Dst, &l.Dst) || route.Protocol != RouteProtocolNetd {
+			continue
+		}
+		if err := l.RouteDel(&route); err != nil {
+			if !errors.Is(err, syscall.ESRCH) {
+				errs = append(errs, fmt.Errorf("failed to delete mirrored route %v: %w", route, err))
+			}
+			continue
+		}
+		diffLogf("deleted ip route %s", describeRoute(route))
+		changed = true
+	}
+	return changed, errors.Join(errs...)
+}
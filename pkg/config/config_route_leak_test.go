@@ -0,0 +1,80 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestRouteLeakConfig(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.50.0.0/16")
+	source := netlink.Route{Dst: dst, Gw: net.IPv4(10, 128, 0, 1), LinkIndex: 2, Table: unix.RT_TABLE_MAIN, Protocol: unix.RTPROT_BOOT}
+	fake := &fakeRouteTable{routes: []netlink.Route{source}}
+	l := NewRouteLeakConfig(*dst, unix.RT_TABLE_MAIN, 200)
+	l.RouteAdd, l.RouteDel, l.RouteList, l.RouteReplace = fake.add, fake.del, fake.list, fake.replace
+
+	mirror := func() *netlink.Route {
+		for i, r := range fake.routes {
+			if r.Table == 200 {
+				return &fake.routes[i]
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if changed, err := l.EnsureChanged(true); err != nil || changed != (i == 0) {
+			t.Fatalf("EnsureChanged(true) #%d = %v, %v", i, changed, err)
+		}
+	}
+	if m := mirror(); m == nil || !m.Gw.Equal(source.Gw) || m.LinkIndex != 2 || m.Protocol != RouteProtocolNetd {
+		t.Fatalf("expected the route mirrored into table 200, got %v", fake.routes)
+	}
+
+	// The source moves to another gateway.
+	fake.routes[0].Gw = net.IPv4(10, 128, 0, 2)
+	if err := l.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if m := mirror(); m == nil || !m.Gw.Equal(net.IPv4(10, 128, 0, 2)) || len(fake.routes) != 2 {
+		t.Fatalf("the mirror should follow the source gateway, got %v", fake.routes)
+	}
+
+	// The source goes away.
+	fake.routes = fake.routes[1:]
+	if err := l.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if len(fake.routes) != 0 {
+		t.Fatalf("the mirror should be removed with its source, got %v", fake.routes)
+	}
+
+	fake.routes = []netlink.Route{source}
+	if err := l.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if err := l.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].Table != unix.RT_TABLE_MAIN {
+		t.Errorf("Ensure(false) should remove only the mirror, got %v", fake.routes)
+	}
+}
@@ -0,0 +1,688 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakeRouteTable mimics the kernel FIB: routes are keyed by table and dst
+// only, a delete with the universe scope matches any scope and a delete
+// without a link matches any link.
+type fakeRouteTable struct {
+	routes []netlink.Route
+}
+
+func (f *fakeRouteTable) add(route *netlink.Route) error {
+	for _, r := range f.routes {
+		if routeTable(r) == routeTable(*route) && ipNetEqual(r.Dst, route.Dst) && r.Priority == route.Priority {
+			return syscall.EEXIST
+		}
+	}
+	f.routes = append(f.routes, *route)
+	return nil
+}
+
+func (f *fakeRouteTable) del(route *netlink.Route) error {
+	for i, r := range f.routes {
+		if routeTable(r) != routeTable(*route) || !ipNetEqual(r.Dst, route.Dst) {
+			continue
+		}
+		if route.Scope != netlink.SCOPE_UNIVERSE && route.Scope != r.Scope {
+			continue
+		}
+		if route.LinkIndex != 0 && route.LinkIndex != r.LinkIndex {
+			continue
+		}
+		if route.Priority != 0 && route.Priority != r.Priority {
+			continue
+		}
+		f.routes = append(f.routes[:i], f.routes[i+1:]...)
+		return nil
+	}
+	return syscall.ESRCH
+}
+
+func (f *fakeRouteTable) list(_ int, filter *netlink.Route, _ uint64) ([]netlink.Route, error) {
+	var routes []netlink.Route
+	for _, r := range f.routes {
+		if routeTable(r) == routeTable(*filter) {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+func (f *fakeRouteTable) config(route netlink.Route) IPRouteConfig {
+	return IPRouteConfig{
+		Route:     route,
+		RouteAdd:  f.add,
+		RouteDel:  f.del,
+		RouteList: f.list,
+	}
+}
+
+func TestLinkScopeRouteConfig(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	fake := &fakeRouteTable{}
+
+	c := NewLinkScopeRouteConfig(*dst, 2, customRouteTable)
+	if c.Route.Scope != netlink.SCOPE_LINK {
+		t.Fatalf("NewLinkScopeRouteConfig scope = %v, want link", c.Route.Scope)
+	}
+	c = fake.config(c.Route)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fake.routes) != 1 || fake.routes[0].Scope != netlink.SCOPE_LINK {
+		t.Fatalf("expected a single link-scoped route, got %v", fake.routes)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 0 {
+		t.Errorf("Ensure(false) should remove the link-scoped route, got %v", fake.routes)
+	}
+}
+
+func TestRouteScopeNotConflated(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	global := netlink.Route{Dst: dst, Gw: net.IPv4(10, 0, 0, 1), Table: customRouteTable}
+	fake := &fakeRouteTable{routes: []netlink.Route{global}}
+
+	c := fake.config(NewLinkScopeRouteConfig(*dst, 2, customRouteTable).Route)
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should report the conflicting global route instead of treating it as present")
+	}
+
+	// Tearing down the link-scoped route must not remove the global one.
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].Scope != netlink.SCOPE_UNIVERSE {
+		t.Errorf("Ensure(false) should leave the global route in place, got %v", fake.routes)
+	}
+}
+
+func TestRouteNearDuplicates(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	want := netlink.Route{
+		Dst:       dst,
+		Gw:        net.IPv4(10, 0, 0, 1),
+		LinkIndex: 2,
+		Table:     customRouteTable,
+		Priority:  100,
+		Protocol:  RouteProtocolNetd,
+	}
+	_, otherDst, _ := net.ParseCIDR("10.1.1.0/24")
+
+	tests := []struct {
+		desc   string
+		modify func(r *netlink.Route)
+		// routes is the number of routes expected once want is installed.
+		routes int
+	}{
+		{"identical", func(r *netlink.Route) {}, 1},
+		{"dst", func(r *netlink.Route) { r.Dst = otherDst }, 2},
+		{"gateway", func(r *netlink.Route) { r.Gw = net.IPv4(10, 0, 0, 2) }, 1},
+		{"scope", func(r *netlink.Route) { r.Scope = netlink.SCOPE_LINK }, 1},
+		{"metric", func(r *netlink.Route) { r.Priority = 200 }, 2},
+		{"table", func(r *netlink.Route) { r.Table = customRouteTable + 1 }, 2},
+		{"protocol", func(r *netlink.Route) { r.Protocol = unix.RTPROT_BOOT }, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			existing := want
+			tc.modify(&existing)
+			fake := &fakeRouteTable{routes: []netlink.Route{existing}}
+			c := fake.config(want)
+			c.RouteReplace = fake.replace
+
+			changed, err := c.EnsureChanged(true)
+			if err != nil {
+				t.Fatalf("EnsureChanged(true) returned error: %v", err)
+			}
+			if changed == (tc.desc == "identical") {
+				t.Errorf("EnsureChanged(true) reported changed=%v with an existing route differing in %s", changed, tc.desc)
+			}
+			if len(fake.routes) != tc.routes {
+				t.Errorf("expected %d routes, got %v", tc.routes, fake.routes)
+			}
+			found := false
+			for _, r := range fake.routes {
+				found = found || routeMatches(want, r)
+			}
+			if !found {
+				t.Errorf("the wanted route is missing, got %v", fake.routes)
+			}
+
+			// Once converged, a second Ensure must be a no-op.
+			if changed, err := c.EnsureChanged(true); err != nil || changed {
+				t.Errorf("second EnsureChanged(true) = %v, %v, want false, nil", changed, err)
+			}
+		})
+	}
+}
+
+func (f *fakeRouteTable) replace(route *netlink.Route) error {
+	for i, r := range f.routes {
+		if routeTable(r) == routeTable(*route) && ipNetEqual(r.Dst, route.Dst) && r.Priority == route.Priority {
+			f.routes[i] = *route
+			return nil
+		}
+	}
+	f.routes = append(f.routes, *route)
+	return nil
+}
+
+func TestRouteWithPrefSrc(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("0.0.0.0/0")
+	gw := net.IPv4(10, 0, 0, 1)
+	src := net.IPv4(10, 0, 0, 5)
+	fake := &fakeRouteTable{}
+
+	c := NewRouteWithPrefSrc(*dst, gw, src, 2)
+	c.RouteAdd, c.RouteDel, c.RouteList, c.RouteReplace = fake.add, fake.del, fake.list, fake.replace
+
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Src.Equal(src) {
+		t.Fatalf("expected a route with pref src %v, got %v", src, fake.routes)
+	}
+
+	// Someone changes the preferred source; the next reconcile corrects it.
+	fake.routes[0].Src = net.IPv4(10, 0, 0, 9)
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Src.Equal(src) {
+		t.Errorf("expected the pref src to be corrected to %v, got %v", src, fake.routes)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 0 {
+		t.Errorf("Ensure(false) should remove the route, got %v", fake.routes)
+	}
+}
+
+func TestRouteConfigCustomTables(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.2.0.0/16")
+	fake := &fakeRouteTable{}
+	var configs []IPRouteConfig
+	for _, table := range []int{100, 200} {
+		c := fake.config(netlink.Route{Dst: dst, Gw: net.IPv4(10, 0, 0, byte(table/100)), Table: table})
+		configs = append(configs, c)
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) for table %d returned error: %v", table, err)
+		}
+	}
+	// Re-ensuring must find each route in its own table.
+	for _, c := range configs {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("second Ensure(true) for table %d returned error: %v", c.Route.Table, err)
+		}
+	}
+	if len(fake.routes) != 2 {
+		t.Fatalf("expected one route per table, got %v", fake.routes)
+	}
+
+	if err := configs[0].Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].Table != 200 {
+		t.Errorf("Ensure(false) should only remove the route in table 100, got %v", fake.routes)
+	}
+
+	// The route in table 100 is gone, so the one in table 200 does not
+	// count as present for it.
+	if err := configs[0].Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 {
+		t.Errorf("Ensure(false) should not touch table 200, got %v", fake.routes)
+	}
+}
+
+func TestIPRouteConfigEnsureDelError(t *testing.T) {
+	r := IPRouteConfig{
+		RouteDel: func(route *netlink.Route) error { return os.ErrPermission },
+	}
+	if err := r.Ensure(false); err != os.ErrPermission {
+		t.Errorf("Ensure(false) should return non-errno errors, got %v", err)
+	}
+}
+
+func TestRouteVerifyAfterApply(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.3.0.0/16")
+	fake := &fakeRouteTable{}
+	c := fake.config(netlink.Route{Dst: dst, LinkIndex: 2, Table: 100})
+	c.VerifyAfterApply = true
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+
+	// A kernel that acknowledges the add but forgets the route.
+	fake.routes = nil
+	c.RouteAdd = func(route *netlink.Route) error { return nil }
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should fail when the added route is not listed afterwards")
+	}
+
+	c.RouteList = nil
+	if err := c.Ensure(true); err == nil {
+		t.Error("VerifyAfterApply without RouteList should be rejected")
+	}
+}
+
+func TestFlushRoutes(t *testing.T) {
+	_, a, _ := net.ParseCIDR("10.1.0.0/24")
+	_, b, _ := net.ParseCIDR("10.2.0.0/24")
+	fake := &fakeRouteTable{routes: []netlink.Route{
+		{Dst: a, Table: customRouteTable, Protocol: unix.RTPROT_STATIC},
+		{Dst: b, Table: customRouteTable, Protocol: unix.RTPROT_BOOT},
+		{Dst: a, Protocol: unix.RTPROT_STATIC},
+	}}
+	// Like RouteListFiltered, list applies only the table filter.
+	filter := netlink.Route{Table: customRouteTable, Protocol: unix.RTPROT_STATIC}
+	if deleted, err := flushRoutes(fake.list, fake.del, filter, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_PROTOCOL); err != nil || !deleted {
+		t.Fatalf("flushRoutes = %v, %v, want true, nil", deleted, err)
+	}
+	if len(fake.routes) != 2 {
+		t.Fatalf("only the static route in table %d should be flushed, got %v", customRouteTable, fake.routes)
+	}
+	for _, r := range fake.routes {
+		if routeTable(r) == customRouteTable && r.Protocol == unix.RTPROT_STATIC {
+			t.Errorf("route %v should have been flushed", r)
+		}
+	}
+
+	c := RouteFlushConfig{Filter: netlink.Route{Table: customRouteTable}, Mask: netlink.RT_FILTER_TABLE, RouteList: fake.list, RouteDel: fake.del}
+	if changed, err := c.EnsureChanged(true); changed || err != nil || len(fake.routes) != 2 {
+		t.Errorf("EnsureChanged(true) = %v, %v, want false, nil and nothing flushed", changed, err)
+	}
+	if changed, err := c.EnsureChanged(false); !changed || err != nil {
+		t.Fatalf("EnsureChanged(false) = %v, %v, want true, nil", changed, err)
+	}
+	if len(fake.routes) != 1 || routeTable(fake.routes[0]) != unix.RT_TABLE_MAIN {
+		t.Errorf("Ensure(false) should flush table %d only, got %v", customRouteTable, fake.routes)
+	}
+	if changed, err := c.EnsureChanged(false); changed || err != nil {
+		t.Errorf("EnsureChanged(false) with nothing left to flush = %v, %v, want false, nil", changed, err)
+	}
+}
+
+func TestLocalVIPRouteConfig(t *testing.T) {
+	for _, tc := range []struct {
+		vip  string
+		bits int
+	}{
+		{"10.0.0.10", 32},
+		{"fd00::10", 128},
+	} {
+		fake := &fakeRouteTable{}
+		c := NewLocalVIPRouteConfig(net.ParseIP(tc.vip))
+		if ones, bits := c.Route.Dst.Mask.Size(); ones != tc.bits || bits != tc.bits {
+			t.Errorf("%s: route mask = /%d, want /%d", tc.vip, ones, tc.bits)
+		}
+		if c.Route.Type != unix.RTN_LOCAL || c.Route.Table != unix.RT_TABLE_LOCAL || c.Route.LinkIndex != loopbackIndex {
+			t.Errorf("%s: unexpected route %v", tc.vip, c.Route)
+		}
+		c = fake.config(c.Route)
+
+		// A unicast route to the VIP in the local table is not the local route.
+		unicast := c.Route
+		unicast.Type = unix.RTN_UNICAST
+		if routeMatches(c.Route, unicast) {
+			t.Errorf("%s: a unicast route should not satisfy a local route", tc.vip)
+		}
+
+		for i := 0; i < 2; i++ {
+			if changed, err := c.EnsureChanged(true); err != nil || changed != (i == 0) {
+				t.Fatalf("%s: EnsureChanged(true) #%d = %v, %v", tc.vip, i, changed, err)
+			}
+		}
+		if len(fake.routes) != 1 {
+			t.Fatalf("%s: expected a single local route, got %v", tc.vip, fake.routes)
+		}
+		if err := c.Ensure(false); err != nil {
+			t.Fatalf("%s: Ensure(false) returned error: %v", tc.vip, err)
+		}
+		if len(fake.routes) != 0 {
+			t.Errorf("%s: Ensure(false) should remove the local route, got %v", tc.vip, fake.routes)
+		}
+	}
+}
+
+func TestMTURouteConfig(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.8.0.0/16")
+	fake := &fakeRouteTable{}
+	c := NewMTURouteConfig(*dst, net.IPv4(10, 0, 0, 1), 2, 1400, 1360)
+	c.RouteAdd, c.RouteDel, c.RouteList, c.RouteReplace = fake.add, fake.del, fake.list, fake.replace
+
+	for i := 0; i < 2; i++ {
+		if changed, err := c.EnsureChanged(true); err != nil || changed != (i == 0) {
+			t.Fatalf("EnsureChanged(true) #%d = %v, %v", i, changed, err)
+		}
+	}
+	if len(fake.routes) != 1 || fake.routes[0].MTU != 1400 || fake.routes[0].AdvMSS != 1360 {
+		t.Fatalf("expected a route with mtu 1400 advmss 1360, got %v", fake.routes)
+	}
+
+	// The MTU drifts; the next reconcile replaces the route.
+	fake.routes[0].MTU = 1500
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) after MTU drift = %v, %v", changed, err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].MTU != 1400 {
+		t.Errorf("expected the MTU to be corrected to 1400, got %v", fake.routes)
+	}
+}
+
+func TestRouteKeepForeignRoutes(t *testing.T) {
+	gw := net.IPv4(10, 0, 0, 1)
+	fake := &fakeRouteTable{}
+	c := fake.config(netlink.Route{Gw: gw, LinkIndex: 2, Table: customRouteTable})
+	c.RouteReplace = fake.replace
+	c.KeepForeignRoutes = true
+
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) with no default route returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Gw.Equal(gw) {
+		t.Fatalf("expected the default route via %v, got %v", gw, fake.routes)
+	}
+
+	foreignGw := net.IPv4(10, 0, 0, 254)
+	fake.routes[0].Gw = foreignGw
+	err := c.Ensure(true)
+	if !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("Ensure(true) with a foreign default route = %v, want ErrRouteConflict", err)
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Gw.Equal(foreignGw) {
+		t.Errorf("the foreign default route should be left alone, got %v", fake.routes)
+	}
+
+	c.RouteList = nil
+	if err := c.Ensure(true); err == nil {
+		t.Error("KeepForeignRoutes without RouteList should be rejected")
+	}
+}
+
+func TestRouteProtocolOwnership(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	c := NewLinkScopeRouteConfig(*dst, 2, customRouteTable)
+	if c.Route.Protocol != RouteProtocolNetd {
+		t.Fatalf("netd routes should carry protocol %d, got %d", RouteProtocolNetd, c.Route.Protocol)
+	}
+
+	// A route to the same destination owned by someone else is neither
+	// treated as netd's nor deleted on teardown.
+	foreign := netlink.Route{Dst: dst, LinkIndex: 2, Table: customRouteTable, Scope: netlink.SCOPE_LINK, Protocol: unix.RTPROT_KERNEL}
+	fake := &fakeRouteTable{routes: []netlink.Route{foreign}}
+	c = fake.config(c.Route)
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].Protocol != unix.RTPROT_KERNEL {
+		t.Fatalf("the foreign route should be left alone, got %v", fake.routes)
+	}
+
+	// With RouteReplace, drift detection takes the route over.
+	c.RouteReplace = fake.replace
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].Protocol != RouteProtocolNetd {
+		t.Fatalf("the route should be replaced with netd's protocol, got %v", fake.routes)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 0 {
+		t.Errorf("netd's own route should be deleted, got %v", fake.routes)
+	}
+}
+
+func TestRouteLinkNameReresolved(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	fake := &fakeRouteTable{}
+	index := 2
+	c := fake.config(NewLinkScopeRouteConfig(*dst, index, customRouteTable).Route)
+	c = c.WithLinkName("eth1", func(name string) (netlink.Link, error) {
+		if index == 0 {
+			return nil, netlink.LinkNotFoundError{}
+		}
+		return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name, Index: index}}, nil
+	})
+	var dels int
+	routeDel := c.RouteDel
+	c.RouteDel = func(route *netlink.Route) error {
+		dels++
+		return routeDel(route)
+	}
+
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+
+	// eth1 is recreated with a new index; the route on the old one is stale.
+	index = 5
+	changed, err := c.EnsureChanged(true)
+	if err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) after the index change = %v, %v; want true, nil", changed, err)
+	}
+	if len(fake.routes) != 1 || fake.routes[0].LinkIndex != 5 {
+		t.Fatalf("expected a single route on link index 5, got %v", fake.routes)
+	}
+	dels = 0
+	if changed, err := c.EnsureChanged(true); err != nil || changed {
+		t.Errorf("a second EnsureChanged(true) = %v, %v; want false, nil", changed, err)
+	}
+	if dels != 0 {
+		t.Errorf("the stale route should be deleted once, got %d more deletes", dels)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 0 {
+		t.Errorf("Ensure(false) should remove the route, got %v", fake.routes)
+	}
+
+	index = 0
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should fail while the link is missing")
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Errorf("Ensure(false) with the link gone returned error: %v", err)
+	}
+}
+
+func TestTableBlackholeDefault(t *testing.T) {
+	for _, c := range []IPRouteConfig{NewTableBlackholeDefault(300), NewTableBlackholeDefaultV6(300)} {
+		if c.Route.Type != unix.RTN_BLACKHOLE || !isDefaultDst(c.Route.Dst) {
+			t.Fatalf("expected a blackhole default route, got %v", c.Route)
+		}
+		family := routeFamily(c.Route)
+
+		// A unicast default in another table is not the blackhole, and the
+		// kernel lists the blackhole with a nil Dst and, for IPv6, on lo.
+		other := netlink.Route{Dst: c.Route.Dst, Gw: net.IPv4(10, 0, 0, 1), Table: unix.RT_TABLE_MAIN}
+		fake := &fakeRouteTable{routes: []netlink.Route{other}}
+		c = fake.config(c.Route)
+		c.RouteList = func(f int, filter *netlink.Route, mask uint64) ([]netlink.Route, error) {
+			if f != family {
+				t.Errorf("listed family %d, want %d", f, family)
+			}
+			routes, err := fake.list(f, filter, mask)
+			for i := range routes {
+				if routes[i].Type == unix.RTN_BLACKHOLE {
+					routes[i].Dst = nil
+					if family == netlink.FAMILY_V6 {
+						routes[i].LinkIndex = loopbackIndex
+					}
+				}
+			}
+			return routes, err
+		}
+
+		for i := 0; i < 2; i++ {
+			if changed, err := c.EnsureChanged(true); err != nil || changed != (i == 0) {
+				t.Fatalf("EnsureChanged(true) #%d = %v, %v", i, changed, err)
+			}
+		}
+		if len(fake.routes) != 2 {
+			t.Fatalf("expected the blackhole next to the main default, got %v", fake.routes)
+		}
+		if err := c.Ensure(false); err != nil {
+			t.Fatalf("Ensure(false) returned error: %v", err)
+		}
+		if len(fake.routes) != 1 || fake.routes[0].Table != unix.RT_TABLE_MAIN {
+			t.Errorf("only the blackhole should be removed, got %v", fake.routes)
+		}
+	}
+}
+
+func TestRouteGatewayResolver(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("0.0.0.0/0")
+	fake := &fakeRouteTable{}
+	c := fake.config(netlink.Route{Dst: dst, LinkIndex: 2, Table: 400, Protocol: RouteProtocolNetd})
+	gw := net.IPv4(10, 0, 0, 10)
+	var resolveErr error
+	c.GatewayResolver = func() (net.IP, error) { return gw, resolveErr }
+	if err := c.Ensure(true); err == nil {
+		t.Fatal("GatewayResolver without RouteReplace should be rejected")
+	}
+	c.RouteReplace = fake.replace
+
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Gw.Equal(gw) {
+		t.Fatalf("expected a single route via %v, got %v", gw, fake.routes)
+	}
+
+	gw = net.IPv4(10, 0, 0, 20)
+	if changed, err := c.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) after the gateway moved = %v, %v; want true, nil", changed, err)
+	}
+	if len(fake.routes) != 1 || !fake.routes[0].Gw.Equal(gw) {
+		t.Fatalf("the route should be replaced via %v, got %v", gw, fake.routes)
+	}
+
+	// A failed resolution leaves the route alone, and teardown does not need
+	// the gateway.
+	resolveErr = errors.New("service not found")
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should report the resolver error")
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.routes) != 0 {
+		t.Errorf("Ensure(false) should remove the route, got %v", fake.routes)
+	}
+}
+
+func TestRouteSrcAddrDeferred(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	src := net.ParseIP("10.128.0.5")
+	var addrs []netlink.Addr
+	addrList := func(_ netlink.Link, family int) ([]netlink.Addr, error) {
+		if family != netlink.FAMILY_V4 {
+			t.Errorf("addresses listed for family %d, want IPv4", family)
+		}
+		return addrs, nil
+	}
+	routes := &fakeRouteTable{}
+	r := routes.config(netlink.Route{Dst: dst, Src: src, LinkIndex: 2, Table: 100})
+	r.SrcAddrList = addrList
+
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{r}}
+	var summary ReconcileSummary
+	s.OnReconcileComplete = func(rs ReconcileSummary) { summary = rs }
+	if err := s.Ensure(); !errors.Is(err, ErrDeferred) {
+		t.Errorf("Ensure() without the source address = %v, want ErrDeferred", err)
+	}
+	if summary.Deferred != 1 || summary.Failed != 0 || len(routes.routes) != 0 {
+		t.Errorf("summary %+v with routes %v, want one deferred route and nothing added", summary, routes.routes)
+	}
+
+	addrs = []netlink.Addr{{IPNet: &net.IPNet{IP: src, Mask: net.CIDRMask(32, 32)}}}
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure() with the source address returned error: %v", err)
+	}
+	if summary.Changed != 1 || len(routes.routes) != 1 {
+		t.Errorf("summary %+v with routes %v, want the route added", summary, routes.routes)
+	}
+
+	// Removal does not need the address.
+	addrs = nil
+	if err := r.Ensure(false); err != nil || len(routes.routes) != 0 {
+		t.Errorf("Ensure(false) = %v with routes %v, want the route removed", err, routes.routes)
+	}
+}
+
+func TestLocalRouteConfigs(t *testing.T) {
+	ips := []net.IP{net.ParseIP("10.128.0.2"), net.ParseIP("10.128.0.3"), net.ParseIP("fd00::2")}
+	configs := NewLocalRouteConfigs(ips, 2)
+	if len(configs) != len(ips) {
+		t.Fatalf("got %d configs for %d IPs", len(configs), len(ips))
+	}
+	routes := &fakeRouteTable{}
+	for i := range configs {
+		c := configs[i].(IPRouteConfig)
+		configs[i] = routes.config(c.Route)
+	}
+	s := Set{Enabled: true, FeatureName: "test", Configs: configs}
+	for i := 0; i < 2; i++ {
+		if err := s.Ensure(); err != nil {
+			t.Fatalf("Ensure() returned error: %v", err)
+		}
+	}
+	if len(routes.routes) != len(ips) {
+		t.Fatalf("got routes %v, want one per node IP", routes.routes)
+	}
+	for i, ip := range ips {
+		r := routes.routes[i]
+		ones, bits := r.Dst.Mask.Size()
+		if !r.Dst.IP.Equal(ip) || ones != bits || r.Type != unix.RTN_LOCAL || r.Table != unix.RT_TABLE_LOCAL || r.LinkIndex != 2 {
+			t.Errorf("route for %v = %+v, want a local host route on link 2", ip, r)
+		}
+	}
+
+	s.Enabled = false
+	if err := s.Ensure(); err != nil || len(routes.routes) != 0 {
+		t.Errorf("Ensure() of the disabled Set = %v with routes %v, want them removed", err, routes.routes)
+	}
+}
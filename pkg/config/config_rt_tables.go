@@ -0,0 +1,115 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// RTTablesPath is where iproute2 looks up routing table names.
+const RTTablesPath = "/etc/iproute2/rt_tables"
+
+// RTTablesConfig registers Name for the routing table ID in an rt_tables
+// file, so that `ip route show table <name>` works for the tables netd uses.
+// The kernel only knows the number, so this is purely for operators. The
+// entry is appended once and left in place when disabled, as other tools may
+// rely on it too.
+type RTTablesConfig struct {
+	Path string
+	Name string
+	ID   int
+}
+
+// NewRTTablesConfig creates a RTTablesConfig for RTTablesPath.
+func NewRTTablesConfig(name string, id int) RTTablesConfig {
+	return RTTablesConfig{Path: RTTablesPath, Name: name, ID: id}
+}
+
+// Ensure RTTablesConfig
+func (c RTTablesConfig) Ensure(enabled bool) error {
+	_, err := c.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged RTTablesConfig
+func (c RTTablesConfig) EnsureChanged(enabled bool) (bool, error) {
+	if !enabled {
+		return false, nil
+	}
+	if c.Name == "" || strings.ContainsAny(c.Name, " \t\n#") {
+		return false, fmt.Errorf("invalid routing table name %q", c.Name)
+	}
+	data, err := os.ReadFile(c.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	found, err := c.lookup(data)
+	if err != nil || found {
+		return false, err
+	}
+
+	f, err := os.OpenFile(c.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return false, err
+	}
+	entry := fmt.Sprintf("%d\t%s\n", c.ID, c.Name)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		entry = "\n" + entry
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	glog.Infof("registered routing table %d as %q in %s", c.ID, c.Name, c.Path)
+	return true, nil
+}
+
+// lookup reports whether data already maps Name to ID. Name or ID being
+// mapped to something else is an error, as the file would be ambiguous.
+func (c RTTablesConfig) lookup(data []byte) (bool, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		id, err := strconv.ParseInt(fields[0], 0, 64)
+		if err != nil {
+			continue
+		}
+		switch name := fields[1]; {
+		case int(id) == c.ID && name == c.Name:
+			return true, nil
+		case int(id) == c.ID:
+			return false, fmt.Errorf("routing table %d is already named %q in %s", c.ID, name, c.Path)
+		case name == c.Name:
+			return false, fmt.Errorf("routing table name %q is already used for table %d in %s", c.Name, id, c.Path)
+		}
+	}
+	return false, scanner.Err()
+}
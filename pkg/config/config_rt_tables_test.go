@@ -0,0 +1,97 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const defaultRTTables = `#
+# reserved values
+#
+255	local
+254	main
+253	default
+0	unspec
+#
+# local
+#
+#1	inr.ruhep`
+
+func TestRTTablesConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rt_tables")
+	if err := os.WriteFile(path, []byte(defaultRTTables), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := RTTablesConfig{Path: path, Name: "netd-pod", ID: 0x1}
+
+	for i, wantChanged := range []bool{true, false, false} {
+		changed, err := c.EnsureChanged(true)
+		if err != nil {
+			t.Fatalf("run %d: EnsureChanged(true) returned error: %v", i, err)
+		}
+		if changed != wantChanged {
+			t.Errorf("run %d: EnsureChanged(true) = %v, want %v", i, changed, wantChanged)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaultRTTables + "\n1\tnetd-pod\n"
+	if string(data) != want {
+		t.Errorf("rt_tables = %q, want %q", data, want)
+	}
+
+	if changed, err := c.EnsureChanged(false); err != nil || changed {
+		t.Errorf("EnsureChanged(false) = %v, %v; want the entry left in place", changed, err)
+	}
+}
+
+func TestRTTablesConfigMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rt_tables")
+	c := RTTablesConfig{Path: path, Name: "netd", ID: 300}
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "300\tnetd\n" {
+		t.Errorf("rt_tables = %q, want a single entry", data)
+	}
+}
+
+func TestRTTablesConfigConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rt_tables")
+	orig := defaultRTTables + "\n0x64 other\n"
+	if err := os.WriteFile(path, []byte(orig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []RTTablesConfig{
+		{Path: path, Name: "netd", ID: 100},
+		{Path: path, Name: "other", ID: 101},
+		{Path: path, Name: "main", ID: 100},
+		{Path: path, Name: "bad name", ID: 102},
+	} {
+		if err := c.Ensure(true); err == nil {
+			t.Errorf("Ensure(true) for %d %q should fail", c.ID, c.Name)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != orig {
+		t.Errorf("rt_tables changed on conflict: %q", data)
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/metrics"
+)
+
+// ConflictPolicy is how IPRuleConfig.Ensure handles foreign rules, other
+// rules at the priority of its rule, e.g. left by another agent.
+type ConflictPolicy int
+
+const (
+	// ConflictCoexist adds the rule next to foreign rules, which the kernel
+	// evaluates in the order they were added.
+	ConflictCoexist ConflictPolicy = iota
+	// ConflictOverwrite deletes foreign rules before adding the rule. A Set
+	// holding another rule at the same priority fails to Ensure, as that rule
+	// would be deleted too.
+	ConflictOverwrite
+	// ConflictDefer leaves foreign rules in place and, while the rule is not
+	// installed yet, fails with ErrRuleConflict instead of adding it.
+	ConflictDefer
+)
+
+// ErrRuleConflict is returned, wrapped, when a foreign rule occupies the
+// priority of a ConflictDefer rule. It is an ErrDeferred.
+var ErrRuleConflict = fmt.Errorf("%w: conflicting ip rule", ErrDeferred)
+
+// newRuleConfig returns an IPRuleConfig looking up table with every optional
+// match unset, using the netlink rule functions.
+func newRuleConfig(table int) IPRuleConfig {
+	rule := netlink.NewRule()
+	rule.Table = table
+	return IPRuleConfig{
+		Rule:     *rule,
+		RuleAdd:  netlink.RuleAdd,
+		RuleDel:  netlink.RuleDel,
+		RuleList: netlink.RuleList,
+	}
+}
+
+// checkRuleTable rejects the rules netlink would silently change. Tables
+// above 255 are sent in the 32-bit FRA_TABLE attribute, leaving the legacy
+// 8-bit table unspecified, and netlink only sends suppress_prefixlen and
+// suppress_ifgroup along with the legacy table, so it would drop them.
+func checkRuleTable(rule netlink.Rule) error {
+	if rule.Table < 0 {
+		return fmt.Errorf("invalid routing table %d for ip rule %s", rule.Table, describeRule(rule))
+	}
+	if (rule.Table == 0 || rule.Table > 255) && (rule.SuppressPrefixlen >= 0 || rule.SuppressIfgroup >= 0) {
+		return fmt.Errorf("ip rule %s: suppress_prefixlen and suppress_ifgroup require a table from 1 to 255", describeRule(rule))
+	}
+	return nil
+}
+
+// tableEmpty reports whether the rule's table holds no route of its family.
+func (r IPRuleConfig) tableEmpty() (bool, error) {
+	family := netlink.FAMILY_V4
+	if ruleFamily(r.Rule) == unix.AF_INET6 {
+		family = netlink.FAMILY_V6
+	}
+	filter := &netlink.Route{Table: r.Rule.Table}
+	routes, err := r.TableRouteList(family, filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		metrics.RecordNetlinkError("route_list", err)
+		return false, fmt.Errorf("failed to list routes in table %d: %w", r.Rule.Table, err)
+	}
+	for _, route := range routes {
+		if routeTable(route) == routeTable(*filter) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// NewDscpRuleConfig creates an IPRuleConfig sending packets with the given
+// DSCP value to table. The DSCP is carried in the upper six bits of the
+// rule's TOS field.
+func NewDscpRuleConfig(dscp int, table int) IPRuleConfig {
+	c := newRuleConfig(table)
+	c.Rule.Tos = uint(dscp<<2) & 0xfc
+	return c
+}
+
+// NewIPProtoRuleConfig creates an IPRuleConfig sending packets of the L4
+// protocol proto, e.g. unix.IPPROTO_TCP, to table, like
+// "ip rule add ipproto tcp lookup <table>".
+func NewIPProtoRuleConfig(proto int, table int) IPRuleConfig {
+	c := newRuleConfig(table)
+	c.Rule.IPProto = proto
+	return c
+}
+
+// NewMarkDscpRuleConfig creates an IPRuleConfig sending packets that carry
+// both the fwmark mark/mask and the given DSCP value to table. Rules differing
+// in either field are distinct, both in the kernel and to Ensure.
+func NewMarkDscpRuleConfig(mark, mask uint32, dscp int, table int) IPRuleConfig {
+	c := NewDscpRuleConfig(dscp, table)
+	c.Rule.Mark = int(mark)
+	c.Rule.Mask = int(mask)
+	return c
+}
+
+// PickTable deterministically maps key to one of tables using rendezvous
+// (highest random weight) hashing: each table is scored with FNV-64a over the
+// key followed by the table ID as a big-endian uint32, and the highest score
+// wins, ties going to the lower ID. Every node computes the same mapping, and
+// removing a table only remaps the keys that were assigned to it.
+func PickTable(key string, tables []int) (int, error) {
+	if len(tables) == 0 {
+		return 0, fmt.Errorf("no routing tables to pick from for %q", key)
+	}
+	best, bestScore := 0, uint64(0)
+	for i, table := range tables {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		var id [4]byte
+		binary.BigEndian.PutUint32(id[:], uint32(table))
+		h.Write(id[:])
+		score := h.Sum64()
+		if i == 0 || score > bestScore || score == bestScore && table < best {
+			best, bestScore = table, score
+		}
+	}
+	return best, nil
+}
+
+// NewHashedSourceRuleConfig creates an IPRuleConfig sending traffic from ip
+// to the table PickTable selects for it among tables.
+func NewHashedSourceRuleConfig(ip net.IP, tables []int) (IPRuleConfig, error) {
+	table, err := PickTable(ip.String(), tables)
+	if err != nil {
+		return IPRuleConfig{}, err
+	}
+	bits := 32
+	if ip.To4() == nil {
+		bits = 128
+	}
+	c := newRuleConfig(table)
+	c.Rule.Src = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	return c, nil
+}
+
+// DualStackRuleConfig ensures an IPv4 and an IPv6 IPRuleConfig together.
+type DualStackRuleConfig struct {
+	V4, V6 IPRuleConfig
+}
+
+// NewDualStackRuleConfig creates a DualStackRuleConfig, pinning each rule to
+// its family so that both are listed and counted in the right one.
+func NewDualStackRuleConfig(v4, v6 IPRuleConfig) DualStackRuleConfig {
+	v4.Rule.Family = netlink.FAMILY_V4
+	v6.Rule.Family = netlink.FAMILY_V6
+	return DualStackRuleConfig{V4: v4, V6: v6}
+}
+
+// Dynamic DualStackRuleConfig
+func (d DualStackRuleConfig) Dynamic() bool {
+	return d.V4.Dynamic() || d.V6.Dynamic()
+}
+
+// Ensure DualStackRuleConfig
+func (d DualStackRuleConfig) Ensure(enabled bool) error {
+	_, err := d.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged DualStackRuleConfig. Both families are always attempted and
+// their errors are joined.
+func (d DualStackRuleConfig) EnsureChanged(enabled bool) (bool, error) {
+	v4Changed, v4Err := d.V4.EnsureChanged(enabled)
+	if v4Err != nil {
+		v4Err = fmt.Errorf("IPv4 rule %v: %w", d.V4.Rule, v4Err)
+	}
+	v6Changed, v6Err := d.V6.EnsureChanged(enabled)
+	if v6Err != nil {
+		v6Err = fmt.Errorf("IPv6 rule %v: %w", d.V6.Rule, v6Err)
+	}
+	return v4Changed || v6Changed, errors.Join(v4Err, v6Err)
+}
+
+// resolveConflicts applies ConflictPolicy to the foreign rules at the rule's
+// priority and reports whether any was deleted.
+func (r IPRuleConfig) resolveConflicts() (bool, error) {
+	if r.ConflictPolicy == ConflictCoexist || r.Rule.Priority < 0 {
+		return false, nil
+	}
+	rules, err := r.listRules()
+	if err != nil {
+		return false, err
+	}
+	var foreign []netlink.Rule
+	installed := false
+	for _, rule := range rules {
+		switch {
+		case ruleMatches(r.Rule, rule):
+			installed = true
+		case rule.Priority == r.Rule.Priority:
+			foreign = append(foreign, rule)
+		}
+	}
+	if len(foreign) == 0 {
+		return false, nil
+	}
+	if r.ConflictPolicy == ConflictDefer {
+		if installed {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: %s is taken by %s", ErrRuleConflict, describeRule(r.Rule), describeRule(foreign[0]))
+	}
+	changed := false
+	for i := range foreign {
+		if err := r.RuleDel(&foreign[i]); err != nil {
+			metrics.RecordNetlinkError("rule_del", err)
+			return changed, fmt.Errorf("failed to delete foreign ip rule %s: %w", describeRule(foreign[i]), err)
+		}
+		diffLogf("deleted foreign ip rule %s", describeRule(foreign[i]))
+		changed = true
+	}
+	return changed, nil
+}
@@ -0,0 +1,452 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakeRuleList is an in-memory rule table. Like the kernel, it reports rules
+// with fresh pointer fields and assigns a priority to rules added without one.
+type fakeRuleList struct {
+	rules []netlink.Rule
+}
+
+func (f *fakeRuleList) add(rule *netlink.Rule) error {
+	r := *rule
+	if r.Priority < 0 {
+		r.Priority = 32000
+	}
+	f.rules = append(f.rules, r)
+	return nil
+}
+
+func (f *fakeRuleList) del(rule *netlink.Rule) error {
+	for i, r := range f.rules {
+		if ruleMatches(*rule, r) {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeRuleList) list(_ int) ([]netlink.Rule, error) {
+	var rules []netlink.Rule
+	for _, r := range f.rules {
+		if r.Dport != nil {
+			r.Dport = netlink.NewRulePortRange(r.Dport.Start, r.Dport.End)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (f *fakeRuleList) config(c IPRuleConfig) IPRuleConfig {
+	c.RuleAdd, c.RuleDel, c.RuleList = f.add, f.del, f.list
+	return c
+}
+
+func TestDscpRuleConfig(t *testing.T) {
+	plain := newRuleConfig(100)
+	fake := &fakeRuleList{rules: []netlink.Rule{plain.Rule}}
+
+	c := fake.config(NewDscpRuleConfig(46, 100))
+	if c.Rule.Tos != 0xb8 {
+		t.Fatalf("NewDscpRuleConfig(46) Tos = %#x, want 0xb8", c.Rule.Tos)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fake.rules) != 2 {
+		t.Fatalf("the DSCP rule should be added once next to the plain rule, got %v", fake.rules)
+	}
+
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.rules) != 1 || fake.rules[0].Tos != 0 {
+		t.Errorf("Ensure(false) should only remove the DSCP rule, got %v", fake.rules)
+	}
+}
+
+func TestMarkDscpRuleConfig(t *testing.T) {
+	c := NewMarkDscpRuleConfig(0x10000, 0xff0000, 46, 100)
+	if c.Rule.Mark != 0x10000 || c.Rule.Mask != 0xff0000 || c.Rule.Tos != 0xb8 {
+		t.Fatalf("NewMarkDscpRuleConfig should set both the fwmark and DSCP, got %v", c.Rule)
+	}
+
+	// Rules sharing the mark but not the DSCP, or the DSCP but not the mark,
+	// are other rules.
+	otherDscp := NewMarkDscpRuleConfig(0x10000, 0xff0000, 10, 100)
+	otherMark := NewMarkDscpRuleConfig(0x20000, 0xff0000, 46, 100)
+	fake := &fakeRuleList{rules: []netlink.Rule{otherDscp.Rule, otherMark.Rule}}
+	c = fake.config(c)
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fake.rules) != 3 {
+		t.Fatalf("the rule should be added once next to the other two, got %v", fake.rules)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.rules) != 2 || fake.rules[0].Tos != otherDscp.Rule.Tos || fake.rules[1].Mark != otherMark.Rule.Mark {
+		t.Errorf("Ensure(false) should only remove its own rule, got %v", fake.rules)
+	}
+}
+
+func TestIPProtoRuleConfig(t *testing.T) {
+	plain := newRuleConfig(100)
+	fake := &fakeRuleList{rules: []netlink.Rule{plain.Rule}}
+
+	c := fake.config(NewIPProtoRuleConfig(unix.IPPROTO_TCP, 100))
+	if c.Rule.IPProto != unix.IPPROTO_TCP {
+		t.Fatalf("NewIPProtoRuleConfig IPProto = %d, want %d", c.Rule.IPProto, unix.IPPROTO_TCP)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(fake.rules) != 2 {
+		t.Fatalf("the ipproto rule should be added once next to the plain rule, got %v", fake.rules)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(fake.rules) != 1 || fake.rules[0].IPProto != 0 {
+		t.Errorf("Ensure(false) should only remove the ipproto rule, got %v", fake.rules)
+	}
+}
+
+func TestIPRuleConfigPortRangeCount(t *testing.T) {
+	fake := &fakeRuleList{}
+	c := fake.config(ExcludeDNSIPRuleConfigs[0].(IPRuleConfig))
+	c.Ensure(true)
+	c.Ensure(true)
+	if count, _ := c.count(); count != 1 || len(fake.rules) != 1 {
+		t.Errorf("a rule with a port range should be counted by value, got %v", fake.rules)
+	}
+}
+
+func TestPickTable(t *testing.T) {
+	tables := []int{100, 101, 102, 103}
+	counts := make(map[int]int)
+	assigned := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := net.IPv4(10, 0, byte(i/256), byte(i%256)).String()
+		table, err := PickTable(key, tables)
+		if err != nil {
+			t.Fatalf("PickTable returned error: %v", err)
+		}
+		if again, _ := PickTable(key, []int{103, 102, 101, 100}); again != table {
+			t.Fatalf("PickTable(%s) depends on table order: %d vs %d", key, table, again)
+		}
+		counts[table]++
+		assigned[key] = table
+	}
+	for _, table := range tables {
+		if counts[table] < 150 {
+			t.Errorf("table %d got %d of 1000 keys, distribution is too skewed: %v", table, counts[table], counts)
+		}
+	}
+
+	// Removing a table must only move the keys that were on it.
+	for key, table := range assigned {
+		got, _ := PickTable(key, []int{100, 101, 102})
+		if table != 103 && got != table {
+			t.Errorf("key %s moved from %d to %d after removing table 103", key, table, got)
+		}
+	}
+
+	if _, err := PickTable("10.0.0.1", nil); err == nil {
+		t.Error("PickTable should fail without tables")
+	}
+}
+
+func TestNewHashedSourceRuleConfig(t *testing.T) {
+	ip := net.ParseIP("10.4.0.7")
+	c, err := NewHashedSourceRuleConfig(ip, []int{100, 101})
+	if err != nil {
+		t.Fatalf("NewHashedSourceRuleConfig returned error: %v", err)
+	}
+	want, _ := PickTable(ip.String(), []int{100, 101})
+	if c.Rule.Table != want {
+		t.Errorf("rule table = %d, want %d", c.Rule.Table, want)
+	}
+	if ones, bits := c.Rule.Src.Mask.Size(); !c.Rule.Src.IP.Equal(ip) || ones != 32 || bits != 32 {
+		t.Errorf("rule src = %v, want %v/32", c.Rule.Src, ip)
+	}
+}
+
+func TestRuleVerifyAfterApply(t *testing.T) {
+	fake := &fakeRuleList{}
+	c := fake.config(NewDscpRuleConfig(10, 100))
+	c.VerifyAfterApply = true
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+
+	fake.rules = nil
+	c.RuleAdd = func(rule *netlink.Rule) error { return nil }
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should fail when the added rule is not listed afterwards")
+	}
+}
+
+func TestDualStackRuleConfig(t *testing.T) {
+	v4Rules, v6Rules := &fakeRuleList{}, &fakeRuleList{}
+	listFamily := func(f *fakeRuleList, want int) ruleLister {
+		return func(family int) ([]netlink.Rule, error) {
+			if family != want {
+				t.Errorf("rules listed in family %d, want %d", family, want)
+			}
+			return f.list(family)
+		}
+	}
+	v4 := v4Rules.config(newRuleConfig(100))
+	v4.RuleList = listFamily(v4Rules, netlink.FAMILY_V4)
+	v6 := v6Rules.config(newRuleConfig(100))
+	v6.RuleList = listFamily(v6Rules, netlink.FAMILY_V6)
+	c := NewDualStackRuleConfig(v4, v6)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	if len(v4Rules.rules) != 1 || len(v6Rules.rules) != 1 {
+		t.Fatalf("each family should have one rule, got v4 %v v6 %v", v4Rules.rules, v6Rules.rules)
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	if len(v4Rules.rules) != 0 || len(v6Rules.rules) != 0 {
+		t.Fatalf("Ensure(false) should remove both rules, got v4 %v v6 %v", v4Rules.rules, v6Rules.rules)
+	}
+
+	// A failure in one family does not stop the other.
+	c.V6.RuleAdd = func(*netlink.Rule) error { return syscall.EAFNOSUPPORT }
+	if err := c.Ensure(true); err == nil {
+		t.Error("Ensure(true) should report the IPv6 failure")
+	}
+	if len(v4Rules.rules) != 1 {
+		t.Errorf("the IPv4 rule should be installed despite the IPv6 failure, got %v", v4Rules.rules)
+	}
+}
+
+func TestIPRuleConfigTableRouteList(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	routes := &fakeRouteTable{}
+	rules := &fakeRuleList{}
+	c := rules.config(newRuleConfig(300))
+	c.Rule.Priority = 31000
+	c.TableRouteList = routes.list
+
+	if changed, err := c.EnsureChanged(true); err != nil || changed || len(rules.rules) != 0 {
+		t.Fatalf("EnsureChanged(true) with an empty table = %v, %v with rules %v; want no rule", changed, err, rules.rules)
+	}
+
+	routes.routes = append(routes.routes, netlink.Route{Dst: dst, LinkIndex: 2, Table: 300})
+	if changed, err := c.EnsureChanged(true); err != nil || !changed || len(rules.rules) != 1 {
+		t.Fatalf("EnsureChanged(true) once the table has a route = %v, %v with rules %v; want the rule added", changed, err, rules.rules)
+	}
+
+	// A route in another table does not count.
+	routes.routes[0].Table = 301
+	if changed, err := c.EnsureChanged(true); err != nil || !changed || len(rules.rules) != 0 {
+		t.Errorf("EnsureChanged(true) after the table emptied = %v, %v with rules %v; want the rule removed", changed, err, rules.rules)
+	}
+}
+
+// TestLargeTableIDs checks against the kernel that rules and routes for a
+// table above 255, which netlink sends in the 32-bit table attribute rather
+// than the legacy 8-bit field, point at that table and are found again.
+func TestLargeTableIDs(t *testing.T) {
+	defer enterScratchNetns(t)()
+	const table = 1000
+
+	rule := newRuleConfig(table)
+	rule.Rule.Priority = 1000
+	_, src, _ := net.ParseCIDR("10.0.0.0/8")
+	rule.Rule.Src = src
+	_, dst, _ := net.ParseCIDR("192.168.0.0/16")
+	route := IPRouteConfig{
+		Route:     netlink.Route{Dst: dst, Table: table, Type: unix.RTN_BLACKHOLE},
+		RouteAdd:  netlink.RouteAdd,
+		RouteDel:  netlink.RouteDel,
+		RouteList: netlink.RouteListFiltered,
+	}
+	for _, c := range []ChangeReporter{rule, route} {
+		for i := 0; i < 2; i++ {
+			if changed, err := c.EnsureChanged(true); err != nil || changed != (i == 0) {
+				t.Fatalf("%T EnsureChanged(true) #%d = %v, %v", c, i, changed, err)
+			}
+		}
+	}
+
+	rules, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		t.Fatalf("RuleList returned error: %v", err)
+	}
+	var found bool
+	for _, r := range rules {
+		if r.Priority == 1000 {
+			found = true
+			if r.Table != table {
+				t.Errorf("rule points at table %d, want %d", r.Table, table)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("rule at priority 1000 not found in %v", rules)
+	}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+	if err != nil || len(routes) != 1 || !ipNetEqual(routes[0].Dst, dst) {
+		t.Errorf("routes in table %d = %v, %v; want the blackhole to %v", table, routes, err, dst)
+	}
+
+	for _, c := range []ChangeReporter{rule, route} {
+		if changed, err := c.EnsureChanged(false); err != nil || !changed {
+			t.Errorf("%T EnsureChanged(false) = %v, %v", c, changed, err)
+		}
+	}
+}
+
+func TestRuleTableChecked(t *testing.T) {
+	rules := &fakeRuleList{}
+	for _, table := range []int{256, 1000, 0} {
+		c := rules.config(newRuleConfig(table))
+		c.Rule.SuppressPrefixlen = 0
+		if err := c.Ensure(true); err == nil {
+			t.Errorf("suppress_prefixlen with table %d should fail", table)
+		}
+	}
+	c := rules.config(newRuleConfig(-1))
+	if err := c.Ensure(true); err == nil {
+		t.Error("a negative table should fail")
+	}
+	c = rules.config(newRuleConfig(254))
+	c.Rule.SuppressPrefixlen = 0
+	if err := c.Ensure(true); err != nil {
+		t.Errorf("suppress_prefixlen with the main table returned error: %v", err)
+	}
+	if len(rules.rules) != 1 {
+		t.Errorf("got rules %v, want only the main table rule", rules.rules)
+	}
+}
+
+func TestRuleConflictPolicy(t *testing.T) {
+	foreign := newRuleConfig(50).Rule
+	foreign.Priority = 30000
+	other := newRuleConfig(60).Rule
+	other.Priority = 30001
+
+	for _, tc := range []struct {
+		desc    string
+		policy  ConflictPolicy
+		wantErr bool
+		// want lists the tables of the rules left, in order.
+		want []int
+	}{
+		{"coexist", ConflictCoexist, false, []int{50, 60, 100}},
+		{"overwrite", ConflictOverwrite, false, []int{60, 100}},
+		{"defer", ConflictDefer, true, []int{50, 60}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			fake := &fakeRuleList{rules: []netlink.Rule{foreign, other}}
+			c := fake.config(newRuleConfig(100))
+			c.Rule.Priority = 30000
+			c.ConflictPolicy = tc.policy
+
+			changed, err := c.EnsureChanged(true)
+			if tc.wantErr {
+				if !errors.Is(err, ErrRuleConflict) || !errors.Is(err, ErrDeferred) || changed {
+					t.Errorf("EnsureChanged(true) = %v, %v; want false, ErrRuleConflict", changed, err)
+				}
+			} else if err != nil || !changed {
+				t.Errorf("EnsureChanged(true) = %v, %v; want true, nil", changed, err)
+			}
+			var tables []int
+			for _, r := range fake.rules {
+				tables = append(tables, r.Table)
+			}
+			if !reflect.DeepEqual(tables, tc.want) {
+				t.Errorf("rules left in tables %v, want %v", tables, tc.want)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if changed, err := c.EnsureChanged(true); err != nil || changed {
+				t.Errorf("a second EnsureChanged(true) = %v, %v; want false, nil", changed, err)
+			}
+			// Removing netd's rule never touches the foreign ones.
+			if err := c.Ensure(false); err != nil {
+				t.Fatalf("Ensure(false) returned error: %v", err)
+			}
+			if len(fake.rules) != len(tc.want)-1 {
+				t.Errorf("Ensure(false) left %v", fake.rules)
+			}
+		})
+	}
+
+	// An overwriting rule would delete the rules its own Set declares at its
+	// priority, so the Set is rejected.
+	fake := &fakeRuleList{rules: []netlink.Rule{foreign}}
+	c := fake.config(newRuleConfig(100))
+	c.Rule.Priority = 30000
+	c.ConflictPolicy = ConflictOverwrite
+	sibling := fake.config(newRuleConfig(50))
+	sibling.Rule.Priority = 30000
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{c, NamedConfig{Config: sibling, Name: "sibling"}}}
+	if err := s.Ensure(); err == nil {
+		t.Error("a Set with an overwriting rule sharing its priority should fail")
+	}
+	if len(fake.rules) != 1 {
+		t.Errorf("the rejected Set should not change the rules, got %v", fake.rules)
+	}
+	s.Configs = []Config{c}
+	if err := s.Ensure(); err != nil {
+		t.Errorf("a Set with a lone overwriting rule returned error: %v", err)
+	}
+
+	// A deferred rule that is already installed is left alone.
+	fake = &fakeRuleList{}
+	c = fake.config(newRuleConfig(100))
+	c.Rule.Priority = 30000
+	c.ConflictPolicy = ConflictDefer
+	if err := c.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) without a foreign rule returned error: %v", err)
+	}
+	fake.rules = append(fake.rules, foreign)
+	if changed, err := c.EnsureChanged(true); err != nil || changed {
+		t.Errorf("EnsureChanged(true) with the rule installed = %v, %v; want false, nil", changed, err)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// selfTestTable and selfTestPriority are not used by any netd feature.
+	selfTestTable    = 5252
+	selfTestPriority = 32700
+	selfTestChain    = "NETD-SELFTEST"
+)
+
+// selfTestSrc is in TEST-NET-1, so the self-test rule never matches traffic.
+var selfTestSrc = &net.IPNet{IP: net.IPv4(192, 0, 2, 254), Mask: net.CIDRMask(32, 32)}
+
+// SelfTest applies a throwaway policy rule and an unreferenced iptables
+// chain, checks both took effect, then removes them. It fails when netd lacks
+// the capabilities or tools to manage its configs, without touching any state
+// a feature relies on.
+func SelfTest(ctx context.Context) error {
+	if ipt == nil {
+		return errors.New("self-test: iptables is unavailable")
+	}
+	rule := newRuleConfig(selfTestTable)
+	rule.Rule.Priority = selfTestPriority
+	rule.Rule.Src = selfTestSrc
+	chain := IPTablesRuleConfig{
+		Spec: IPTablesChainSpec{TableName: tableFilter, ChainName: selfTestChain, IPT: ipt},
+		RuleSpecs: []IPTablesRuleSpec{
+			{"-s", selfTestSrc.String(), "-j", "RETURN", "-m", "comment", "--comment", "netd self-test"},
+		},
+		IPT: ipt,
+	}
+	return selfTest(ctx, []Config{rule, chain})
+}
+
+// selfTest ensures each config, checks it is reported present, then removes
+// it and checks it is gone. Configs are always removed, even on failure.
+func selfTest(ctx context.Context, configs []Config) error {
+	for _, c := range configs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := selfTestOne(c); err != nil {
+			glog.Errorf("self-test failed: %v", err)
+			return err
+		}
+	}
+	glog.Infof("self-test passed")
+	return nil
+}
+
+func selfTestOne(c Config) (err error) {
+	defer func() {
+		if rerr := c.Ensure(false); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("self-test: failed to revert %v: %w", c, rerr))
+			return
+		}
+		if err != nil {
+			return
+		}
+		if state, serr := configState(c); serr != nil || state.Present {
+			err = fmt.Errorf("self-test: %v is still present after revert (%v)", c, serr)
+		}
+	}()
+
+	if err := c.Ensure(true); err != nil {
+		return fmt.Errorf("self-test: failed to apply %v: %w", c, err)
+	}
+	state, err := configState(c)
+	if err != nil {
+		return fmt.Errorf("self-test: failed to query %v: %w", c, err)
+	}
+	if !state.Present {
+		return fmt.Errorf("self-test: %v is missing after it was applied", c)
+	}
+	return nil
+}
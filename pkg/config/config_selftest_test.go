@@ -0,0 +1,67 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestSelfTest(t *testing.T) {
+	rules := &fakeRuleList{}
+	rule := rules.config(newRuleConfig(selfTestTable))
+	rule.Rule.Priority = selfTestPriority
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	chain := IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableFilter, ChainName: selfTestChain, IPT: fakeIPT},
+		RuleSpecs: []IPTablesRuleSpec{{"-j", "RETURN"}},
+		IPT:       fakeIPT,
+	}
+
+	if err := selfTest(context.Background(), []Config{rule, chain}); err != nil {
+		t.Fatalf("selfTest returned error: %v", err)
+	}
+	if len(rules.rules) != 0 || len(fakeIPT.iptCache) != 0 {
+		t.Errorf("selfTest should leave nothing behind, got rules %v chains %v", rules.rules, fakeIPT.iptCache)
+	}
+
+	// Without CAP_NET_ADMIN the rule cannot be added.
+	denied := rule
+	denied.RuleAdd = func(*netlink.Rule) error { return syscall.EPERM }
+	if err := selfTest(context.Background(), []Config{denied, chain}); err == nil {
+		t.Error("selfTest should fail when the rule cannot be added")
+	}
+	if len(fakeIPT.iptCache) != 0 {
+		t.Error("selfTest should stop at the first failure")
+	}
+
+	// An add that silently does nothing is caught by the presence check.
+	silent := rule
+	silent.RuleAdd = func(*netlink.Rule) error { return nil }
+	if err := selfTest(context.Background(), []Config{silent}); err == nil {
+		t.Error("selfTest should fail when the rule is missing after it was applied")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := selfTest(ctx, []Config{rule}); err == nil {
+		t.Error("selfTest should stop when ctx is cancelled")
+	}
+}
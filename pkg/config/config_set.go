@@ -0,0 +1,163 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/golang/glog"
+)
+
+// Ensure applies every config of the Set in OrderedConfigs order when it is
+// enabled, and removes them in reverse order when it is not. All configs are
+// attempted and their errors are joined. A Set whose Gate is unknown is left
+// untouched. OnReconcileComplete is called unless the Set was skipped. With a
+// Tracker, configs unchanged since they were last applied are not ensured,
+// except DynamicConfigs.
+// With a Recorder, the outcome is recorded for Status.
+func (s Set) Ensure() error {
+	return s.EnsureWithBudget(nil)
+}
+
+// EnsureWithBudget is Ensure, retrying failed configs for as long as budget
+// allows. The budget is shared by every config, so a node where everything
+// fails gives up quickly overall. A nil budget never retries.
+func (s Set) EnsureWithBudget(budget *RetryBudget) error {
+	var specHash uint64
+	if s.Recorder != nil {
+		specHash = s.SpecHash()
+	}
+	s, ok := s.Resolve()
+	if !ok {
+		return nil
+	}
+	summary := ReconcileSummary{FeatureName: s.FeatureName, Enabled: s.Enabled, Total: len(s.Configs)}
+	defer func() {
+		if s.OnReconcileComplete != nil {
+			s.OnReconcileComplete(summary)
+		}
+	}()
+	configs, err := s.OrderedConfigs()
+	if err == nil && s.Enabled {
+		err = checkConflictPolicies(s.Configs)
+	}
+	if err != nil {
+		summary.Failed = summary.Total
+		s.Recorder.record(s.FeatureName, specHash, err)
+		return err
+	}
+	var errs []error
+	applied := make(map[uint64]bool)
+	for i := range configs {
+		c := configs[i]
+		if !s.Enabled {
+			c = configs[len(configs)-1-i]
+		}
+		var h uint64
+		if s.Tracker != nil && !isDynamic(c) {
+			h = configHash(c, s.Enabled)
+			if s.Tracker.unchanged(s.FeatureName, h) {
+				applied[h] = true
+				summary.Unchanged++
+				continue
+			}
+		}
+		if s.Window.scheduled(c, s.Enabled) {
+			glog.Infof("%v: %s %v", s.FeatureName, ErrScheduled, reflect.ValueOf(c))
+			errs = append(errs, fmt.Errorf("%w: %v", ErrScheduled, reflect.ValueOf(c)))
+			summary.Deferred++
+			continue
+		}
+		changed, err := EnsureChanged(c, s.Enabled)
+		for err != nil && budget.take() {
+			glog.Warningf("retrying %v for %v after: %v", reflect.ValueOf(c), s.FeatureName, err)
+			changed, err = EnsureChanged(c, s.Enabled)
+		}
+		if err == nil && h != 0 {
+			applied[h] = true
+		}
+		switch {
+		case errors.Is(err, ErrDeferred):
+			glog.Infof("%v: %v", s.FeatureName, err)
+			errs = append(errs, err)
+			summary.Deferred++
+		case err != nil:
+			glog.Errorf("found an error for %v: %v when ensuring %v", s.FeatureName, err, reflect.ValueOf(c))
+			errs = append(errs, err)
+			summary.Failed++
+		case changed:
+			summary.Changed++
+			if s.OnChange != nil {
+				s.OnChange(ChangeEvent{FeatureName: s.FeatureName, Config: c, Enabled: s.Enabled})
+			}
+		default:
+			summary.Unchanged++
+		}
+	}
+	if s.Tracker != nil {
+		s.Tracker.record(s.FeatureName, applied)
+	}
+	err = errors.Join(errs...)
+	s.Recorder.record(s.FeatureName, specHash, err)
+	return err
+}
+
+// ApplyAll tears down the disabled sets in reverse declaration order, then
+// applies the enabled sets in declaration order, so a set may depend on the
+// chains, rules or routes of the sets before it. Errors are aggregated.
+func ApplyAll(sets []Set) error {
+	return applyAll(sets, false)
+}
+
+// ApplyAllFailFast is like ApplyAll but stops at the first set that fails.
+func ApplyAllFailFast(sets []Set) error {
+	return applyAll(sets, true)
+}
+
+func applyAll(sets []Set, failFast bool) error {
+	var resolved []Set
+	for _, s := range sets {
+		if s, ok := s.Resolve(); ok {
+			s.Gate = nil
+			resolved = append(resolved, s)
+		}
+	}
+	sets = resolved
+
+	var errs []error
+	ensure := func(s Set) bool {
+		if err := s.Ensure(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.FeatureName, err))
+			return !failFast
+		}
+		return true
+	}
+
+	for i := len(sets) - 1; i >= 0; i-- {
+		if !sets[i].Enabled && !ensure(sets[i]) {
+			return errors.Join(errs...)
+		}
+	}
+	for _, s := range sets {
+		if s.Enabled && !ensure(s) {
+			return errors.Join(errs...)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingConfig appends "<name>:<enabled>" to log on every Ensure.
+type recordingConfig struct {
+	name string
+	log  *[]string
+	err  error
+}
+
+func (c recordingConfig) Ensure(enabled bool) error {
+	*c.log = append(*c.log, fmt.Sprintf("%s:%v", c.name, enabled))
+	return c.err
+}
+
+func TestSetEnsureOrder(t *testing.T) {
+	var log []string
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs:     []Config{recordingConfig{"a", &log, nil}, recordingConfig{"b", &log, nil}},
+	}
+	s.Ensure()
+	s.Enabled = false
+	s.Ensure()
+	want := []string{"a:true", "b:true", "b:false", "a:false"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("Set.Ensure order = %v, want %v", log, want)
+	}
+}
+
+func TestApplyAll(t *testing.T) {
+	var log []string
+	set := func(name string, enabled bool, err error) Set {
+		return Set{Enabled: enabled, FeatureName: name, Configs: []Config{recordingConfig{name, &log, err}}}
+	}
+
+	sets := []Set{set("base", true, nil), set("old", false, nil), set("masquerade", true, nil),
+		set("stale", false, nil), set("policy-routing", true, nil)}
+	if err := ApplyAll(sets); err != nil {
+		t.Fatalf("ApplyAll returned error: %v", err)
+	}
+	want := []string{"stale:false", "old:false", "base:true", "masquerade:true", "policy-routing:true"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ApplyAll order = %v, want %v", log, want)
+	}
+
+	errBoom := errors.New("boom")
+	log = nil
+	sets = []Set{set("base", true, errBoom), set("masquerade", true, nil)}
+	if err := ApplyAll(sets); !errors.Is(err, errBoom) {
+		t.Errorf("ApplyAll should return the aggregated error, got %v", err)
+	}
+	if len(log) != 2 {
+		t.Errorf("ApplyAll should continue past failures, got %v", log)
+	}
+
+	log = nil
+	if err := ApplyAllFailFast(sets); !errors.Is(err, errBoom) {
+		t.Errorf("ApplyAllFailFast should return the error, got %v", err)
+	}
+	if len(log) != 1 {
+		t.Errorf("ApplyAllFailFast should stop at the first failure, got %v", log)
+	}
+}
+
+func TestSetOnReconcileComplete(t *testing.T) {
+	var log []string
+	var summaries []ReconcileSummary
+	mSysctl := map[string]string{"net.ipv4.ip_forward": "1"}
+	sysctl := SysctlConfig{
+		Key:          "net.ipv4.ip_forward",
+		Value:        "1",
+		DefaultValue: "0",
+		SysctlFunc: func(name string, params ...string) (string, error) {
+			if len(params) == 0 {
+				return mSysctl[name], nil
+			}
+			mSysctl[name] = params[0]
+			return "", nil
+		},
+	}
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs: []Config{
+			sysctl,
+			recordingConfig{"a", &log, nil},
+			recordingConfig{"b", &log, errors.New("boom")},
+			recordingConfig{"c", &log, nil},
+		},
+		OnReconcileComplete: func(summary ReconcileSummary) { summaries = append(summaries, summary) },
+	}
+	if err := s.Ensure(); err == nil {
+		t.Error("Ensure() should return the failed config's error")
+	}
+	s.Enabled = false
+	s.Configs = s.Configs[:2]
+	if err := s.Ensure(); err != nil {
+		t.Errorf("Ensure() returned error: %v", err)
+	}
+
+	want := []ReconcileSummary{
+		{FeatureName: "test", Enabled: true, Total: 4, Changed: 2, Unchanged: 1, Failed: 1},
+		{FeatureName: "test", Enabled: false, Total: 2, Changed: 2, Unchanged: 0, Failed: 0},
+	}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Errorf("summaries = %+v, want %+v", summaries, want)
+	}
+}
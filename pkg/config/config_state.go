@@ -0,0 +1,164 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// ConfigState is the live state of one Config as queried from the kernel.
+type ConfigState struct {
+	Config Config
+	// Known is false for configs whose state cannot be queried, or whose
+	// query failed.
+	Known bool
+	// Present reports whether the config is installed. For a sysctl it
+	// reports whether the current value is the enabled value.
+	Present bool
+	// Value is the current value of a sysctl.
+	Value string
+}
+
+// StateSnapshot is what a Set has actually installed at one point in time.
+type StateSnapshot struct {
+	FeatureName string
+	Enabled     bool
+	Configs     []ConfigState
+}
+
+// CurrentState queries the presence of each config of the Set without
+// changing anything. Configs that fail to be queried are reported as unknown
+// and their errors are joined.
+func (s Set) CurrentState() (StateSnapshot, error) {
+	snapshot := StateSnapshot{FeatureName: s.FeatureName, Enabled: s.Enabled}
+	var errs []error
+	for _, c := range s.Configs {
+		state, err := configState(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", c, err))
+			state = ConfigState{Config: c}
+		}
+		snapshot.Configs = append(snapshot.Configs, state)
+	}
+	return snapshot, errors.Join(errs...)
+}
+
+// Divergent returns the known configs of the snapshot whose presence differs
+// from the Enabled state of their Set.
+func (s StateSnapshot) Divergent() []ConfigState {
+	var divergent []ConfigState
+	for _, state := range s.Configs {
+		if state.Known && state.Present != s.Enabled {
+			divergent = append(divergent, state)
+		}
+	}
+	return divergent
+}
+
+// CheckDivergence reports whether the live state matches the desired state of
+// the Set, logging every config that differs, without changing anything. It
+// is the check-mode complement of Ensure. A Set whose Gate is unknown would be
+// left untouched, so it matches. Configs whose state cannot be queried are
+// not counted, but their errors are returned.
+func CheckDivergence(s Set) (bool, error) {
+	s, ok := s.Resolve()
+	if !ok {
+		return true, nil
+	}
+	snapshot, err := s.CurrentState()
+	divergent := snapshot.Divergent()
+	for _, state := range divergent {
+		glog.Warningf("%s: %s is %s", s.FeatureName, DescribeTarget(state.Config), describePresence(state.Present))
+	}
+	return len(divergent) == 0, err
+}
+
+func describePresence(present bool) string {
+	if present {
+		return "present but should be removed"
+	}
+	return "missing"
+}
+
+func configState(c Config) (ConfigState, error) {
+	state := ConfigState{Config: c, Known: true}
+	var err error
+	switch c := c.(type) {
+	case NamedConfig:
+		return configState(c.Config)
+	case SysctlConfig:
+		state.Value, err = c.SysctlFunc(c.Key)
+		state.Value = strings.TrimSpace(state.Value)
+		state.Present = state.Value == c.Value
+	case IPRuleConfig:
+		var n int
+		n, err = c.count()
+		state.Present = n > 0
+	case IPRouteConfig:
+		if c.RouteList == nil {
+			return ConfigState{Config: c}, nil
+		}
+		state.Present, _, err = c.lookup()
+	case IPTablesRuleConfig:
+		state.Present, err = c.present()
+	default:
+		return ConfigState{Config: c}, nil
+	}
+	return state, err
+}
+
+// present reports whether the chain and all of its rules are installed.
+func (r IPTablesRuleConfig) present() (bool, error) {
+	exists, err := r.IPT.ChainExists(r.Spec.TableName, r.Spec.ChainName)
+	if err != nil || !exists {
+		return false, err
+	}
+	for _, rs := range r.RuleSpecs {
+		exists, err := r.IPT.Exists(r.Spec.TableName, r.Spec.ChainName, rs...)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// EnsureAndVerify ensures the Set, then re-reads the live state and fails
+// listing every config that is not in the desired state, for features where
+// a silently partial application is unacceptable. Configs whose state cannot
+// be queried are not verified.
+func EnsureAndVerify(s Set) error {
+	if err := s.Ensure(); err != nil {
+		return err
+	}
+	s, ok := s.Resolve()
+	if !ok {
+		return nil
+	}
+	snapshot, err := s.CurrentState()
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", s.FeatureName, err)
+	}
+	var errs []error
+	for _, state := range snapshot.Divergent() {
+		errs = append(errs, fmt.Errorf("%s did not take: %s is %s", s.FeatureName, DescribeTarget(state.Config), describePresence(state.Present)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestSetCurrentState(t *testing.T) {
+	rules := &fakeRuleList{}
+	installedRule := rules.config(newRuleConfig(100))
+	rules.add(&installedRule.Rule)
+	missingRule := rules.config(NewDscpRuleConfig(46, 100))
+
+	routes := &fakeRouteTable{}
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	route := routes.config(netlink.Route{Dst: dst, LinkIndex: 2, Table: 100})
+
+	ipt := FakeIPTable{iptCache: map[string][]string{"NETD-TEST": {"-j ACCEPT"}}}
+	iptRule := IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: "filter", ChainName: "NETD-TEST", IPT: ipt},
+		RuleSpecs: []IPTablesRuleSpec{{"-j", "ACCEPT"}, {"-j", "DROP"}},
+		IPT:       ipt,
+	}
+
+	sysctl := SysctlConfig{
+		Key:   "net.ipv4.ip_forward",
+		Value: "1",
+		SysctlFunc: func(string, ...string) (string, error) {
+			return "1\n", nil
+		},
+	}
+	brokenSysctl := SysctlConfig{
+		Key: "net.ipv4.broken",
+		SysctlFunc: func(string, ...string) (string, error) {
+			return "", errors.New("permission denied")
+		},
+	}
+
+	s := Set{
+		Enabled:     true,
+		FeatureName: "test",
+		Configs:     []Config{sysctl, brokenSysctl, installedRule, missingRule, route, iptRule},
+	}
+	snapshot, err := s.CurrentState()
+	if err == nil {
+		t.Error("CurrentState should report the failed sysctl read")
+	}
+	if snapshot.FeatureName != "test" || !snapshot.Enabled || len(snapshot.Configs) != 6 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	want := []struct{ known, present bool }{
+		{true, true},
+		{false, false},
+		{true, true},
+		{true, false},
+		{true, false},
+		{true, false},
+	}
+	for i, w := range want {
+		got := snapshot.Configs[i]
+		if got.Known != w.known || got.Present != w.present {
+			t.Errorf("config %d: known=%v present=%v, want known=%v present=%v", i, got.Known, got.Present, w.known, w.present)
+		}
+	}
+	if v := snapshot.Configs[0].Value; v != "1" {
+		t.Errorf("sysctl value = %q, want 1", v)
+	}
+	if len(rules.rules) != 1 || len(routes.routes) != 0 || len(ipt.iptCache["NETD-TEST"]) != 1 {
+		t.Error("CurrentState must not modify anything")
+	}
+
+	route.Ensure(true)
+	iptRule.Ensure(true)
+	snapshot, _ = s.CurrentState()
+	if !snapshot.Configs[4].Present || !snapshot.Configs[5].Present {
+		t.Errorf("route and iptables rule should be present after Ensure, got %+v", snapshot.Configs[4:])
+	}
+}
+
+func TestCheckDivergence(t *testing.T) {
+	rules := &fakeRuleList{}
+	rule := rules.config(newRuleConfig(100))
+	routes := &fakeRouteTable{}
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	route := routes.config(netlink.Route{Dst: dst, LinkIndex: 2, Table: 100})
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{rule, route}}
+
+	if match, err := CheckDivergence(s); err != nil || match {
+		t.Errorf("CheckDivergence() before applying = %v, %v; want a divergence", match, err)
+	}
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure() returned error: %v", err)
+	}
+	if match, err := CheckDivergence(s); err != nil || !match {
+		t.Errorf("CheckDivergence() after applying = %v, %v; want a match", match, err)
+	}
+
+	// The route was removed behind netd's back.
+	routes.routes = nil
+	if match, err := CheckDivergence(s); err != nil || match {
+		t.Errorf("CheckDivergence() with a missing route = %v, %v; want a divergence", match, err)
+	}
+	if len(rules.rules) != 1 || len(routes.routes) != 0 {
+		t.Errorf("CheckDivergence changed the live state: rules %v, routes %v", rules.rules, routes.routes)
+	}
+
+	// A disabled Set matches once its configs are gone.
+	s.Enabled = false
+	if match, _ := CheckDivergence(s); match {
+		t.Error("CheckDivergence() of a disabled Set with its rule installed should diverge")
+	}
+	rules.rules = nil
+	if match, err := CheckDivergence(s); err != nil || !match {
+		t.Errorf("CheckDivergence() of a removed disabled Set = %v, %v; want a match", match, err)
+	}
+}
+
+// forgetfulRouteTable drops the next route added to it, as if it had been
+// removed right after being applied.
+type forgetfulRouteTable struct {
+	*fakeRouteTable
+	forget bool
+}
+
+func (f *forgetfulRouteTable) add(route *netlink.Route) error {
+	if f.forget {
+		f.forget = false
+		return nil
+	}
+	return f.fakeRouteTable.add(route)
+}
+
+func TestEnsureAndVerify(t *testing.T) {
+	rules := &fakeRuleList{}
+	rule := rules.config(newRuleConfig(100))
+	routes := &forgetfulRouteTable{fakeRouteTable: &fakeRouteTable{}, forget: true}
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	route := routes.config(netlink.Route{Dst: dst, LinkIndex: 2, Table: 100})
+	route.RouteAdd = routes.add
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{rule, route}}
+
+	err := EnsureAndVerify(s)
+	if err == nil || !strings.Contains(err.Error(), "ip route 10.1.0.0/24") || strings.Contains(err.Error(), "ip rule") {
+		t.Errorf("EnsureAndVerify() = %v, want an error naming only the forgotten route", err)
+	}
+	if err := EnsureAndVerify(s); err != nil {
+		t.Errorf("EnsureAndVerify() once the route stays = %v, want nil", err)
+	}
+
+	s.Enabled = false
+	if err := EnsureAndVerify(s); err != nil {
+		t.Errorf("EnsureAndVerify() of the disabled Set = %v, want nil", err)
+	}
+	if len(rules.rules) != 0 || len(routes.routes) != 0 {
+		t.Errorf("disabled Set left rules %v and routes %v", rules.rules, routes.routes)
+	}
+}
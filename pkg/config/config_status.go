@@ -0,0 +1,84 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// SetStatus is the observed state of a Set, for a parent controller to tell
+// whether netd has caught up with the spec it asked for.
+type SetStatus struct {
+	// SpecHash is the SpecHash of the Set last applied successfully.
+	SpecHash uint64
+	// LastApplied is when that Ensure completed, zero if none did.
+	LastApplied time.Time
+	// LastError is the error of the latest Ensure, nil if it succeeded.
+	LastError error
+}
+
+// StatusRecorder keeps the SetStatus of every Set using it, by FeatureName.
+type StatusRecorder struct {
+	mu     sync.Mutex
+	status map[string]SetStatus
+	now    func() time.Time
+}
+
+// NewStatusRecorder creates an empty StatusRecorder.
+func NewStatusRecorder() *StatusRecorder {
+	return &StatusRecorder{status: make(map[string]SetStatus), now: time.Now}
+}
+
+func (r *StatusRecorder) record(featureName string, specHash uint64, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status[featureName]
+	status.LastError = err
+	if err == nil {
+		status.SpecHash = specHash
+		status.LastApplied = r.now()
+	}
+	r.status[featureName] = status
+}
+
+// Status returns the SetStatus recorded by the Set's Recorder, or the zero
+// SetStatus without one.
+func (s Set) Status() SetStatus {
+	if s.Recorder == nil {
+		return SetStatus{}
+	}
+	s.Recorder.mu.Lock()
+	defer s.Recorder.mu.Unlock()
+	return s.Recorder.status[s.FeatureName]
+}
+
+// SpecHash hashes the desired state of the Set: Enabled and every config in
+// declaration order. It equals Status().SpecHash once that state is applied.
+func (s Set) SpecHash() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s %v %d ", s.FeatureName, s.Enabled, len(s.Configs))
+	for _, c := range s.Configs {
+		fmt.Fprintf(h, "%x ", configHash(c, s.Enabled))
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetStatus(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder := NewStatusRecorder()
+	recorder.now = func() time.Time { return now }
+
+	var fail error
+	ensure := func(string, bool) error { return fail }
+	s := Set{Enabled: true, FeatureName: "test", Configs: []Config{funcConfig{"route", ensure}}, Recorder: recorder}
+	if status := s.Status(); status != (SetStatus{}) {
+		t.Errorf("Status before any Ensure = %+v, want zero", status)
+	}
+
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+	applied := now
+	want := SetStatus{SpecHash: s.SpecHash(), LastApplied: applied}
+	if status := s.Status(); status != want {
+		t.Errorf("Status after Ensure = %+v, want %+v", status, want)
+	}
+
+	// A new spec that fails to apply keeps reporting the previous one.
+	previous := s.SpecHash()
+	s.Configs = append(s.Configs, funcConfig{"rule", ensure})
+	if s.SpecHash() == previous {
+		t.Fatal("SpecHash should change with the configs")
+	}
+	now = now.Add(time.Minute)
+	fail = errors.New("permission denied")
+	s.Ensure()
+	status := s.Status()
+	if status.SpecHash != previous || !status.LastApplied.Equal(applied) || !errors.Is(status.LastError, fail) {
+		t.Errorf("Status after a failed Ensure = %+v, want the previous spec with the error", status)
+	}
+
+	now = now.Add(time.Minute)
+	fail = nil
+	if err := s.Ensure(); err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+	want = SetStatus{SpecHash: s.SpecHash(), LastApplied: now}
+	if status := s.Status(); status != want {
+		t.Errorf("Status after catching up = %+v, want %+v", status, want)
+	}
+
+	s.Enabled = false
+	if s.SpecHash() == want.SpecHash {
+		t.Error("SpecHash should change with Enabled")
+	}
+	if status := (Set{FeatureName: "test"}).Status(); status != (SetStatus{}) {
+		t.Errorf("Status without a Recorder = %+v, want zero", status)
+	}
+}
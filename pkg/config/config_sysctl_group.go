@@ -0,0 +1,75 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SysctlGroupConfig writes Sysctls in their declared order, e.g.
+// net.ipv4.ip_forward before the sysctls that only take effect with
+// forwarding on, and resets them to their defaults in reverse order. On
+// enable, a failed write stops the group, so a sysctl is never set without
+// those declared before it.
+type SysctlGroupConfig struct {
+	Sysctls []SysctlConfig
+}
+
+// NewSysctlGroupConfig creates a SysctlGroupConfig writing sysctls in the
+// given order. Each key may appear only once.
+func NewSysctlGroupConfig(sysctls ...SysctlConfig) (SysctlGroupConfig, error) {
+	if len(sysctls) == 0 {
+		return SysctlGroupConfig{}, fmt.Errorf("a sysctl group needs at least one sysctl")
+	}
+	keys := make(map[string]bool)
+	for _, s := range sysctls {
+		if keys[s.Key] {
+			return SysctlGroupConfig{}, fmt.Errorf("sysctl %s appears twice in the group", s.Key)
+		}
+		keys[s.Key] = true
+	}
+	return SysctlGroupConfig{Sysctls: sysctls}, nil
+}
+
+// Ensure SysctlGroupConfig
+func (g SysctlGroupConfig) Ensure(enabled bool) error {
+	_, err := g.EnsureChanged(enabled)
+	return err
+}
+
+// EnsureChanged SysctlGroupConfig. Every sysctl is attempted on disable.
+func (g SysctlGroupConfig) EnsureChanged(enabled bool) (bool, error) {
+	var changed bool
+	if !enabled {
+		var errs []error
+		for i := len(g.Sysctls) - 1; i >= 0; i-- {
+			sysctlChanged, err := g.Sysctls[i].EnsureChanged(false)
+			changed = changed || sysctlChanged
+			errs = append(errs, err)
+		}
+		return changed, errors.Join(errs...)
+	}
+	for _, s := range g.Sysctls {
+		sysctlChanged, err := s.EnsureChanged(true)
+		if err != nil {
+			return changed, fmt.Errorf("failed to set sysctl %s: %w", s.Key, err)
+		}
+		changed = changed || sysctlChanged
+	}
+	return changed, nil
+}
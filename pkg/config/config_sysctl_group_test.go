@@ -0,0 +1,82 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSysctlGroupConfigOrder(t *testing.T) {
+	const forwarding, rpFilter, localnet = "net.ipv4.ip_forward", "net.ipv4.conf.eth0.rp_filter", "net.ipv4.conf.eth0.route_localnet"
+	sysctls := fakeSysctls{forwarding: "0", rpFilter: "1", localnet: "0"}
+	var writes []string
+	sysctlFunc := func(name string, params ...string) (string, error) {
+		if len(params) > 0 {
+			writes = append(writes, name+"="+params[0])
+		}
+		return sysctls.sysctl(name, params...)
+	}
+	g, err := NewSysctlGroupConfig(
+		SysctlConfig{Key: forwarding, Value: "1", DefaultValue: "0", SysctlFunc: sysctlFunc},
+		SysctlConfig{Key: rpFilter, Value: "2", DefaultValue: "1", SysctlFunc: sysctlFunc},
+		SysctlConfig{Key: localnet, Value: "1", DefaultValue: "0", SysctlFunc: sysctlFunc},
+	)
+	if err != nil {
+		t.Fatalf("NewSysctlGroupConfig returned error: %v", err)
+	}
+
+	if changed, err := g.EnsureChanged(true); err != nil || !changed {
+		t.Fatalf("EnsureChanged(true) = %v, %v; want true, nil", changed, err)
+	}
+	want := []string{forwarding + "=1", rpFilter + "=2", localnet + "=1"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("enable wrote %v, want %v", writes, want)
+	}
+	if changed, err := g.EnsureChanged(true); err != nil || changed {
+		t.Errorf("a second EnsureChanged(true) = %v, %v; want false, nil", changed, err)
+	}
+
+	writes = nil
+	if err := g.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	want = []string{localnet + "=0", rpFilter + "=1", forwarding + "=0"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("disable wrote %v, want %v", writes, want)
+	}
+
+	// A failed write leaves the sysctls depending on it alone.
+	writes = nil
+	g.Sysctls[0].SysctlFunc = func(name string, params ...string) (string, error) {
+		if len(params) > 0 {
+			return "", errors.New("read-only file system")
+		}
+		return "0", nil
+	}
+	if err := g.Ensure(true); err == nil {
+		t.Error("Ensure(true) should fail when the first sysctl cannot be written")
+	}
+	if len(writes) != 0 {
+		t.Errorf("no sysctl after the failed one should be written, got %v", writes)
+	}
+
+	if _, err := NewSysctlGroupConfig(SysctlConfig{Key: forwarding}, SysctlConfig{Key: forwarding}); err == nil {
+		t.Error("a group with a duplicate key should be rejected")
+	}
+}
@@ -14,6 +14,7 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"syscall"
@@ -30,6 +31,9 @@ func TestSysctlConfigEnsure(t *testing.T) {
 		Value:        "2",
 		DefaultValue: "1",
 		SysctlFunc: func(name string, params ...string) (string, error) {
+			if len(params) == 0 {
+				return mSysctl[name], nil
+			}
 			mSysctl[name] = params[0]
 			return "", nil
 		},
@@ -119,6 +123,38 @@ func (i FakeIPTable) DeleteChain(_, chain string) error {
 	return nil
 }
 
+func (i FakeIPTable) ChainExists(_, chain string) (bool, error) {
+	_, ok := i.iptCache[chain]
+	return ok, nil
+}
+
+func (i FakeIPTable) Exists(_, chain string, rulespec ...string) (bool, error) {
+	rule := strings.Join(rulespec, " ")
+	for _, r := range i.iptCache[chain] {
+		if r == rule {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (i FakeIPTable) Insert(_, chain string, pos int, rulespec ...string) error {
+	rules := i.iptCache[chain]
+	if pos > len(rules)+1 {
+		return fmt.Errorf("index of insertion too big")
+	}
+	rules = append(rules, "")
+	copy(rules[pos:], rules[pos-1:])
+	rules[pos-1] = strings.Join(rulespec, " ")
+	i.iptCache[chain] = rules
+	return nil
+}
+
+func (i FakeIPTable) Append(_, chain string, rulespec ...string) error {
+	i.iptCache[chain] = append(i.iptCache[chain], strings.Join(rulespec, " "))
+	return nil
+}
+
 func (i FakeIPTable) AppendUnique(_, chain string, rulespec ...string) error {
 	rule := strings.Join(rulespec, " ")
 	for _, r := range i.iptCache[chain] {
@@ -140,6 +176,14 @@ func (i FakeIPTable) Delete(_, chain string, rulespec ...string) error {
 	return nil
 }
 
+func (i FakeIPTable) List(_, chain string) ([]string, error) {
+	rules := []string{"-N " + chain}
+	for _, rule := range i.iptCache[chain] {
+		rules = append(rules, "-A "+chain+" "+rule)
+	}
+	return rules, nil
+}
+
 func TestFakeIPTable(t *testing.T) {
 	fakeIPT := FakeIPTable{
 		iptCache: make(map[string][]string),
@@ -170,43 +214,43 @@ func TestIPTablesRuleConfig(t *testing.T) {
 		iptCache: make(map[string][]string),
 	}
 	iptableRule1 := IPTablesRuleConfig{
-		IPTablesChainSpec{
+		Spec: IPTablesChainSpec{
 			TableName:      "mangle",
 			ChainName:      "postRoutingChain",
 			IsDefaultChain: true,
 			IPT:            fakeIPT,
 		},
-		[]IPTablesRuleSpec{
-			[]string{"rule1", "-m", "-j"},
-			[]string{"rule2", "-m", "-j"},
+		RuleSpecs: []IPTablesRuleSpec{
+			[]string{"rule1", "-m", "-j", "-m", "comment", "--comment", "netd: test"},
+			[]string{"rule2", "-m", "-j", "-m", "comment", "--comment", "netd: test"},
 		},
-		fakeIPT,
+		IPT: fakeIPT,
 	}
 	iptableRule2 := IPTablesRuleConfig{
-		IPTablesChainSpec{
+		Spec: IPTablesChainSpec{
 			TableName:      "mangle",
 			ChainName:      "postRoutingChain",
 			IsDefaultChain: true,
 			IPT:            fakeIPT,
 		},
-		[]IPTablesRuleSpec{
-			[]string{"rule1", "-m", "-j"},
-			[]string{"rule3", "-m", "-j"},
+		RuleSpecs: []IPTablesRuleSpec{
+			[]string{"rule1", "-m", "-j", "-m", "comment", "--comment", "netd: test"},
+			[]string{"rule3", "-m", "-j", "-m", "comment", "--comment", "netd: test"},
 		},
-		fakeIPT,
+		IPT: fakeIPT,
 	}
 	iptableRule3 := IPTablesRuleConfig{
-		IPTablesChainSpec{
+		Spec: IPTablesChainSpec{
 			TableName:      "mangle",
 			ChainName:      "gcpPostRoutingChain",
 			IsDefaultChain: false,
 			IPT:            fakeIPT,
 		},
-		[]IPTablesRuleSpec{
-			[]string{"rule1", "-m", "-j"},
-			[]string{"rule2", "-m", "-j"},
+		RuleSpecs: []IPTablesRuleSpec{
+			[]string{"rule1", "-m", "-j", "-m", "comment", "--comment", "netd: test"},
+			[]string{"rule2", "-m", "-j", "-m", "comment", "--comment", "netd: test"},
 		},
-		fakeIPT,
+		IPT: fakeIPT,
 	}
 	iptableRule1.Ensure(true)
 	iptableRule2.Ensure(true)
@@ -234,3 +278,331 @@ func TestIPTablesRuleConfig(t *testing.T) {
 		t.Error("Ensure should keep 0 rule for iptableRule1.")
 	}
 }
+
+func TestIPTablesRuleConfigRawTable(t *testing.T) {
+	fakeIPT := FakeIPTable{
+		iptCache: make(map[string][]string),
+	}
+	notrackRule := IPTablesRuleConfig{
+		Spec: IPTablesChainSpec{
+			TableName:      tableRaw,
+			ChainName:      "GCP-NOTRACK",
+			IsDefaultChain: false,
+			IPT:            fakeIPT,
+		},
+		RuleSpecs: []IPTablesRuleSpec{
+			[]string{"-d", "10.0.0.0/8", "-j", "NOTRACK"},
+		},
+		IPT: fakeIPT,
+	}
+	jumpRule := IPTablesRuleConfig{
+		Spec: IPTablesChainSpec{
+			TableName:      tableRaw,
+			ChainName:      preRoutingChain,
+			IsDefaultChain: true,
+			IPT:            fakeIPT,
+		},
+		RuleSpecs: []IPTablesRuleSpec{
+			[]string{"-j", "GCP-NOTRACK", "-m", "comment", "--comment", "netd: test"},
+		},
+		IPT: fakeIPT,
+	}
+	for i := 0; i < 2; i++ {
+		if err := notrackRule.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) on raw table returned error: %v", err)
+		}
+		if err := jumpRule.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) on raw table returned error: %v", err)
+		}
+	}
+	if len(fakeIPT.iptCache["GCP-NOTRACK"]) != 1 || len(fakeIPT.iptCache[preRoutingChain]) != 1 {
+		t.Errorf("raw table rules should be added once, got %v", fakeIPT.iptCache)
+	}
+
+	jumpRule.Ensure(false)
+	notrackRule.Ensure(false)
+	if _, ok := fakeIPT.iptCache["GCP-NOTRACK"]; ok {
+		t.Error("Ensure(false) should delete the GCP-NOTRACK chain in the raw table.")
+	}
+	if len(fakeIPT.iptCache[preRoutingChain]) != 0 {
+		t.Error("Ensure(false) should remove the jump rule from the raw PREROUTING chain.")
+	}
+}
+
+func TestIPTablesChainSpecTables(t *testing.T) {
+	for _, table := range []string{tableFilter, tableNAT, tableMangle, tableRaw, tableSecurity} {
+		fakeIPT := FakeIPTable{
+			iptCache: make(map[string][]string),
+		}
+		spec := IPTablesChainSpec{TableName: table, ChainName: "GCP-TEST", IPT: fakeIPT}
+		if _, err := spec.ensure(true); err != nil {
+			t.Errorf("ensure(true) for table %s returned error: %v", table, err)
+		}
+		if _, ok := fakeIPT.iptCache["GCP-TEST"]; !ok {
+			t.Errorf("ensure(true) should create chain in table %s", table)
+		}
+		if _, err := spec.ensure(false); err != nil {
+			t.Errorf("ensure(false) for table %s returned error: %v", table, err)
+		}
+		if _, ok := fakeIPT.iptCache["GCP-TEST"]; ok {
+			t.Errorf("ensure(false) should delete chain in table %s", table)
+		}
+	}
+
+	spec := IPTablesChainSpec{TableName: "bogus", ChainName: "GCP-TEST", IPT: FakeIPTable{iptCache: make(map[string][]string)}}
+	if _, err := spec.ensure(true); err == nil {
+		t.Error("ensure(true) should reject an unknown table")
+	}
+}
+
+func TestIPRuleConfigStalePriorities(t *testing.T) {
+	rule := func(priority int) netlink.Rule {
+		return netlink.Rule{Priority: priority, Table: 100, SuppressIfgroup: -1, SuppressPrefixlen: -1, Mark: -1, Mask: -1, Goto: -1}
+	}
+	ruleList := []netlink.Rule{rule(100), rule(300), rule(200), rule(400)}
+
+	ipRule := IPRuleConfig{
+		Rule:    rule(300),
+		RuleAdd: func(rule *netlink.Rule) error { ruleList = append(ruleList, *rule); return nil },
+		// Like the kernel, delete the first rule matching the priority.
+		RuleDel: func(rule *netlink.Rule) error {
+			for i, r := range ruleList {
+				if r.Priority == rule.Priority {
+					ruleList = append(ruleList[:i], ruleList[i+1:]...)
+					return nil
+				}
+			}
+			return nil
+		},
+		RuleList:        func(family int) ([]netlink.Rule, error) { return append([]netlink.Rule(nil), ruleList...), nil },
+		StalePriorities: []int{100, 200},
+	}
+	if err := ipRule.Ensure(true); err != nil {
+		t.Fatalf("Ensure(true) returned error: %v", err)
+	}
+	if len(ruleList) != 2 || ruleList[0].Priority != 300 || ruleList[1].Priority != 400 {
+		t.Errorf("only the rules at priorities 300 and 400 should remain, got %v", ruleList)
+	}
+}
+
+func TestIPRuleConfigMigratePriority(t *testing.T) {
+	rule := func(priority int) netlink.Rule {
+		return netlink.Rule{Priority: priority, Table: 100, SuppressIfgroup: -1, SuppressPrefixlen: -1, Mark: -1, Mask: -1, Goto: -1}
+	}
+	ruleList := []netlink.Rule{rule(100)}
+	present := func() bool {
+		for _, r := range ruleList {
+			if r.Priority == 100 || r.Priority == 200 {
+				return true
+			}
+		}
+		return false
+	}
+
+	ipRule := IPRuleConfig{
+		Rule: rule(100),
+		RuleAdd: func(rule *netlink.Rule) error {
+			ruleList = append(ruleList, *rule)
+			return nil
+		},
+		RuleDel: func(rule *netlink.Rule) error {
+			for i, r := range ruleList {
+				if r == *rule {
+					ruleList = append(ruleList[:i], ruleList[i+1:]...)
+					break
+				}
+			}
+			if !present() {
+				t.Errorf("rule was absent after deleting %v", *rule)
+			}
+			return nil
+		},
+		RuleList: func(family int) ([]netlink.Rule, error) { return append([]netlink.Rule(nil), ruleList...), nil },
+	}
+	if err := ipRule.MigratePriority(100, 200); err != nil {
+		t.Fatalf("MigratePriority returned error: %v", err)
+	}
+	if len(ruleList) != 1 || ruleList[0].Priority != 200 {
+		t.Errorf("only the rule at priority 200 should remain, got %v", ruleList)
+	}
+}
+
+func TestIPRuleConfigListRetry(t *testing.T) {
+	calls := 0
+	ipRule := IPRuleConfig{
+		Rule: netlink.Rule{SuppressIfgroup: -1, SuppressPrefixlen: -1, Mark: -1, Mask: -1, Goto: -1},
+		RuleList: func(family int) ([]netlink.Rule, error) {
+			calls++
+			if calls == 1 {
+				return nil, syscall.EINTR
+			}
+			return []netlink.Rule{{SuppressIfgroup: -1, SuppressPrefixlen: -1, Mark: -1, Mask: -1, Goto: -1}}, nil
+		},
+	}
+	if count, err := ipRule.count(); err != nil || count != 1 {
+		t.Errorf("count() should retry an interrupted dump, got count %d err %v", count, err)
+	}
+
+	calls = 0
+	ipRule.RuleList = func(family int) ([]netlink.Rule, error) {
+		calls++
+		return nil, syscall.EINTR
+	}
+	if _, err := ipRule.count(); err == nil || calls != ruleListAttempts {
+		t.Errorf("count() should give up after %d attempts, got %d calls, err %v", ruleListAttempts, calls, err)
+	}
+}
+
+func TestEnsureChanged(t *testing.T) {
+	mSysctl := map[string]string{"net.ipv4.ip_forward": "0"}
+	fakeIPT := FakeIPTable{iptCache: make(map[string][]string)}
+	routes := &fakeRouteTable{}
+	rules := &fakeRuleList{}
+
+	configs := map[string]Config{
+		"sysctl": SysctlConfig{
+			Key: "net.ipv4.ip_forward", Value: "1", DefaultValue: "0",
+			SysctlFunc: func(name string, params ...string) (string, error) {
+				if len(params) == 0 {
+					return mSysctl[name] + "\n", nil
+				}
+				mSysctl[name] = params[0]
+				return "", nil
+			},
+		},
+		"route": routes.config(netlink.Route{Table: customRouteTable, LinkIndex: 2}),
+		"rule":  rules.config(newRuleConfig(customRouteTable)),
+		"iptables": IPTablesRuleConfig{
+			Spec:      IPTablesChainSpec{TableName: tableMangle, ChainName: gcpPostRoutingChain, IPT: fakeIPT},
+			RuleSpecs: []IPTablesRuleSpec{{"-j", "MARK", "--set-mark", "0x4000"}},
+			IPT:       fakeIPT,
+		},
+	}
+	for name, c := range configs {
+		for _, enabled := range []bool{true, false} {
+			changed, err := EnsureChanged(c, enabled)
+			if err != nil || !changed {
+				t.Errorf("%s: first EnsureChanged(%v) = %v, %v; want a change", name, enabled, changed, err)
+			}
+			changed, err = EnsureChanged(c, enabled)
+			if err != nil || changed {
+				t.Errorf("%s: second EnsureChanged(%v) = %v, %v; want no change", name, enabled, changed, err)
+			}
+		}
+	}
+}
+
+func TestIPTablesRuleConfigDefaultChainOwnership(t *testing.T) {
+	unmarked := IPTablesRuleSpec{"-p", "tcp", "--dport", "22", "-j", "ACCEPT"}
+	foreign := IPTablesRuleSpec{"-p", "tcp", "--dport", "443", "-j", "ACCEPT", "-m", "comment", "--comment", "kube-proxy"}
+	marked := IPTablesRuleSpec{"-p", "tcp", "--dport", "80", "-j", "ACCEPT"}.WithComment("http")
+	legacy := IPTablesRuleSpec{"-j", gcpPreRoutingChain, "-m", "comment", "--comment", policyRoutingPreRoutingComment}
+	fakeIPT := FakeIPTable{
+		iptCache: map[string][]string{
+			"INPUT": {strings.Join(unmarked, " "), strings.Join(foreign, " "), strings.Join(marked, " "), strings.Join(legacy, " ")},
+		},
+	}
+	c := IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableFilter, ChainName: "INPUT", IsDefaultChain: true, IPT: fakeIPT},
+		RuleSpecs: []IPTablesRuleSpec{unmarked, foreign, marked, legacy},
+		IPT:       fakeIPT,
+	}
+	if err := c.Ensure(false); err != nil {
+		t.Fatalf("Ensure(false) returned error: %v", err)
+	}
+	want := []string{strings.Join(unmarked, " "), strings.Join(foreign, " ")}
+	if got := fakeIPT.iptCache["INPUT"]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("only the netd-marked rules should be deleted, got %q, want %q", got, want)
+	}
+}
+
+func TestIPTablesRuleConfigOrdered(t *testing.T) {
+	fakeIPT := FakeIPTable{iptCache: map[string][]string{
+		// A previous version declared ACCEPT before DROP.
+		"GCP-ORDERED": {"-s 10.0.0.0/8 -j ACCEPT", "-s 10.0.0.1 -j DROP"},
+	}}
+	declared := []IPTablesRuleSpec{
+		{"-s", "10.0.0.1", "-j", "DROP"},
+		{"-s", "10.0.0.0/8", "-j", "ACCEPT"},
+		{"-j", "RETURN"},
+	}
+	c := IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableFilter, ChainName: "GCP-ORDERED", IPT: fakeIPT},
+		RuleSpecs: declared,
+		IPT:       fakeIPT,
+		Ordered:   true,
+	}
+	for i := 0; i < 2; i++ {
+		changed, err := c.EnsureChanged(true)
+		if err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+		if changed != (i == 0) {
+			t.Errorf("EnsureChanged(true) #%d = %v, want a change only the first time", i, changed)
+		}
+	}
+	want := []string{"-s 10.0.0.1 -j DROP", "-s 10.0.0.0/8 -j ACCEPT", "-j RETURN"}
+	if got := fakeIPT.iptCache["GCP-ORDERED"]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("chain contents = %q, want %q", got, want)
+	}
+
+	// In a default chain, only netd's rules are moved behind the others, and
+	// the new copies are added before the old ones are deleted.
+	fakeIPT.iptCache["INPUT"] = []string{"-j netd-b -m comment --comment netd: b", "-j system", "-j netd-a -m comment --comment netd: a"}
+	var ops []string
+	ipt := opRecordingIPTable{fakeIPT, &ops}
+	c = IPTablesRuleConfig{
+		Spec:      IPTablesChainSpec{TableName: tableFilter, ChainName: "INPUT", IsDefaultChain: true, IPT: ipt},
+		RuleSpecs: []IPTablesRuleSpec{IPTablesRuleSpec{"-j", "netd-a"}.WithComment("a"), IPTablesRuleSpec{"-j", "netd-b"}.WithComment("b")},
+		IPT:       ipt,
+		Ordered:   true,
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Ensure(true); err != nil {
+			t.Fatalf("Ensure(true) returned error: %v", err)
+		}
+	}
+	want = []string{"-j system", "-j netd-a -m comment --comment netd: a", "-j netd-b -m comment --comment netd: b"}
+	if got := fakeIPT.iptCache["INPUT"]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("default chain contents = %q, want %q", got, want)
+	}
+	wantOps := []string{"append netd-a", "append netd-b", "delete netd-a", "delete netd-b"}
+	if strings.Join(ops, "\n") != strings.Join(wantOps, "\n") {
+		t.Errorf("default chain rewritten with %q, want %q", ops, wantOps)
+	}
+}
+
+// opRecordingIPTable records the Append and Delete calls made on a
+// FakeIPTable as "<op> <target>".
+type opRecordingIPTable struct {
+	FakeIPTable
+	ops *[]string
+}
+
+func (i opRecordingIPTable) Append(table, chain string, rulespec ...string) error {
+	*i.ops = append(*i.ops, "append "+rulespec[1])
+	return i.FakeIPTable.Append(table, chain, rulespec...)
+}
+
+func (i opRecordingIPTable) Delete(table, chain string, rulespec ...string) error {
+	*i.ops = append(*i.ops, "delete "+rulespec[1])
+	return i.FakeIPTable.Delete(table, chain, rulespec...)
+}
+
+func TestCanonicalRuleSpec(t *testing.T) {
+	spec := IPTablesRuleSpec{"-p", "tcp", "--source", "10.0.0.1", "--dport", "80", "-j", "ACCEPT"}.WithComment("web")
+	listed := splitRuleSpec(`-A INPUT -s 10.0.0.1/32 -p tcp -m tcp --dport 80 -m comment --comment "netd: web" -j ACCEPT`)
+	if got, want := canonicalRuleSpec(spec), canonicalRuleSpec(listed[2:]); got != want {
+		t.Errorf("canonicalRuleSpec(%v) = %q, want it to match the listing %q", spec, got, want)
+	}
+
+	for _, pair := range [][2]IPTablesRuleSpec{
+		{{"-s", "10.0.0.1", "-d", "10.0.0.2"}, {"-s", "10.0.0.2", "-d", "10.0.0.1"}},
+		{{"!", "-s", "10.0.0.0/8", "-j", "DROP"}, {"-s", "10.0.0.0/8", "-j", "DROP"}},
+		{{"-p", "tcp", "-m", "udp"}, {"-p", "tcp"}},
+	} {
+		if canonicalRuleSpec(pair[0]) == canonicalRuleSpec(pair[1]) {
+			t.Errorf("canonicalRuleSpec should tell %v and %v apart", pair[0], pair[1])
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/config"
+)
+
+// AuditEntry records one change netd made to the kernel state.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Feature    string    `json:"feature"`
+	ConfigType string    `json:"configType"`
+	// Action is "add" when the config was applied and "del" when removed.
+	Action string `json:"action"`
+	Target string `json:"target"`
+}
+
+// AuditLog keeps the most recent AuditEntries in a fixed-size ring buffer and
+// optionally appends every entry to a file as a JSON line. It serves the
+// buffered entries, oldest first, as JSON over HTTP.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	next    int
+	full    bool
+	file    io.WriteCloser
+	now     func() time.Time
+}
+
+// NewAuditLog creates an AuditLog holding up to size entries. When path is
+// not empty, entries are also appended to that file.
+func NewAuditLog(size int, path string) (*AuditLog, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("audit log size must be positive, got %d", size)
+	}
+	a := &AuditLog{entries: make([]AuditEntry, size), now: time.Now}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		a.file = f
+	}
+	return a, nil
+}
+
+// Record adds the change described by event to the log.
+func (a *AuditLog) Record(event config.ChangeEvent) {
+	action := "del"
+	if event.Enabled {
+		action = "add"
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry := AuditEntry{
+		Time:       a.now(),
+		Feature:    event.FeatureName,
+		ConfigType: fmt.Sprintf("%T", event.Config),
+		Action:     action,
+		Target:     config.DescribeTarget(event.Config),
+	}
+	a.entries[a.next] = entry
+	a.next = (a.next + 1) % len(a.entries)
+	a.full = a.full || a.next == 0
+	if a.file != nil {
+		line, _ := json.Marshal(entry)
+		if _, err := a.file.Write(append(line, '\n')); err != nil {
+			glog.Errorf("failed to write audit log entry: %v", err)
+		}
+	}
+}
+
+// Entries returns the buffered entries, oldest first.
+func (a *AuditLog) Entries() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.full {
+		return append([]AuditEntry(nil), a.entries[:a.next]...)
+	}
+	return append(append([]AuditEntry(nil), a.entries[a.next:]...), a.entries[:a.next]...)
+}
+
+// ServeHTTP writes the buffered entries as a JSON array.
+func (a *AuditLog) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Entries()); err != nil {
+		glog.Errorf("failed to write audit log: %v", err)
+	}
+}
+
+// Close closes the audit log file, if any.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/config"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := NewAuditLog(2, path)
+	if err != nil {
+		t.Fatalf("NewAuditLog returned error: %v", err)
+	}
+	defer log.Close()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	log.now = func() time.Time { return now }
+
+	mSysctl := map[string]string{"net.ipv4.ip_forward": "0"}
+	sysctl := config.SysctlConfig{
+		Key:          "net.ipv4.ip_forward",
+		Value:        "1",
+		DefaultValue: "0",
+		SysctlFunc: func(name string, params ...string) (string, error) {
+			if len(params) == 0 {
+				return mSysctl[name], nil
+			}
+			mSysctl[name] = params[0]
+			return "", nil
+		},
+	}
+	n := newTestController(sysctl)
+	n.SetAuditLog(log)
+
+	n.reconcile()
+	n.reconcile() // no change, nothing recorded
+	n.configSet[0].Enabled = false
+	n.reconcile()
+	n.configSet[0].Enabled = true
+	n.reconcile()
+
+	entry := func(action string) AuditEntry {
+		return AuditEntry{Time: now, Feature: "Test", ConfigType: "config.SysctlConfig", Action: action, Target: "sysctl net.ipv4.ip_forward"}
+	}
+	// The first add was evicted from the two-entry buffer.
+	want := []AuditEntry{entry("del"), entry("add")}
+	if got := log.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Entries() = %+v, want %+v", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	log.ServeHTTP(rec, httptest.NewRequest("GET", "/audit", nil))
+	var served []AuditEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("failed to decode %q: %v", rec.Body.String(), err)
+	}
+	if !reflect.DeepEqual(served, want) {
+		t.Errorf("served %+v, want %+v", served, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the audit file: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 {
+		t.Errorf("the audit file should keep every entry, got %q", lines)
+	}
+}
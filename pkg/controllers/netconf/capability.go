@@ -0,0 +1,58 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProcSelfStatus is the status file of the running process.
+const ProcSelfStatus = "/proc/self/status"
+
+// HasNetAdmin reports whether CAP_NET_ADMIN is in the effective capability
+// set listed in statusPath, normally ProcSelfStatus. Without it every change
+// to routes, rules, iptables or sysctls fails.
+func HasNetAdmin(statusPath string) (bool, error) {
+	f, err := os.Open(statusPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid CapEff in %s: %w", statusPath, err)
+		}
+		return caps&(1<<unix.CAP_NET_ADMIN) != 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return false, fmt.Errorf("no CapEff in %s", statusPath)
+}
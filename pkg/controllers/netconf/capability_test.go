@@ -0,0 +1,54 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasNetAdmin(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name, capEff string
+		want         bool
+	}{
+		{"root", "000001ffffffffff", true},
+		{"net-admin-only", "0000000000001000", true},
+		{"unprivileged", "0000000000000000", false},
+		{"net-raw-only", "0000000000002000", false},
+	} {
+		path := filepath.Join(dir, tc.name)
+		status := "Name:\tnetd\nCapInh:\t0000000000000000\nCapEff:\t" + tc.capEff + "\nCapBnd:\t000001ffffffffff\n"
+		if err := os.WriteFile(path, []byte(status), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := HasNetAdmin(path)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: HasNetAdmin() = %v, %v; want %v, nil", tc.name, got, err, tc.want)
+		}
+	}
+
+	path := filepath.Join(dir, "no-caps")
+	if err := os.WriteFile(path, []byte("Name:\tnetd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := HasNetAdmin(path); err == nil {
+		t.Error("a status without CapEff should be an error")
+	}
+}
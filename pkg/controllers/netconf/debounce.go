@@ -0,0 +1,89 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// DebouncedReconciler coalesces bursts of triggers into a single run. A run
+// starts once no trigger has arrived for the window, and a trigger arriving
+// during a run causes another run afterwards, so the last trigger is always
+// followed by a run.
+type DebouncedReconciler struct {
+	window  time.Duration
+	run     func() error
+	trigger chan struct{}
+}
+
+// NewDebouncedReconciler creates a DebouncedReconciler calling run. Call Run
+// to start it; Trigger may be used before then.
+func NewDebouncedReconciler(window time.Duration, run func() error) *DebouncedReconciler {
+	return &DebouncedReconciler{
+		window:  window,
+		run:     run,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests a run. It never blocks, e.g. it can be passed to WatchNode
+// as onChange.
+func (d *DebouncedReconciler) Trigger() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes triggers until stopCh is closed.
+func (d *DebouncedReconciler) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-d.trigger:
+		}
+		if !d.wait(stopCh) {
+			return
+		}
+		if err := d.run(); err != nil {
+			glog.Errorf("debounced reconcile failed: %v", err)
+		}
+	}
+}
+
+// wait returns once the window has passed without a trigger, or false if
+// stopCh was closed first.
+func (d *DebouncedReconciler) wait(stopCh <-chan struct{}) bool {
+	timer := time.NewTimer(d.window)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return false
+		case <-d.trigger:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(d.window)
+		case <-timer.C:
+			return true
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebouncedReconciler(t *testing.T) {
+	const window = 50 * time.Millisecond
+	var runs atomic.Int32
+	ran := make(chan struct{}, 10)
+	d := NewDebouncedReconciler(window, func() error {
+		runs.Add(1)
+		ran <- struct{}{}
+		return nil
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go d.Run(stopCh)
+
+	waitRun := func(msg string) {
+		t.Helper()
+		select {
+		case <-ran:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected a run: %s", msg)
+		}
+	}
+
+	// A burst within the window is coalesced into one run.
+	for i := 0; i < 20; i++ {
+		d.Trigger()
+		time.Sleep(window / 10)
+	}
+	waitRun("after the first burst")
+	time.Sleep(3 * window)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("a burst should be coalesced into 1 run, got %d", n)
+	}
+
+	// Another burst after the window runs again.
+	for i := 0; i < 5; i++ {
+		d.Trigger()
+	}
+	waitRun("after the second burst")
+	time.Sleep(3 * window)
+	if n := runs.Load(); n != 2 {
+		t.Errorf("two separate bursts should cause 2 runs, got %d", n)
+	}
+}
+
+func TestDebouncedReconcilerTriggerDuringRun(t *testing.T) {
+	release := make(chan struct{})
+	ran := make(chan struct{}, 10)
+	first := true
+	d := NewDebouncedReconciler(10*time.Millisecond, func() error {
+		ran <- struct{}{}
+		if first {
+			first = false
+			<-release
+		}
+		return nil
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go d.Run(stopCh)
+
+	d.Trigger()
+	<-ran
+	// The first run is in progress; a trigger now must cause a second run.
+	d.Trigger()
+	close(release)
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("a trigger during a run should be followed by another run")
+	}
+}
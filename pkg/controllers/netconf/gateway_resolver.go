@@ -0,0 +1,53 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// apiLookupTimeout bounds each Service or Node lookup so a slow API server
+// cannot stall a reconcile.
+const apiLookupTimeout = 5 * time.Second
+
+// ServiceGatewayResolver returns a resolver for IPRouteConfig.GatewayResolver
+// reading the ClusterIP of the Service namespace/name on every call.
+func ServiceGatewayResolver(client kubernetes.Interface, namespace, name string) func() (net.IP, error) {
+	return func() (net.IP, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), apiLookupTimeout)
+		defer cancel()
+		svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == v1.ClusterIPNone {
+			return nil, fmt.Errorf("service %s/%s has no ClusterIP", namespace, name)
+		}
+		ip := net.ParseIP(svc.Spec.ClusterIP)
+		if ip == nil {
+			return nil, fmt.Errorf("service %s/%s has an invalid ClusterIP %q", namespace, name, svc.Spec.ClusterIP)
+		}
+		return ip, nil
+	}
+}
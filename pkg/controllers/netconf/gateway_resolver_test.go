@@ -0,0 +1,61 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServiceGatewayResolver(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "egress-gw"},
+		Spec:       v1.ServiceSpec{ClusterIP: "10.96.0.50"},
+	}
+	client := fake.NewSimpleClientset(svc)
+	resolve := ServiceGatewayResolver(client, "kube-system", "egress-gw")
+
+	gw, err := resolve()
+	if err != nil || !gw.Equal(net.ParseIP("10.96.0.50")) {
+		t.Fatalf("resolve() = %v, %v; want 10.96.0.50", gw, err)
+	}
+
+	svc = svc.DeepCopy()
+	svc.Spec.ClusterIP = "10.96.0.60"
+	if _, err := client.CoreV1().Services("kube-system").Update(context.Background(), svc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update service: %v", err)
+	}
+	if gw, err := resolve(); err != nil || !gw.Equal(net.ParseIP("10.96.0.60")) {
+		t.Errorf("resolve() after the update = %v, %v; want 10.96.0.60", gw, err)
+	}
+
+	svc.Spec.ClusterIP = v1.ClusterIPNone
+	if _, err := client.CoreV1().Services("kube-system").Update(context.Background(), svc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update service: %v", err)
+	}
+	if _, err := resolve(); err == nil {
+		t.Error("a headless service should not resolve")
+	}
+	if _, err := ServiceGatewayResolver(client, "kube-system", "missing")(); err == nil {
+		t.Error("a missing service should not resolve")
+	}
+}
@@ -17,8 +17,15 @@ limitations under the License.
 package netconf
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -30,10 +37,33 @@ import (
 type NetworkConfigController struct {
 	configSet         []*config.Set
 	reconcileInterval time.Duration
+	reconcileJitter   float64
+	rand              *rand.Rand
+	paused            atomic.Bool
+	readOnly          atomic.Bool
+
+	// mu guards the Enabled state of configSet and the fields below.
+	mu         sync.Mutex
+	stateStore *StateStore
+	configured map[string]bool
+	savedState map[string]FeatureState
+
+	failureThreshold    int
+	criticalFeatures    map[string]bool
+	failureHandler      func(featureName string, failures int)
+	consecutiveFailures map[string]int
+	auditLog            *AuditLog
+	tracker             *config.ChangeTracker
+	fullReconcileEvery  int
+	reconciles          int
+	generation          func() (uint64, error)
+	lastGeneration      uint64
+	netlinkBatch        bool
 }
 
 // NewNetworkConfigController creates a new NetworkConfigController
-func NewNetworkConfigController(enablePolicyRouting, enableSourceValidMark, excludeDNS bool, reconcileInterval time.Duration) *NetworkConfigController {
+func NewNetworkConfigController(enablePolicyRouting, enableSourceValidMark, excludeDNS bool, reconcileInterval time.Duration,
+	reconcileJitter float64) *NetworkConfigController {
 	var configSet []*config.Set
 
 	configSet = append(configSet, &config.PolicyRoutingConfigSet)
@@ -48,38 +78,387 @@ func NewNetworkConfigController(enablePolicyRouting, enableSourceValidMark, excl
 		configSet[0].Configs = append(configSet[0].Configs, config.ExcludeDNSIPRuleConfigs...)
 	}
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		glog.Errorf("failed to get hostname for reconcile jitter seed: %v", err)
+	}
+
 	return &NetworkConfigController{
 		configSet:         configSet,
 		reconcileInterval: reconcileInterval,
+		reconcileJitter:   reconcileJitter,
+		rand:              newJitterRand(hostname),
 	}
 }
 
+// newJitterRand returns a random source seeded from the node name, so a pod
+// restarted on the same node keeps the same reconcile offsets.
+func newJitterRand(nodeName string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(nodeName))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
 // Run runs the NetworkConfigController
 func (n *NetworkConfigController) Run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	n.printConfig()
+	n.checkConflicts()
 
 	for {
-		n.ensure()
+		n.reconcile()
 
 		select {
 		case <-stopCh:
 			return
-		case <-time.After(n.reconcileInterval):
+		case <-time.After(n.nextInterval()):
+			continue
+		}
+	}
+}
+
+// nextInterval returns the reconcile interval extended by a random fraction,
+// up to reconcileJitter, of itself.
+func (n *NetworkConfigController) nextInterval() time.Duration {
+	if n.reconcileJitter <= 0 {
+		return n.reconcileInterval
+	}
+	return n.reconcileInterval + time.Duration(n.rand.Float64()*n.reconcileJitter*float64(n.reconcileInterval))
+}
+
+// Pause stops the reconcile loop from ensuring configs until Resume is called,
+// so that manual changes made while debugging are not reverted.
+func (n *NetworkConfigController) Pause() {
+	n.paused.Store(true)
+	glog.Infof("NetworkConfigController reconciliation paused")
+}
+
+// Resume re-enables the reconcile loop after Pause.
+func (n *NetworkConfigController) Resume() {
+	n.paused.Store(false)
+	glog.Infof("NetworkConfigController reconciliation resumed")
+}
+
+// Paused returns whether reconciliation is currently paused.
+func (n *NetworkConfigController) Paused() bool {
+	return n.paused.Load()
+}
+
+// SetReadOnly switches the controller to observing only: each reconcile logs
+// the changes it would make instead of making them, and the health endpoint
+// reports unhealthy. It is meant for nodes where netd lacks CAP_NET_ADMIN, so
+// netd keeps reporting drift instead of failing every Ensure.
+func (n *NetworkConfigController) SetReadOnly(readOnly bool) {
+	n.readOnly.Store(readOnly)
+	if readOnly {
+		glog.Warningf("NetworkConfigController is read-only, no changes will be made")
+	}
+}
+
+// ReadOnly returns whether the controller only observes.
+func (n *NetworkConfigController) ReadOnly() bool {
+	return n.readOnly.Load()
+}
+
+// ServeHTTP reports the controller health, including whether it is paused.
+// A read-only controller is reported unhealthy.
+func (n *NetworkConfigController) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if n.ReadOnly() {
+		http.Error(w, "unhealthy: read-only, missing CAP_NET_ADMIN", http.StatusServiceUnavailable)
+		return
+	}
+	if n.Paused() {
+		fmt.Fprintln(w, "ok (reconciliation paused)")
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (n *NetworkConfigController) reconcile() {
+	if n.Paused() {
+		glog.Infof("NetworkConfigController is paused, skipping reconcile")
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ReadOnly() {
+		n.observe()
+		return
+	}
+	if n.tracker != nil {
+		if n.generationAdvanced() || n.reconciles%n.fullReconcileEvery == 0 {
+			n.tracker.Reset()
+		}
+		n.reconciles++
+	}
+	n.ensure()
+	n.saveState()
+}
+
+// RestoreState loads the feature state persisted in store and re-applies the
+// enabled state of every feature whose flag-derived value is unchanged since
+// it was saved, so a feature disabled at runtime stays disabled across
+// restarts. The state is saved to store after each reconcile. It must be
+// called before Run.
+func (n *NetworkConfigController) RestoreState(store *StateStore) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stateStore = store
+	n.configured = make(map[string]bool)
+	n.savedState = store.Load()
+	for _, cs := range n.configSet {
+		n.configured[cs.FeatureName] = cs.Enabled
+		state, ok := n.savedState[cs.FeatureName]
+		if !ok || state.Configured != cs.Enabled || state.Enabled == cs.Enabled {
 			continue
 		}
+		glog.Infof("restoring %s enabled=%v from %s", cs.FeatureName, state.Enabled, store.path)
+		cs.Enabled = state.Enabled
+	}
+}
+
+// SetFeatureEnabled changes the enabled state of a feature at runtime. It
+// takes effect on the next reconcile and persists across restarts when a
+// StateStore is in use.
+func (n *NetworkConfigController) SetFeatureEnabled(featureName string, enabled bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, cs := range n.configSet {
+		if cs.FeatureName == featureName {
+			cs.Enabled = enabled
+			return true
+		}
 	}
+	return false
+}
+
+func (n *NetworkConfigController) saveState() {
+	if n.stateStore == nil {
+		return
+	}
+	states := make(map[string]FeatureState)
+	for _, cs := range n.configSet {
+		states[cs.FeatureName] = FeatureState{Enabled: cs.Enabled, Configured: n.configured[cs.FeatureName]}
+	}
+	if reflect.DeepEqual(states, n.savedState) {
+		return
+	}
+	if err := n.stateStore.Save(states); err != nil {
+		glog.Errorf("failed to save feature state: %v", err)
+		return
+	}
+	n.savedState = states
 }
 
 func (n *NetworkConfigController) ensure() {
 	for _, cs := range n.configSet {
-		for _, c := range cs.Configs {
-			if err := c.Ensure(cs.Enabled); err != nil {
-				glog.Errorf("found an error for %v: %v when ensuring %v", cs.FeatureName, err, reflect.ValueOf(c))
+		s := *cs
+		s.Tracker = n.tracker
+		if n.criticalFeatures[s.FeatureName] {
+			hook := s.OnReconcileComplete
+			s.OnReconcileComplete = func(summary config.ReconcileSummary) {
+				if hook != nil {
+					hook(summary)
+				}
+				n.recordOutcome(summary)
+			}
+		}
+		if n.auditLog != nil {
+			hook := s.OnChange
+			s.OnChange = func(event config.ChangeEvent) {
+				if hook != nil {
+					hook(event)
+				}
+				n.auditLog.Record(event)
+			}
+		}
+		// Set.Ensure logs the errors of each config.
+		if n.netlinkBatch {
+			_ = s.EnsureBatched()
+		} else {
+			_ = s.Ensure()
+		}
+	}
+}
+
+// SetChangeTracking makes the controller only ensure the configs that changed
+// since they were last applied, and every config on every fullReconcileEvery
+// reconciles, starting with the first, to repair changes made outside netd.
+// A fullReconcileEvery of one or less ensures every config on every
+// reconcile. It must be called before Run.
+func (n *NetworkConfigController) SetChangeTracking(fullReconcileEvery int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.tracker = nil
+	n.fullReconcileEvery = fullReconcileEvery
+	n.reconciles = 0
+	if fullReconcileEvery > 1 {
+		n.tracker = config.NewChangeTracker()
+	}
+}
+
+// SetGenerationSource forces a full reconcile, ensuring even the configs
+// change tracking would skip, whenever generation returns a higher value than
+// on the previous reconcile, e.g. after the node's network was reconfigured.
+// It must be called before Run.
+func (n *NetworkConfigController) SetGenerationSource(generation func() (uint64, error)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.generation = generation
+	n.lastGeneration = 0
+}
+
+// generationAdvanced reports whether the generation increased since it was
+// last read. A failed read counts as unchanged.
+func (n *NetworkConfigController) generationAdvanced() bool {
+	if n.generation == nil {
+		return false
+	}
+	generation, err := n.generation()
+	if err != nil {
+		glog.Errorf("failed to read the network config generation: %v", err)
+		return false
+	}
+	if generation <= n.lastGeneration {
+		return false
+	}
+	glog.Infof("network config generation advanced from %d to %d, forcing a full reconcile", n.lastGeneration, generation)
+	n.lastGeneration = generation
+	return true
+}
+
+// SetCanary restricts each feature of percents to that percentage of the
+// nodes, picking this one by nodeName. It must be called before Run.
+func (n *NetworkConfigController) SetCanary(nodeName string, percents map[string]int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, cs := range n.configSet {
+		if percent, ok := percents[cs.FeatureName]; ok {
+			cs.Canary = &config.CanaryGate{NodeName: nodeName, Percent: float64(percent)}
+			glog.Infof("%s canary at %d%%: selected=%v", cs.FeatureName, percent, cs.Canary.Selected(cs.FeatureName))
+		}
+	}
+}
+
+// SetNetlinkBatch makes the controller ensure each feature with
+// Set.EnsureBatched, issuing its route and rule calls on one netlink socket
+// per reconcile. It must be called before Run.
+func (n *NetworkConfigController) SetNetlinkBatch(batch bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.netlinkBatch = batch
+}
+
+// SetAuditLog records every change the controller makes in log. It must be
+// called before Run.
+func (n *NetworkConfigController) SetAuditLog(log *AuditLog) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.auditLog = log
+}
+
+// SetFailureThreshold makes the controller call handler once a feature among
+// criticalFeatures has failed every one of its configs for threshold
+// consecutive reconciles. A nil handler logs and exits non-zero, so the
+// orchestrator restarts netd. A threshold of zero disables the check. It must
+// be called before Run.
+func (n *NetworkConfigController) SetFailureThreshold(threshold int, criticalFeatures []string, handler func(featureName string, failures int)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if handler == nil {
+		handler = func(featureName string, failures int) {
+			glog.Exitf("%s failed to apply for %d consecutive reconciles, exiting", featureName, failures)
+		}
+	}
+	n.failureThreshold = threshold
+	n.failureHandler = handler
+	n.criticalFeatures = make(map[string]bool)
+	n.consecutiveFailures = make(map[string]int)
+	if threshold <= 0 {
+		return
+	}
+	for _, name := range criticalFeatures {
+		n.criticalFeatures[name] = true
+	}
+}
+
+// recordOutcome counts the consecutive fully-failed reconciles of a critical
+// feature. Any config applying successfully resets the count.
+func (n *NetworkConfigController) recordOutcome(summary config.ReconcileSummary) {
+	if summary.Total == 0 || summary.Failed < summary.Total {
+		n.consecutiveFailures[summary.FeatureName] = 0
+		return
+	}
+	n.consecutiveFailures[summary.FeatureName]++
+	failures := n.consecutiveFailures[summary.FeatureName]
+	glog.Warningf("every config of critical feature %s failed, %d/%d consecutive reconciles", summary.FeatureName, failures, n.failureThreshold)
+	if failures >= n.failureThreshold {
+		n.failureHandler(summary.FeatureName, failures)
+	}
+}
+
+// observe logs the configs whose live state differs from the desired one,
+// without changing them.
+func (n *NetworkConfigController) observe() {
+	for _, cs := range n.configSet {
+		cs, ok := cs.Resolve()
+		if !ok {
+			continue
+		}
+		snapshot, err := cs.CurrentState()
+		if err != nil {
+			glog.Errorf("failed to query the state of %v: %v", cs.FeatureName, err)
+		}
+		for _, state := range snapshot.Divergent() {
+			action := "remove"
+			if cs.Enabled {
+				action = "apply"
 			}
+			glog.Warningf("read-only: would %s %v for %v", action, state.Config, cs.FeatureName)
+		}
+	}
+}
+
+// checkConflicts warns about ip rules that different features install at the
+// same priority.
+func (n *NetworkConfigController) checkConflicts() {
+	sets := make([]config.Set, 0, len(n.configSet))
+	for _, cs := range n.configSet {
+		sets = append(sets, *cs)
+	}
+	if err := config.CheckRulePriorityConflicts(sets); err != nil {
+		glog.Warningf("conflicting ip rule priorities: %v", err)
+	}
+}
+
+// CheckDivergence reports whether the live state of every feature matches
+// its desired state, without changing anything.
+func (n *NetworkConfigController) CheckDivergence() (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	match := true
+	var errs []error
+	for _, cs := range n.configSet {
+		ok, err := config.CheckDivergence(*cs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cs.FeatureName, err))
 		}
+		match = match && ok
+	}
+	return match, errors.Join(errs...)
+}
+
+// SweepOrphanedChains deletes the iptables chains netd owns that none of its
+// features manages any more. It must be called before Run.
+func (n *NetworkConfigController) SweepOrphanedChains() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var sets []config.Set
+	for _, cs := range n.configSet {
+		sets = append(sets, *cs)
 	}
+	return config.SweepOrphanedChains(sets)
 }
 
 func (n *NetworkConfigController) printConfig() {
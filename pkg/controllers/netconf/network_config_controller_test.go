@@ -0,0 +1,292 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/netd/pkg/config"
+)
+
+type countingConfig struct {
+	count *int
+}
+
+func (c countingConfig) Ensure(_ bool) error {
+	*c.count++
+	return nil
+}
+
+func newTestController(configs ...config.Config) *NetworkConfigController {
+	return &NetworkConfigController{
+		configSet: []*config.Set{
+			{Enabled: true, FeatureName: "Test", Configs: configs},
+		},
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	var count int
+	n := newTestController(countingConfig{&count})
+
+	n.reconcile()
+	if count != 1 {
+		t.Fatalf("reconcile should ensure configs, got %d calls", count)
+	}
+
+	n.Pause()
+	if !n.Paused() {
+		t.Fatal("Paused() should be true after Pause()")
+	}
+	n.reconcile()
+	n.reconcile()
+	if count != 1 {
+		t.Errorf("reconcile should be skipped while paused, got %d calls", count)
+	}
+
+	n.Resume()
+	if n.Paused() {
+		t.Fatal("Paused() should be false after Resume()")
+	}
+	n.reconcile()
+	if count != 2 {
+		t.Errorf("reconcile should ensure configs after Resume(), got %d calls", count)
+	}
+}
+
+func TestHealthReportsPaused(t *testing.T) {
+	n := newTestController()
+
+	rec := httptest.NewRecorder()
+	n.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if strings.Contains(rec.Body.String(), "paused") {
+		t.Errorf("health should not report paused, got %q", rec.Body.String())
+	}
+
+	n.Pause()
+	rec = httptest.NewRecorder()
+	n.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if !strings.Contains(rec.Body.String(), "paused") {
+		t.Errorf("health should report paused, got %q", rec.Body.String())
+	}
+}
+
+func TestNetlinkBatch(t *testing.T) {
+	var count int
+	n := newTestController(countingConfig{&count})
+	n.SetNetlinkBatch(true)
+
+	n.reconcile()
+	if count != 1 {
+		t.Errorf("a batched reconcile should ensure configs, got %d calls", count)
+	}
+}
+
+func TestSetFeatureEnabled(t *testing.T) {
+	n := newTestController()
+	if !n.SetFeatureEnabled("Test", false) || n.configSet[0].Enabled {
+		t.Error("SetFeatureEnabled should disable the feature")
+	}
+	if n.SetFeatureEnabled("Other", true) {
+		t.Error("SetFeatureEnabled of an unknown feature should fail")
+	}
+}
+
+func TestReconcileJitter(t *testing.T) {
+	interval := 10 * time.Second
+	n := &NetworkConfigController{
+		reconcileInterval: interval,
+		reconcileJitter:   0.2,
+		rand:              newJitterRand("node-1"),
+	}
+	for i := 0; i < 100; i++ {
+		d := n.nextInterval()
+		if d < interval || d > interval+2*time.Second {
+			t.Fatalf("nextInterval() = %v, want within [%v, %v]", d, interval, interval+2*time.Second)
+		}
+	}
+
+	a, b := newJitterRand("node-1"), newJitterRand("node-1")
+	if a.Int63() != b.Int63() {
+		t.Error("jitter source should be stable for the same node name")
+	}
+
+	n.reconcileJitter = 0
+	if d := n.nextInterval(); d != interval {
+		t.Errorf("nextInterval() with no jitter = %v, want %v", d, interval)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	var count, writes int
+	sysctl := config.SysctlConfig{
+		Key:   "net.ipv4.ip_forward",
+		Value: "1",
+		SysctlFunc: func(_ string, params ...string) (string, error) {
+			if len(params) > 0 {
+				writes++
+			}
+			return "0", nil
+		},
+	}
+	n := newTestController(countingConfig{&count}, sysctl)
+	n.SetReadOnly(true)
+
+	n.reconcile()
+	if count != 0 || writes != 0 {
+		t.Errorf("a read-only reconcile should change nothing, got %d ensures and %d sysctl writes", count, writes)
+	}
+
+	rec := httptest.NewRecorder()
+	n.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("health of a read-only controller = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	n.SetReadOnly(false)
+	n.reconcile()
+	if count != 1 || writes != 1 {
+		t.Errorf("reconcile should ensure configs once writable, got %d ensures and %d sysctl writes", count, writes)
+	}
+}
+
+type failingConfig struct {
+	fail *bool
+}
+
+func (c failingConfig) Ensure(_ bool) error {
+	if *c.fail {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestFailureThreshold(t *testing.T) {
+	fail := true
+	n := newTestController(failingConfig{&fail}, failingConfig{&fail})
+	var fired []int
+	n.SetFailureThreshold(3, []string{"Test"}, func(featureName string, failures int) {
+		if featureName != "Test" {
+			t.Errorf("handler called for %s, want Test", featureName)
+		}
+		fired = append(fired, failures)
+	})
+
+	n.reconcile()
+	n.reconcile()
+	fail = false
+	n.reconcile()
+	fail = true
+	n.reconcile()
+	n.reconcile()
+	if len(fired) != 0 {
+		t.Fatalf("a successful reconcile should reset the count, handler fired at %v", fired)
+	}
+	n.reconcile()
+	if len(fired) != 1 || fired[0] != 3 {
+		t.Errorf("handler should fire at the third consecutive failure, fired at %v", fired)
+	}
+
+	// Features that are not critical never trigger the handler.
+	fired = nil
+	n.SetFailureThreshold(1, []string{"Other"}, func(string, int) { fired = append(fired, 0) })
+	n.reconcile()
+	if len(fired) != 0 {
+		t.Errorf("handler fired for a non-critical feature")
+	}
+}
+
+// funcConfig hands Ensure to a function, so that its hash stays the same
+// across reconciles unlike that of a countingConfig.
+type funcConfig func(enabled bool) error
+
+func (c funcConfig) Ensure(enabled bool) error {
+	return c(enabled)
+}
+
+func TestChangeTracking(t *testing.T) {
+	var count int
+	n := newTestController(funcConfig(countingConfig{&count}.Ensure))
+	n.SetChangeTracking(3)
+
+	for i := 0; i < 6; i++ {
+		n.reconcile()
+	}
+	// Reconciles 0 and 3 are full, the others find the config unchanged.
+	if count != 2 {
+		t.Errorf("config ensured %d times over 6 reconciles, want 2", count)
+	}
+
+	n.SetFeatureEnabled("Test", false)
+	n.reconcile()
+	if count != 3 {
+		t.Errorf("disabling the feature should ensure its config, got %d calls", count)
+	}
+}
+
+func TestGenerationForcesFullReconcile(t *testing.T) {
+	var count int
+	n := newTestController(funcConfig(countingConfig{&count}.Ensure))
+	n.SetChangeTracking(100)
+	generation := uint64(7)
+	var genErr error
+	n.SetGenerationSource(func() (uint64, error) { return generation, genErr })
+
+	n.reconcile()
+	n.reconcile()
+	if count != 1 {
+		t.Fatalf("config ensured %d times before the generation changed, want 1", count)
+	}
+
+	generation = 8
+	n.reconcile()
+	if count != 2 {
+		t.Errorf("a generation bump should force a full reconcile, got %d calls", count)
+	}
+	n.reconcile()
+	if count != 2 {
+		t.Errorf("the same generation should not force another full reconcile, got %d calls", count)
+	}
+
+	genErr = errors.New("metadata unavailable")
+	generation = 9
+	n.reconcile()
+	if count != 2 {
+		t.Errorf("a failed generation read should not force a full reconcile, got %d calls", count)
+	}
+}
+
+func TestSetCanary(t *testing.T) {
+	var ensured []bool
+	n := newTestController(funcConfig(func(enabled bool) error {
+		ensured = append(ensured, enabled)
+		return nil
+	}))
+	n.SetCanary("node-1", map[string]int{"Test": 0, "Other": 100})
+	n.reconcile()
+	if len(ensured) != 1 || ensured[0] {
+		t.Errorf("a node outside the canary should remove the feature's configs, got %v", ensured)
+	}
+	if !n.configSet[0].Enabled {
+		t.Error("the canary should not change the configured Enabled state")
+	}
+}
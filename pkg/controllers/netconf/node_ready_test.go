@@ -0,0 +1,62 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeReadyCheck(t *testing.T) {
+	node := func(name string, status v1.ConditionStatus, cordoned bool) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.NodeSpec{Unschedulable: cordoned},
+			Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+				{Type: v1.NodeReady, Status: status},
+			}},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		node("ready", v1.ConditionTrue, false),
+		node("not-ready", v1.ConditionFalse, false),
+		node("unknown", v1.ConditionUnknown, false),
+		node("cordoned", v1.ConditionTrue, true),
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "no-conditions"}},
+	)
+
+	for name, want := range map[string]bool{
+		"ready":         true,
+		"not-ready":     false,
+		"unknown":       false,
+		"cordoned":      false,
+		"no-conditions": false,
+	} {
+		ready, err := NodeReadyCheck(client, name)()
+		if err != nil || ready != want {
+			t.Errorf("NodeReadyCheck(%s) = %v, %v; want %v, nil", name, ready, err, want)
+		}
+	}
+
+	if _, err := NodeReadyCheck(client, "missing")(); err == nil {
+		t.Error("NodeReadyCheck should fail for a missing node")
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/golang/glog"
+)
+
+// NodeWatchOptions bounds the load WatchNode puts on the API server. The zero
+// value reads the API server as often as the informer needs. To bound how
+// often the ensure pipeline runs, pass a DebouncedReconciler's Trigger as
+// onChange.
+type NodeWatchOptions struct {
+	// ResyncPeriod is how often the cached node is replayed, calling onChange
+	// without reading the API server. Zero disables resyncs, and the informer
+	// raises periods below one second to it.
+	ResyncPeriod time.Duration
+	// ListWatchQPS bounds the rate of list and watch requests, which the
+	// informer repeats whenever its watch is dropped. Zero is unlimited.
+	ListWatchQPS float32
+}
+
+// WatchNode watches the Node named nodeName and calls onChange once after the
+// initial sync, then whenever the node is re-created or deleted, its
+// PodCIDRs, InternalIPs or labels change, and on every resync. Updates
+// touching nothing else are ignored. onChange may be called from the informer
+// goroutine. WatchNode blocks until ctx is cancelled. It is meant for configs
+// derived from the Node, which netd has none of yet, so netd does not run it.
+func WatchNode(ctx context.Context, client kubernetes.Interface, nodeName string, opts NodeWatchOptions, onChange func()) error {
+	limiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	if opts.ListWatchQPS > 0 {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(opts.ListWatchQPS, 1)
+	}
+	defer limiter.Stop()
+	selector := fields.OneTermEqualSelector("metadata.name", nodeName).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			options.FieldSelector = selector
+			return client.CoreV1().Nodes().List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			options.FieldSelector = selector
+			return client.CoreV1().Nodes().Watch(ctx, options)
+		},
+	}
+	glog.Infof("watching node %s with resync period %v and %v list/watch QPS", nodeName, opts.ResyncPeriod, opts.ListWatchQPS)
+	informer := cache.NewSharedIndexInformer(lw, &v1.Node{}, opts.ResyncPeriod, cache.Indexers{})
+
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(_ interface{}, isInInitialList bool) {
+			// Nodes listed during the initial sync are covered by the call below.
+			if !isInInitialList {
+				glog.Infof("node %s added, re-running the ensure pipeline", nodeName)
+				onChange()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := newObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			switch {
+			case oldNode == newNode:
+				// A resync replays the cached node itself.
+				glog.V(2).Infof("resyncing node %s, re-running the ensure pipeline", nodeName)
+				onChange()
+			case nodeChanged(oldNode, newNode):
+				glog.Infof("node %s changed, re-running the ensure pipeline", nodeName)
+				onChange()
+			}
+		},
+		DeleteFunc: func(interface{}) {
+			glog.Warningf("node %s deleted, re-running the ensure pipeline", nodeName)
+			onChange()
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	go informer.Run(ctx.Done())
+	// The registration has synced once the handler has seen the initial list,
+	// so no add from it can race with the call below.
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced, registration.HasSynced) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to sync informer for node %s", nodeName)
+	}
+	onChange()
+
+	<-ctx.Done()
+	glog.Infof("stopped watching node %s", nodeName)
+	return nil
+}
+
+// nodeChanged reports whether the fields netd derives policy routing from
+// differ between the two Node versions.
+func nodeChanged(oldNode, newNode *v1.Node) bool {
+	if oldNode.Spec.PodCIDR != newNode.Spec.PodCIDR || !reflect.DeepEqual(oldNode.Spec.PodCIDRs, newNode.Spec.PodCIDRs) {
+		return true
+	}
+	if !reflect.DeepEqual(nodeInternalIPs(oldNode), nodeInternalIPs(newNode)) {
+		return true
+	}
+	return !reflect.DeepEqual(oldNode.Labels, newNode.Labels)
+}
+
+func nodeInternalIPs(node *v1.Node) []string {
+	var ips []string
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP {
+			ips = append(ips, addr.Address)
+		}
+	}
+	return ips
+}
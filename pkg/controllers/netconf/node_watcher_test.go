@@ -0,0 +1,217 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchNode(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"a": "b"}},
+		Spec:       v1.NodeSpec{PodCIDR: "10.0.0.0/24"},
+	}
+	client := fake.NewSimpleClientset(node)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	done := make(chan error)
+	go func() {
+		done <- WatchNode(ctx, client, "node-1", NodeWatchOptions{}, func() { changes <- struct{}{} })
+	}()
+
+	expectChange := func(want bool, msg string) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Errorf("unexpected onChange call: %s", msg)
+			}
+		case <-time.After(500 * time.Millisecond):
+			if want {
+				t.Errorf("expected onChange call: %s", msg)
+			}
+		}
+	}
+	expectChange(true, "initial sync")
+
+	node = node.DeepCopy()
+	node.Annotations = map[string]string{"unrelated": "update"}
+	node, _ = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	expectChange(false, "annotation update")
+
+	node = node.DeepCopy()
+	node.Spec.PodCIDR = "10.0.1.0/24"
+	if _, err := client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update node: %v", err)
+	}
+	expectChange(true, "PodCIDR update")
+
+	if err := client.CoreV1().Nodes().Delete(ctx, "node-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete node: %v", err)
+	}
+	expectChange(true, "node deletion")
+
+	node = node.DeepCopy()
+	node.ResourceVersion = ""
+	if _, err := client.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to re-create node: %v", err)
+	}
+	expectChange(true, "node re-creation")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WatchNode returned error on cancel: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchNode did not return after context cancel")
+	}
+}
+
+func TestNodeChanged(t *testing.T) {
+	base := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"a": "b"}},
+		Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+			{Type: v1.NodeInternalIP, Address: "10.128.0.2"},
+			{Type: v1.NodeHostName, Address: "node-1"},
+		}},
+	}
+
+	n := base.DeepCopy()
+	n.Status.Addresses[1].Address = "node-2"
+	if nodeChanged(base, n) {
+		t.Error("a hostname change should not count as a node change")
+	}
+
+	n = base.DeepCopy()
+	n.Status.Addresses[0].Address = "10.128.0.3"
+	if !nodeChanged(base, n) {
+		t.Error("an InternalIP change should count as a node change")
+	}
+
+	n = base.DeepCopy()
+	n.Labels["a"] = "c"
+	if !nodeChanged(base, n) {
+		t.Error("a label change should count as a node change")
+	}
+}
+
+// watchNodeCalls runs WatchNode with opts, applies update to the node after
+// the initial sync, and returns the times onChange was called within wait.
+func watchNodeCalls(t *testing.T, opts NodeWatchOptions, wait time.Duration, update func(context.Context, *fake.Clientset)) []time.Time {
+	t.Helper()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{PodCIDR: "10.0.0.0/24"}}
+	client := fake.NewSimpleClientset(node)
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+
+	var mu sync.Mutex
+	var calls []time.Time
+	synced := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- WatchNode(ctx, client, "node-1", opts, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if calls = append(calls, time.Now()); len(calls) == 1 {
+				close(synced)
+			}
+		})
+	}()
+	select {
+	case <-synced:
+	case <-ctx.Done():
+		t.Fatal("onChange was not called after the initial sync")
+	}
+	if update != nil {
+		update(ctx, client)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("WatchNode returned error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]time.Time(nil), calls...)
+}
+
+func TestWatchNodeResyncPeriod(t *testing.T) {
+	calls := watchNodeCalls(t, NodeWatchOptions{ResyncPeriod: time.Second, ListWatchQPS: 5}, 2500*time.Millisecond, nil)
+	// The initial sync, then a resync every second.
+	if len(calls) < 2 || len(calls) > 3 {
+		t.Errorf("onChange called %d times in 2.5s with a 1s resync period, want 3", len(calls))
+	}
+
+	calls = watchNodeCalls(t, NodeWatchOptions{}, 1500*time.Millisecond, nil)
+	if len(calls) != 1 {
+		t.Errorf("onChange called %d times without resyncs or updates, want 1", len(calls))
+	}
+}
+
+func TestWatchNodeDebounced(t *testing.T) {
+	const window = 300 * time.Millisecond
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{PodCIDR: "10.0.0.0/24"}}
+	client := fake.NewSimpleClientset(node)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan struct{}, 20)
+	d := NewDebouncedReconciler(window, func() error {
+		runs <- struct{}{}
+		return nil
+	})
+	go d.Run(ctx.Done())
+	go WatchNode(ctx, client, "node-1", NodeWatchOptions{}, d.Trigger)
+	expectRuns := func(want int, msg string) {
+		t.Helper()
+		got := 0
+		timeout := time.After(3 * window)
+		for done := false; !done; {
+			select {
+			case <-runs:
+				got++
+			case <-timeout:
+				done = true
+			}
+		}
+		if got != want {
+			t.Errorf("pipeline ran %d times %s, want %d", got, msg, want)
+		}
+	}
+	expectRuns(1, "after the initial sync")
+
+	for i := 0; i < 10; i++ {
+		node, err := client.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get node: %v", err)
+		}
+		node.Spec.PodCIDR = fmt.Sprintf("10.0.%d.0/24", i+1)
+		if _, err := client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("failed to update node: %v", err)
+		}
+	}
+	expectRuns(1, "for a burst of updates")
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+)
+
+// FeatureState is the persisted state of one feature. Configured is the value
+// derived from flags when Enabled was applied, so a runtime choice is only
+// restored while the flags still agree with the ones it was made under.
+type FeatureState struct {
+	Enabled    bool `json:"enabled"`
+	Configured bool `json:"configured"`
+}
+
+// StateStore persists the last-applied FeatureState of each feature, by
+// FeatureName, in a JSON file.
+type StateStore struct {
+	path string
+}
+
+// NewStateStore creates a StateStore backed by the file at path.
+func NewStateStore(path string) *StateStore {
+	return &StateStore{path: path}
+}
+
+// Load returns the stored states. A missing or corrupt file yields no states
+// rather than an error, so netd always starts from its flags in that case.
+func (s *StateStore) Load() map[string]FeatureState {
+	states := make(map[string]FeatureState)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("failed to read feature state from %s: %v", s.path, err)
+		}
+		return states
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		glog.Warningf("ignoring corrupt feature state in %s: %v", s.path, err)
+		return make(map[string]FeatureState)
+	}
+	return states
+}
+
+// Save atomically replaces the stored states.
+func (s *StateStore) Save(states map[string]FeatureState) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateStoreSaveLoad(t *testing.T) {
+	store := NewStateStore(filepath.Join(t.TempDir(), "netd", "state.json"))
+	if got := store.Load(); len(got) != 0 {
+		t.Errorf("Load of a missing file = %v, want no states", got)
+	}
+
+	want := map[string]FeatureState{"PolicyRouting": {Enabled: false, Configured: true}}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if got := store.Load(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %v, want %v", got, want)
+	}
+}
+
+func TestStateStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"PolicyRouting": {"enab`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewStateStore(path).Load(); len(got) != 0 {
+		t.Errorf("Load of a corrupt file = %v, want no states", got)
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	store := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+	var count int
+
+	n := newTestController(countingConfig{&count})
+	n.RestoreState(store)
+	n.SetFeatureEnabled("Test", false)
+	n.reconcile()
+
+	// A restart with the same flags keeps the runtime choice.
+	n = newTestController(countingConfig{&count})
+	n.RestoreState(store)
+	if n.configSet[0].Enabled {
+		t.Error("a feature disabled at runtime should stay disabled after a restart")
+	}
+
+	// A restart after the flag was flipped on starts from the flags.
+	if err := store.Save(map[string]FeatureState{"Test": {Enabled: false, Configured: false}}); err != nil {
+		t.Fatal(err)
+	}
+	n = newTestController(countingConfig{&count})
+	n.RestoreState(store)
+	if !n.configSet[0].Enabled {
+		t.Error("a flag change should override the persisted state")
+	}
+}
@@ -44,31 +44,33 @@ func init() {
 }
 
 // StartCollector starts the metrics collector with mcfg configured from input flag
+// and the HTTP listener, which also serves the handlers registered on the
+// default mux, e.g. /healthz, even when no collector is enabled.
 func StartCollector() error {
+	registry := prometheus.NewRegistry()
 	if mcfg.enabledCollectors == "" {
 		glog.Infof("No metrics collectors were enabled.")
-		return nil
-	}
-	enabledCollectors := strings.Split(mcfg.enabledCollectors, ",")
-	nc, pc, err := collector.NewNodeCollector(enabledCollectors, mcfg.procPath, mcfg.stackType)
-	if err != nil {
-		return err
-	}
-	glog.Infof("Enabled metrics collectors:")
-	for n := range nc.Collectors {
-		glog.Infof(" - %s", n)
-	}
+	} else {
+		enabledCollectors := strings.Split(mcfg.enabledCollectors, ",")
+		nc, pc, err := collector.NewNodeCollector(enabledCollectors, mcfg.procPath, mcfg.stackType)
+		if err != nil {
+			return err
+		}
+		glog.Infof("Enabled metrics collectors:")
+		for n := range nc.Collectors {
+			glog.Infof(" - %s", n)
+		}
 
-	registry := prometheus.NewRegistry()
-	err = registry.Register(nc)
-	if err != nil {
-		glog.Errorf("Couldn't register collector: %v", err)
-		return err
-	}
+		if err := registry.Register(nc); err != nil {
+			glog.Errorf("Couldn't register collector: %v", err)
+			return err
+		}
 
-	for _, c := range pc {
-		registry.MustRegister(c)
+		for _, c := range pc {
+			registry.MustRegister(c)
+		}
 	}
+	registry.MustRegister(NetlinkErrors)
 
 	gatherers := prometheus.Gatherers{
 		registry,
@@ -86,8 +88,7 @@ func StartCollector() error {
 	go func() {
 		http.HandleFunc("/metrics", h.ServeHTTP)
 		glog.Infof("Listening on %s", mcfg.listenAddress)
-		err = http.ListenAndServe(mcfg.listenAddress, nil)
-		if err != nil {
+		if err := http.ListenAndServe(mcfg.listenAddress, nil); err != nil {
 			glog.Errorf("Couldn't start http server- %v", err)
 		}
 	}()
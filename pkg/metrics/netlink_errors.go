@@ -0,0 +1,53 @@
+/*
+Copyright 2026 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/unix"
+)
+
+// NetlinkErrors counts failed netlink calls made to ensure routes and rules,
+// by operation (e.g. route_add, rule_list) and errno name.
+var NetlinkErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "netd_netlink_errors_total",
+	Help: "Failed netlink calls made by netd, by operation and errno.",
+}, []string{"operation", "errno"})
+
+// RecordNetlinkError increments NetlinkErrors for a failed call to op. A nil
+// err is ignored.
+func RecordNetlinkError(op string, err error) {
+	if err == nil {
+		return
+	}
+	NetlinkErrors.WithLabelValues(op, errnoClass(err)).Inc()
+}
+
+// errnoClass returns the errno name, e.g. "EPERM", of err or "other" when err
+// does not wrap an errno.
+func errnoClass(err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if name := unix.ErrnoName(errno); name != "" {
+			return name
+		}
+	}
+	return "other"
+}
@@ -28,6 +28,19 @@ type NetdConfig struct {
 	EnableSourceValidMark bool
 	ExcludeDNS            bool
 	ReconcileInterval     time.Duration
+	ReconcileJitter       float64
+	StateFile             string
+	SelfTest              bool
+	DiffLogVerbosity      int
+	FailureExitThreshold  int
+	CriticalFeatures      []string
+	AuditLogSize          int
+	AuditLogFile          string
+	FullReconcileEvery    int
+	Check                 bool
+	SweepOrphanedChains   bool
+	CanaryPercent         map[string]int
+	NetlinkBatch          bool
 }
 
 // NewNetdConfig creates a new netd config
@@ -45,4 +58,30 @@ func (nc *NetdConfig) AddFlags(fs *pflag.FlagSet) {
 		"Whether to exclude DNS traffic from policy routing.")
 	fs.DurationVar(&nc.ReconcileInterval, "reconcile-interval-seconds", 10*time.Second,
 		"Reconcile interval in seconds.")
+	fs.Float64Var(&nc.ReconcileJitter, "reconcile-jitter", 0.1,
+		"Maximum fraction of the reconcile interval added as a per-node random delay.")
+	fs.StringVar(&nc.StateFile, "state-file", "",
+		"File, e.g. /var/lib/netd/feature-state.json, recording the enabled state of each feature across restarts. Empty disables it.")
+	fs.BoolVar(&nc.SelfTest, "self-test", false,
+		"Apply and revert a throwaway policy rule and iptables chain, then exit with the result.")
+	fs.BoolVar(&nc.Check, "check", false,
+		"Compare the live state to the configured features without changing it, then exit non-zero if they diverge.")
+	fs.BoolVar(&nc.SweepOrphanedChains, "sweep-orphaned-chains", false,
+		"Delete the iptables chains netd owns but no longer manages at startup.")
+	fs.StringToIntVar(&nc.CanaryPercent, "canary-percent", nil,
+		"Feature=percent pairs enabling a feature on only that percentage of the nodes, picked by node name.")
+	fs.IntVar(&nc.DiffLogVerbosity, "diff-log-verbosity", 2,
+		"Log verbosity (-v) at which each change netd makes to the system is logged.")
+	fs.IntVar(&nc.FailureExitThreshold, "failure-exit-threshold", 0,
+		"Exit after this many consecutive reconciles in which every config of a critical feature failed. 0 disables it.")
+	fs.StringSliceVar(&nc.CriticalFeatures, "critical-features", []string{"PolicyRouting"},
+		"Features whose failures count towards --failure-exit-threshold.")
+	fs.IntVar(&nc.AuditLogSize, "audit-log-size", 1000,
+		"Number of recent changes served at /audit. 0 disables the audit log.")
+	fs.StringVar(&nc.AuditLogFile, "audit-log-file", "",
+		"File every change is appended to as a JSON line. Empty keeps the audit log in memory only.")
+	fs.IntVar(&nc.FullReconcileEvery, "full-reconcile-every", 0,
+		"Only ensure the configs that changed since the last reconcile, except on every Nth reconcile. 0 or 1 ensures every config on every reconcile.")
+	fs.BoolVar(&nc.NetlinkBatch, "netlink-batch", false,
+		"Issue the route and rule changes of each feature on one netlink socket per reconcile instead of one per change.")
 }